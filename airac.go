@@ -0,0 +1,75 @@
+// airac.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// AIRAC cycles
+
+// airacCycle identifies a 28-day AIRAC cycle, e.g. 2410, the tenth cycle
+// that starts in 2024.
+type airacCycle struct {
+	Year, Number int
+	Effective    time.Time
+}
+
+// airacEpoch is the effective date of cycle 2001; all cycles start a
+// multiple of 28 days before or after it.
+var airacEpoch = time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+
+// airac is the cycle of the maps being converted, if known, from -airac
+// or the ARTCC definition.
+var airac *airacCycle
+
+func (c airacCycle) String() string {
+	return fmt.Sprintf("%02d%02d", c.Year%100, c.Number)
+}
+
+// EffectiveDate returns the date that the cycle starts as YYYY-MM-DD.
+func (c airacCycle) EffectiveDate() string {
+	return c.Effective.Format(time.DateOnly)
+}
+
+// airacForDate returns the cycle that is in effect at the given time.
+func airacForDate(t time.Time) airacCycle {
+	const cycle = 28 * 24 * time.Hour
+	d := t.UTC().Sub(airacEpoch)
+	n := int64(d / cycle)
+	if d < 0 && d%cycle != 0 {
+		n-- // round toward negative infinity
+	}
+	eff := airacEpoch.Add(time.Duration(n) * cycle)
+	// The first cycle of a year starts within its first 28 days.
+	return airacCycle{Year: eff.Year(), Number: 1 + (eff.YearDay()-1)/28, Effective: eff}
+}
+
+// parseAIRAC parses either a cycle given as YYNN (e.g., 2410) or a date,
+// YYYY-MM-DD, in which case the cycle in effect on that date is
+// returned.
+func parseAIRAC(s string) (airacCycle, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return airacForDate(t), nil
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || len(s) != 4 || n%100 == 0 {
+		return airacCycle{}, fmt.Errorf("%q: expected an AIRAC cycle (e.g., 2410) or a date (YYYY-MM-DD)", s)
+	}
+	year, number := 2000+n/100, n%100
+
+	// Find the year's first cycle and count forward from there.
+	c := airacForDate(time.Date(year, 1, 28, 0, 0, 0, 0, time.UTC))
+	c = airacForDate(c.Effective.Add(time.Duration(number-1) * 28 * 24 * time.Hour))
+	if c.Year != year || c.Number != number {
+		return airacCycle{}, fmt.Errorf("%q: %d has only %d AIRAC cycles", s, year,
+			airacForDate(time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC)).Number)
+	}
+	return c, nil
+}
@@ -0,0 +1,227 @@
+// aixm.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// AIXM
+
+// isAIXM reports whether the XML is an AIXM document.
+func isAIXM(b []byte) bool {
+	b = decodeText(b[:min(len(b), 8192)])
+	return bytes.Contains(b, []byte("www.aixm.aero/schema"))
+}
+
+// parseAIXM converts the airspace volumes in an AIXM document (e.g., an
+// FAA MVA chart or airspace boundaries) into a single map. The outline
+// of each volume is drawn and its altitudes are added as a label at its
+// center: the minimum altitude in hundreds of feet for MVA sectors and
+// "LOWER-UPPER" for other airspace.
+func parseAIXM(fn string, b []byte) ([]STARSMap, error) {
+	stem := strings.TrimSuffix(filepath.Base(fn), filepath.Ext(fn))
+	sm := STARSMap{Group: 0, Label: strings.ToUpper(stem), Name: stem}
+
+	type limit struct{ value, uom string }
+	var (
+		inVolume         bool
+		rings            [][]Point2LL
+		limits           map[string]limit
+		lonLat           bool // axis order of the current srsName
+		circleCenter     Point2LL
+		haveCircleCenter bool
+	)
+
+	d := newXMLDecoder(b)
+	var text []byte
+	var uom string
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			text, uom = text[:0], ""
+			for _, a := range t.Attr {
+				switch a.Name.Local {
+				case "srsName":
+					lonLat = strings.Contains(a.Value, "CRS84")
+				case "uom":
+					uom = a.Value
+				}
+			}
+			if t.Name.Local == "AirspaceVolume" {
+				inVolume, rings, limits = true, nil, make(map[string]limit)
+			}
+
+		case xml.CharData:
+			text = append(text, t...)
+
+		case xml.EndElement:
+			if !inVolume {
+				continue
+			}
+			switch t.Name.Local {
+			case "posList", "pos":
+				pts, err := parseGMLPositions(string(text), lonLat)
+				if err != nil {
+					return nil, err
+				}
+				if len(pts) == 1 {
+					// The center of a circle.
+					circleCenter, haveCircleCenter = pts[0], true
+				} else if n := len(rings); n > 0 && len(pts) > 1 && rings[n-1][len(rings[n-1])-1] == pts[0] {
+					// Continuation of the previous curve segment.
+					rings[n-1] = append(rings[n-1], pts[1:]...)
+				} else if len(pts) > 1 {
+					rings = append(rings, pts)
+				}
+
+			case "radius":
+				r, err := strconv.ParseFloat(strings.TrimSpace(string(text)), 64)
+				if err != nil || !haveCircleCenter {
+					return nil, fmt.Errorf("%s: invalid circle", strings.TrimSpace(string(text)))
+				}
+				nm, err := aixmNauticalMiles(r, uom)
+				if err != nil {
+					return nil, err
+				}
+				rings = append(rings, circleLL(circleCenter, float32(nm), 90))
+				haveCircleCenter = false
+
+			case "upperLimit", "lowerLimit", "minimumLimit":
+				limits[t.Name.Local] = limit{value: strings.TrimSpace(string(text)), uom: uom}
+
+			case "AirspaceVolume":
+				inVolume = false
+				sm.Lines = append(sm.Lines, rings...)
+
+				var label string
+				if l, ok := limits["minimumLimit"]; ok {
+					label = aixmAltitude(l.value, l.uom, true)
+				} else if lo, ok := limits["lowerLimit"]; ok {
+					label = aixmAltitude(lo.value, lo.uom, false) + "-" +
+						aixmAltitude(limits["upperLimit"].value, limits["upperLimit"].uom, false)
+				}
+				if label != "" && len(rings) > 0 {
+					sm.Labels = append(sm.Labels, STARSMapLabel{P: ringCentroid(rings[0]), Text: label})
+				}
+			}
+			text = text[:0]
+		}
+	}
+
+	if len(sm.Lines) == 0 {
+		return nil, fmt.Errorf("no airspace volumes found")
+	}
+	return []STARSMap{sm}, nil
+}
+
+// parseGMLPositions parses the coordinates of a gml:pos or gml:posList;
+// unless lonLat is set, they're in latitude, longitude order, as is the
+// case for EPSG:4326.
+func parseGMLPositions(s string, lonLat bool) ([]Point2LL, error) {
+	f := strings.Fields(s)
+	if len(f)%2 != 0 {
+		return nil, fmt.Errorf("%q: expected pairs of coordinates", s)
+	}
+	var pts []Point2LL
+	for i := 0; i < len(f); i += 2 {
+		a, err := strconv.ParseFloat(f[i], 32)
+		if err != nil {
+			return nil, err
+		}
+		b, err := strconv.ParseFloat(f[i+1], 32)
+		if err != nil {
+			return nil, err
+		}
+		if lonLat {
+			pts = append(pts, Point2LL{float32(a), float32(b)})
+		} else {
+			pts = append(pts, Point2LL{float32(b), float32(a)})
+		}
+	}
+	return pts, nil
+}
+
+func aixmNauticalMiles(v float64, uom string) (float64, error) {
+	switch strings.ToUpper(uom) {
+	case "NM", "[NMI_I]":
+		return v, nil
+	case "KM":
+		return v / 1.852, nil
+	case "M":
+		return v / 1852, nil
+	case "FT":
+		return v / 6076.12, nil
+	case "MI":
+		return v * 0.868976, nil
+	default:
+		return 0, fmt.Errorf("%q: unknown unit of measure", uom)
+	}
+}
+
+// aixmAltitude formats an AIXM vertical limit for display. Altitudes in
+// feet are given in hundreds of feet; if mva is set, just the number is
+// returned.
+func aixmAltitude(v, uom string, mva bool) string {
+	if v == "" {
+		return "UNL"
+	} else if v == "GND" || v == "UNL" {
+		return v
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return v
+	}
+	switch strings.ToUpper(uom) {
+	case "FL":
+		if mva {
+			return strconv.Itoa(int(n))
+		}
+		return fmt.Sprintf("FL%03d", int(n))
+	case "M":
+		n *= 3.28084
+	}
+	if n == 0 && !mva {
+		return "SFC"
+	}
+	return strconv.Itoa(int(math.Round(n / 100)))
+}
+
+// ringCentroid returns the centroid of a closed polygon ring, or the
+// average of its vertices if it's degenerate.
+func ringCentroid(r []Point2LL) Point2LL {
+	var a, cx, cy float64
+	for i := range r {
+		p0, p1 := r[i], r[(i+1)%len(r)]
+		c := float64(p0[0])*float64(p1[1]) - float64(p1[0])*float64(p0[1])
+		a += c
+		cx += (float64(p0[0]) + float64(p1[0])) * c
+		cy += (float64(p0[1]) + float64(p1[1])) * c
+	}
+	if math.Abs(a) < 1e-12 {
+		var sum [2]float64
+		for _, p := range r {
+			sum[0] += float64(p[0])
+			sum[1] += float64(p[1])
+		}
+		return Point2LL{float32(sum[0] / float64(len(r))), float32(sum[1] / float64(len(r)))}
+	}
+	return Point2LL{float32(cx / (3 * a)), float32(cy / (3 * a))}
+}
@@ -0,0 +1,91 @@
+// asdex.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// ASDE-X surface maps
+
+// ASDEXPolygon is given by its exterior ring followed by any holes.
+type ASDEXPolygon [][]Point2LL
+
+// ASDEXMap holds the surface map for a single tower facility. The
+// polygons are in drawing order within each category; the lines (hold
+// short lines and the like) should be drawn on top of all of them.
+type ASDEXMap struct {
+	Facility   string
+	Name       string
+	Runways    []ASDEXPolygon
+	Taxiways   []ASDEXPolygon
+	Aprons     []ASDEXPolygon
+	Structures []ASDEXPolygon
+	Lines      [][]Point2LL
+}
+
+// convertASDEXMaps converts the video maps referenced by the ASDE-X
+// configurations of the ARTCC's facilities (or just those of the given
+// facility and its descendants, if one is specified) and returns them
+// indexed by facility id.
+func convertASDEXMaps(artcc ARTCC, base string, facilityId string, centers []Point2LL) map[string]ASDEXMap {
+	specs := artcc.VideoMapSpecs()
+	maps := make(map[string]ASDEXMap)
+	artcc.Scope(facilityId).Walk(func(fac *CRCFacility) {
+		if fac.ASDEXConfiguration == nil || fac.ASDEXConfiguration.VideoMapId == "" {
+			return
+		}
+
+		spec, ok := specs[fac.ASDEXConfiguration.VideoMapId]
+		if !ok {
+			fmt.Printf("\r%s: warning: ASDE-X video map %s not found\n", fac.Id, fac.ASDEXConfiguration.VideoMapId)
+			return
+		}
+
+		am := ASDEXMap{Facility: fac.Id, Name: spec.Name}
+		unknown := make(map[string]int)
+		for _, f := range readVideoMap(base, spec, centers) {
+			switch f.Geometry.Type {
+			case "LineString":
+				am.Lines = append(am.Lines, featureLines([]GeoJSONFeature{f})...)
+
+			case "Polygon":
+				if len(f.Geometry.Rings) == 0 {
+					continue
+				}
+				poly := ASDEXPolygon(f.Geometry.Rings)
+				kind, _ := f.Properties["asdex"].(string)
+				switch strings.ToLower(kind) {
+				case "runway":
+					am.Runways = append(am.Runways, poly)
+				case "taxiway":
+					am.Taxiways = append(am.Taxiways, poly)
+				case "apron":
+					am.Aprons = append(am.Aprons, poly)
+				case "structure":
+					am.Structures = append(am.Structures, poly)
+				default:
+					unknown[kind]++
+				}
+			}
+		}
+
+		if len(unknown) > 0 {
+			var u []string
+			for kind, n := range unknown {
+				u = append(u, fmt.Sprintf("%q (%d)", kind, n))
+			}
+			sort.Strings(u)
+			fmt.Printf("\r%s: warning: ignoring polygons with unknown ASDE-X types: %s\n", spec.Name, strings.Join(u, ", "))
+		}
+
+		maps[fac.Id] = am
+	})
+
+	return maps
+}
@@ -0,0 +1,176 @@
+// boundary.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// Facility boundaries
+//
+// Maps with much of their geometry far from the facility almost always
+// have projection or [lat, lon] ordering problems, so they're checked
+// against its boundary. CRC's ARTCC definitions don't include one, so
+// either it's given with -boundary or the area around the ARTCC's
+// visibility centers is used.
+
+const (
+	// boundaryMargin is how far outside of a -boundary polygon a vertex
+	// must be to be counted as outside of it, so that maps that extend
+	// a bit past the boundary aren't reported.
+	boundaryMargin = 50 // nm
+
+	// centerRadius is the distance from the nearest visibility center
+	// past which a vertex is counted as outside when there's no
+	// -boundary. It's larger than any ARTCC.
+	centerRadius = 500 // nm
+
+	// outsideFraction is the fraction of a map's vertices that must be
+	// outside of the boundary for it to be reported.
+	outsideFraction = 0.1
+)
+
+// facilityBoundary gives the area that the maps should be in: either the
+// polygons from -boundary or the area around the visibility centers.
+type facilityBoundary struct {
+	Rings   [][]Point2LL // exterior rings and holes, using the even-odd rule
+	Centers []Point2LL
+}
+
+// boundary is the boundary that maps are checked against, if known.
+var boundary *facilityBoundary
+
+// readBoundary reads the polygons of the given GeoJSON file.
+func readBoundary(fn string) (*facilityBoundary, error) {
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	var gj GeoJSON
+	if err := UnmarshalJSON(b, &gj); err != nil {
+		return nil, err
+	}
+
+	var fb facilityBoundary
+	for _, f := range gj.Features {
+		for _, r := range f.Geometry.Rings {
+			if len(r) >= 3 {
+				fb.Rings = append(fb.Rings, r)
+			}
+		}
+	}
+	if len(fb.Rings) == 0 {
+		return nil, fmt.Errorf("no polygons found")
+	}
+	return &fb, nil
+}
+
+// addBoundaryCenters adds the given visibility centers to the boundary,
+// unless one was given with -boundary. (With merge, there are centers
+// from each ARTCC.)
+func addBoundaryCenters(centers []Point2LL) {
+	if boundary == nil {
+		boundary = &facilityBoundary{}
+	}
+	if len(boundary.Rings) == 0 {
+		boundary.Centers = append(boundary.Centers, centers...)
+	}
+}
+
+// outside returns how far outside of the boundary the point is, beyond
+// boundaryMargin or centerRadius, or zero if it isn't.
+func (fb *facilityBoundary) outside(p Point2LL) float32 {
+	if len(fb.Rings) > 0 {
+		if fb.contains(p) {
+			return 0
+		}
+		d := float32(math.MaxFloat32)
+		for _, r := range fb.Rings {
+			for i := 1; i < len(r); i++ {
+				d = min(d, nmSegmentDistance(p, r[i-1], r[i]))
+			}
+		}
+		return max(0, d-boundaryMargin)
+	}
+
+	if len(fb.Centers) == 0 {
+		return 0
+	}
+	d := float32(math.MaxFloat32)
+	for _, c := range fb.Centers {
+		d = min(d, nmdistance2ll(p, c))
+	}
+	return max(0, d-centerRadius)
+}
+
+// contains reports whether the point is inside the boundary's polygons.
+func (fb *facilityBoundary) contains(p Point2LL) bool {
+	in := false
+	for _, r := range fb.Rings {
+		for i, j := 0, len(r)-1; i < len(r); j, i = i, i+1 {
+			a, b := r[i], r[j]
+			if (a[1] > p[1]) != (b[1] > p[1]) && p[0] < a[0]+(p[1]-a[1])*(b[0]-a[0])/(b[1]-a[1]) {
+				in = !in
+			}
+		}
+	}
+	return in
+}
+
+// nmSegmentDistance returns the approximate distance in nautical miles
+// from p to the segment from a to b, treating the area around p as
+// flat.
+func nmSegmentDistance(p, a, b Point2LL) float32 {
+	nmPerLon := 60 * float32(math.Cos(radians(p[1])))
+	ax, ay := (a[0]-p[0])*nmPerLon, (a[1]-p[1])*60
+	bx, by := (b[0]-p[0])*nmPerLon, (b[1]-p[1])*60
+
+	dx, dy := bx-ax, by-ay
+	t := float32(0)
+	if l2 := dx*dx + dy*dy; l2 > 0 {
+		t = min(1, max(0, -(ax*dx+ay*dy)/l2))
+	}
+	x, y := ax+t*dx, ay+t*dy
+	return float32(math.Sqrt(float64(x*x + y*y)))
+}
+
+// checkBoundary reports the map if a significant fraction of its
+// vertices are far outside of the boundary.
+func checkBoundary(m STARSMap) {
+	if boundary == nil {
+		return
+	}
+
+	var n, outside int
+	var farthest float32
+	check := func(p Point2LL) {
+		n++
+		if d := boundary.outside(p); d > 0 {
+			outside++
+			farthest = max(farthest, d)
+		}
+	}
+	for _, l := range m.Lines {
+		for _, p := range l {
+			check(p)
+		}
+	}
+	for _, l := range m.Labels {
+		check(l.P)
+	}
+
+	if outside > 0 && float32(outside) >= outsideFraction*float32(n) {
+		what := fmt.Sprintf("more than %d nm from the ARTCC's visibility centers", centerRadius)
+		if len(boundary.Rings) > 0 {
+			what = fmt.Sprintf("more than %d nm outside of the boundary", boundaryMargin)
+		}
+		report(finding{Severity: "warning", Rule: "outside-boundary", Map: m.Name, Feature: -1, Vertex: -1,
+			Message: fmt.Sprintf("%d of %d vertices are %s, by up to %.0f nm; check its projection and coordinate order",
+				outside, n, what, farthest)})
+	}
+}
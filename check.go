@@ -0,0 +1,154 @@
+// check.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// check
+
+// checkARTCC converts the maps of the given ARTCC (or facility) without
+// writing anything and compares the result to the video map and manifest
+// files that were written previously, e.g. those committed in a facility
+// repository. It exits with an error if they differ, listing the maps
+// that have changed, so that CI can ensure that the files are kept up to
+// date with their sources.
+func checkARTCC(base string) {
+	if *perMap || *splitCategories || *maxSize != "" || *output != "" || *encoding != "gob" {
+		fmt.Fprintf(os.Stderr, "crctovice: -per-map, -split-categories, -max-size, -output, and -encoding can't be used with check\n")
+		os.Exit(1)
+	}
+
+	_, base, maps, _ := readARTCCMaps(base)
+	fn := facilityOutputBase(base)
+	vmaps := versionedMaps(prepareMaps(maps))
+
+	sums := make(map[string]string)
+	for _, m := range vmaps {
+		sums[m.Name] = mapChecksum(m)
+	}
+
+	var problems []string
+	gobfn := fn + "-videomaps" + outputExt()
+	if *zstdGOB {
+		gobfn += ".zst"
+	}
+	if old, err := readVideoMapGOB(gobfn); err != nil {
+		problems = append(problems, fmt.Sprintf("%s: %v", gobfn, err))
+	} else {
+		oldSums := make(map[string]string)
+		for _, m := range old {
+			oldSums[m.Name] = mapChecksum(m)
+		}
+		if diff := diffChecksums(oldSums, sums); len(diff) > 0 {
+			problems = append(problems, gobfn+":")
+			problems = append(problems, diff...)
+		} else if b, err := encodeValue(storedMaps(vmaps)); err == nil && !fileMatches(gobfn, b) {
+			// The maps are the same but something else, like their order,
+			// isn't.
+			problems = append(problems, gobfn+": maps are the same but the file differs")
+		}
+	}
+
+	if *manifest == "gob" || *manifest == "both" {
+		mfn := fn + "-manifest" + outputExt()
+		var names map[string]interface{}
+		if b, err := os.ReadFile(mfn); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", mfn, err))
+		} else if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&names); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", mfn, err))
+		} else {
+			oldSums := make(map[string]string)
+			for name, v := range names {
+				oldSums[name], _ = v.(string)
+			}
+			newSums := sums
+			if formatVersion < 2 { // no checksums; just the names
+				newSums = make(map[string]string)
+				for name := range sums {
+					newSums[name] = ""
+				}
+			}
+			if diff := diffChecksums(oldSums, newSums); len(diff) > 0 {
+				problems = append(problems, mfn+":")
+				problems = append(problems, diff...)
+			}
+		}
+	}
+	if *manifest == "json" || *manifest == "both" {
+		// Only the maps are compared, since the provenance records when
+		// the file was written.
+		mfn := fn + "-manifest.json"
+		var m jsonManifest
+		if b, err := os.ReadFile(mfn); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", mfn, err))
+		} else if err := json.Unmarshal(b, &m); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", mfn, err))
+		} else {
+			oldSums := make(map[string]string)
+			for name, mm := range m.Maps {
+				oldSums[name] = mm.SHA256
+			}
+			if diff := diffChecksums(oldSums, sums); len(diff) > 0 {
+				problems = append(problems, mfn+":")
+				problems = append(problems, diff...)
+			}
+		}
+	}
+
+	finishRun()
+	if len(problems) > 0 {
+		fmt.Printf("Output is out of date; rerun crc2vice to update it.\n")
+		for _, p := range problems {
+			fmt.Printf("%s\n", p)
+		}
+		os.Exit(1)
+	}
+	fmt.Printf("%s is up to date.\n", gobfn)
+}
+
+// diffChecksums returns descriptions of the maps that were added,
+// removed, or changed between the two sets of map checksums.
+func diffChecksums(old, cur map[string]string) []string {
+	var diff []string
+	for name, sum := range cur {
+		if oldSum, ok := old[name]; !ok {
+			diff = append(diff, fmt.Sprintf("\tadded: %s", name))
+		} else if oldSum != sum {
+			diff = append(diff, fmt.Sprintf("\tchanged: %s", name))
+		}
+	}
+	for name := range old {
+		if _, ok := cur[name]; !ok {
+			diff = append(diff, fmt.Sprintf("\tremoved: %s", name))
+		}
+	}
+	sort.Strings(diff)
+	return diff
+}
+
+// fileMatches reports whether the named file holds the encoded data b
+// (compressed, if it's a .zst file).
+func fileMatches(fn string, b []byte) bool {
+	old, err := os.ReadFile(fn)
+	if err != nil {
+		return false
+	}
+	if len(old) >= 4 && binary.LittleEndian.Uint32(old) == zstdMagic {
+		if old, err = zstdDecompress(old); err != nil {
+			return false
+		}
+	}
+	return slices.Equal(old, b)
+}
@@ -0,0 +1,315 @@
+// clip.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// Clipping
+//
+// Some maps cover far more area than is needed (e.g., half the country
+// for a single TRACON), so lines can be clipped to a bounding box or to
+// the polygons of a GeoJSON file with -clip, and to a distance from an
+// airport or point with -clip-radius. Labels outside of the region are
+// removed. Clipping is done in longitude-latitude space (or, for
+// -clip-radius, a flat projection centered at the point), which is fine
+// for regions of the size of a facility.
+
+// clipper is a region that maps are clipped to.
+type clipper struct {
+	desc   string   // for messages
+	lo, hi Point2LL // bounds
+	inside func(p Point2LL) bool
+	// crossings returns the parametric positions along the segment from
+	// a to b where it crosses the region's edge.
+	crossings func(a, b Point2LL) []float64
+}
+
+// clipRegion is the region given by -clip, if any.
+var clipRegion *facilityBoundary
+
+// parseClip parses the argument to -clip: either a bounding box given as
+// "minlon,minlat,maxlon,maxlat" or a GeoJSON file with polygons.
+func parseClip(s string) (*facilityBoundary, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if f := strings.Split(s, ","); len(f) == 4 {
+		var v [4]float32
+		for i := range f {
+			n, err := strconv.ParseFloat(strings.TrimSpace(f[i]), 32)
+			if err != nil {
+				return nil, fmt.Errorf("%q: invalid bounding box", s)
+			}
+			v[i] = float32(n)
+		}
+		if v[0] >= v[2] || v[1] >= v[3] {
+			return nil, fmt.Errorf("%q: bounding box must be given as minlon,minlat,maxlon,maxlat", s)
+		}
+		return &facilityBoundary{Rings: [][]Point2LL{{{v[0], v[1]}, {v[2], v[1]}, {v[2], v[3]}, {v[0], v[3]}}}}, nil
+	}
+	return readBoundary(s)
+}
+
+// regionClipper returns a clipper for the polygons of the region.
+func regionClipper(region *facilityBoundary) clipper {
+	c := clipper{
+		desc:   *clipArg,
+		lo:     Point2LL{math.MaxFloat32, math.MaxFloat32},
+		hi:     Point2LL{-math.MaxFloat32, -math.MaxFloat32},
+		inside: region.contains,
+		crossings: func(a, b Point2LL) []float64 {
+			var ts []float64
+			for _, r := range region.Rings {
+				for j, k := 0, len(r)-1; j < len(r); k, j = j, j+1 {
+					if t, ok := segmentIntersection(a, b, r[k], r[j]); ok {
+						ts = append(ts, t)
+					}
+				}
+			}
+			return ts
+		},
+	}
+	for _, r := range region.Rings {
+		for _, p := range r {
+			c.lo = Point2LL{min(c.lo[0], p[0]), min(c.lo[1], p[1])}
+			c.hi = Point2LL{max(c.hi[0], p[0]), max(c.hi[1], p[1])}
+		}
+	}
+	return c
+}
+
+// clipCircle is the circle given by -clip-radius, if any. Its center is
+// either given as a point or is the location of the tower of the given
+// facility, which is found once the ARTCC definition has been read.
+var clipCircle *circle
+
+type circle struct {
+	Center     Point2LL
+	FacilityId string  // if the center hasn't been found yet
+	Radius     float32 // nm
+}
+
+// parseClipRadius parses the argument to -clip-radius, which is of the
+// form "JFK:60nm" or "40.64,-73.78:60nm" (latitude, longitude).
+func parseClipRadius(s string) error {
+	if s == "" {
+		return nil
+	}
+	i := strings.LastIndex(s, ":")
+	if i == -1 {
+		return fmt.Errorf("%q: must be given as FACILITY:RADIUSnm or LAT,LON:RADIUSnm", s)
+	}
+	where, radius := s[:i], s[i+1:]
+
+	r, err := parseNM(radius)
+	if err != nil {
+		return err
+	}
+	clipCircle = &circle{Radius: r}
+
+	if f := strings.Split(where, ","); len(f) == 2 {
+		lat, laterr := strconv.ParseFloat(strings.TrimSpace(f[0]), 32)
+		lon, lonerr := strconv.ParseFloat(strings.TrimSpace(f[1]), 32)
+		if laterr != nil || lonerr != nil || math.Abs(lat) > 90 || math.Abs(lon) > 180 {
+			return fmt.Errorf("%q: invalid latitude and longitude", where)
+		}
+		clipCircle.Center = Point2LL{float32(lon), float32(lat)}
+	} else {
+		clipCircle.FacilityId = where
+	}
+	return nil
+}
+
+// parseNM parses a distance in nautical miles, given with an "nm" suffix.
+func parseNM(s string) (float32, error) {
+	d, ok := strings.CutSuffix(strings.ToLower(s), "nm")
+	v, err := strconv.ParseFloat(strings.TrimSpace(d), 32)
+	if !ok || err != nil || v <= 0 || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("%q: distance must be given in nautical miles (e.g., 60nm)", s)
+	}
+	return float32(v), nil
+}
+
+// findClipCenter finds the location of the -clip-radius facility's tower
+// in the ARTCC definition. ICAO airport codes are accepted for US
+// airports, whose facilities CRC names using their FAA ids.
+func findClipCenter(artcc ARTCC, fn string) {
+	if clipCircle == nil || clipCircle.FacilityId == "" {
+		return
+	}
+	id := clipCircle.FacilityId
+	fac := artcc.Facility.Find(id)
+	if fac == nil && len(id) == 4 && strings.HasPrefix(strings.ToUpper(id), "K") {
+		fac = artcc.Facility.Find(id[1:])
+	}
+	if fac == nil {
+		fmt.Fprintf(os.Stderr, "%s: -clip-radius facility not found in %s. Available facilities: %s\n", id, fn,
+			strings.Join(artcc.Facility.FacilityIds(), ", "))
+		os.Exit(1)
+	}
+	if tc := fac.TowerCabConfiguration; tc == nil || tc.TowerLocation == nil {
+		fmt.Fprintf(os.Stderr, "%s: -clip-radius facility has no tower location; give the latitude and longitude instead\n", fac.Id)
+		os.Exit(1)
+	}
+	clipCircle.Center = fac.TowerCabConfiguration.TowerLocation.Point2LL()
+	clipCircle.FacilityId = ""
+}
+
+// circleClipper returns a clipper for -clip-radius's circle.
+func circleClipper() clipper {
+	if clipCircle.FacilityId != "" {
+		fmt.Fprintf(os.Stderr, "%s: -clip-radius can only be given using a facility when converting an ARTCC\n",
+			clipCircle.FacilityId)
+		os.Exit(1)
+	}
+
+	ctr, r := clipCircle.Center, clipCircle.Radius
+	nmPerLon := 60 * float32(math.Cos(radians(ctr[1])))
+	xy := func(p Point2LL) (float64, float64) {
+		return float64((p[0] - ctr[0]) * nmPerLon), float64((p[1] - ctr[1]) * 60)
+	}
+	return clipper{
+		desc: *clipRadius,
+		lo:   Point2LL{ctr[0] - r/nmPerLon, ctr[1] - r/60},
+		hi:   Point2LL{ctr[0] + r/nmPerLon, ctr[1] + r/60},
+		inside: func(p Point2LL) bool {
+			x, y := xy(p)
+			return x*x+y*y <= float64(r*r)
+		},
+		crossings: func(a, b Point2LL) []float64 {
+			// Solve |a + t (b-a)|^2 = r^2 for t.
+			ax, ay := xy(a)
+			bx, by := xy(b)
+			dx, dy := bx-ax, by-ay
+			qa, qb, qc := dx*dx+dy*dy, 2*(ax*dx+ay*dy), ax*ax+ay*ay-float64(r*r)
+			disc := qb*qb - 4*qa*qc
+			if qa == 0 || disc <= 0 {
+				return nil
+			}
+			var ts []float64
+			for _, t := range []float64{(-qb - math.Sqrt(disc)) / (2 * qa), (-qb + math.Sqrt(disc)) / (2 * qa)} {
+				if t > 0 && t < 1 {
+					ts = append(ts, t)
+				}
+			}
+			return ts
+		},
+	}
+}
+
+// clipLine returns the parts of the line that are inside the region.
+func clipLine(l []Point2LL, c clipper) [][]Point2LL {
+	var pieces [][]Point2LL
+	var cur []Point2LL
+	end := func() {
+		if len(cur) >= 2 {
+			pieces = append(pieces, cur)
+		}
+		cur = nil
+	}
+
+	for i := 1; i < len(l); i++ {
+		a, b := l[i-1], l[i]
+		if max(a[0], b[0]) < c.lo[0] || min(a[0], b[0]) > c.hi[0] || max(a[1], b[1]) < c.lo[1] || min(a[1], b[1]) > c.hi[1] {
+			end() // entirely outside of the region's bounds
+			continue
+		}
+
+		// Split the segment where it crosses the region's edge and keep
+		// the parts whose midpoints are inside.
+		ts := append([]float64{0, 1}, c.crossings(a, b)...)
+		slices.Sort(ts)
+
+		at := func(t float64) Point2LL {
+			// The endpoints are returned exactly so that pieces continue
+			// across vertices.
+			if t == 0 {
+				return a
+			} else if t == 1 {
+				return b
+			}
+			return Point2LL{a[0] + float32(t)*(b[0]-a[0]), a[1] + float32(t)*(b[1]-a[1])}
+		}
+		for j := 1; j < len(ts); j++ {
+			t0, t1 := ts[j-1], ts[j]
+			if t1 == t0 {
+				continue
+			}
+			if !c.inside(at((t0 + t1) / 2)) {
+				end()
+				continue
+			}
+			if p := at(t0); len(cur) == 0 || cur[len(cur)-1] != p {
+				end()
+				cur = []Point2LL{p}
+			}
+			cur = append(cur, at(t1))
+		}
+	}
+	end()
+	return pieces
+}
+
+// segmentIntersection returns the parametric position along the segment
+// from a to b at which it crosses the segment from c to d, if it does.
+func segmentIntersection(a, b, c, d Point2LL) (float64, bool) {
+	rx, ry := float64(b[0]-a[0]), float64(b[1]-a[1])
+	sx, sy := float64(d[0]-c[0]), float64(d[1]-c[1])
+	den := rx*sy - ry*sx
+	if den == 0 { // parallel
+		return 0, false
+	}
+	qx, qy := float64(c[0]-a[0]), float64(c[1]-a[1])
+	t, u := (qx*sy-qy*sx)/den, (qx*ry-qy*rx)/den
+	return t, t > 0 && t < 1 && u >= 0 && u <= 1
+}
+
+// clipMaps returns the maps clipped to -clip's region and -clip-radius's
+// circle.
+func clipMaps(maps []STARSMap) []STARSMap {
+	var clippers []clipper
+	if clipRegion != nil {
+		clippers = append(clippers, regionClipper(clipRegion))
+	}
+	if clipCircle != nil {
+		clippers = append(clippers, circleClipper())
+	}
+
+	for _, c := range clippers {
+		maps = slices.Clone(maps)
+		var before, after, emptied int
+		for i := range maps {
+			var lines [][]Point2LL
+			for _, l := range maps[i].Lines {
+				lines = append(lines, clipLine(l, c)...)
+			}
+			labels := slices.DeleteFunc(slices.Clone(maps[i].Labels), func(l STARSMapLabel) bool {
+				return !c.inside(l.P)
+			})
+
+			before += countVertices(maps[i].Lines)
+			after += countVertices(lines)
+			if !isEmptyMap(maps[i]) && len(lines) == 0 && len(labels) == 0 {
+				emptied++
+			}
+			maps[i].Lines, maps[i].Labels = lines, labels
+		}
+
+		fmt.Printf("Clipped lines to %s: %d vertices to %d", c.desc, before, after)
+		if emptied > 0 {
+			fmt.Printf("; %d maps are now empty", emptied)
+		}
+		fmt.Printf("\n")
+	}
+	return maps
+}
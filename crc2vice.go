@@ -5,66 +5,272 @@
 package main
 
 import (
-	"encoding/gob"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"io/fs"
+	"math"
 	"os"
 	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
+var (
+	source     = flag.String("source", ".", "directory or .zip archive with the CRC ARTCCs/ and VideoMaps/ folders")
+	remote     = flag.Bool("remote", false, "download the ARTCC definition and video maps from the vNAS data API")
+	gitRepo    = flag.String("git", "", "fetch the ARTCC definition and video maps from the given Git repository (with -source giving a subdirectory)")
+	gitRef     = flag.String("git-ref", "HEAD", "branch, tag, or commit of the -git repository to use")
+	gitHub     = flag.String("github", "", "download the ARTCC definition and video maps from the .zip attached to a GitHub release (OWNER/REPO for the latest, or OWNER/REPO@TAG)")
+	gitHubAPI  = flag.String("github-api", "https://api.github.com", "base URL of the GitHub API")
+	vnasURL    = flag.String("vnas-url", "https://data-api.vnas.vatsim.net", "base URL of the vNAS data API")
+	strict     = flag.Bool("strict", false, "exit with an error rather than writing output if any errors or duplicate map names, labels, or ids are found")
+	autoSwap   = flag.Bool("autoswap", true, "detect GeoJSON files with [lat, lon] coordinate ordering and swap them")
+	coordOrder = flag.String("coord-order", "auto", "coordinate ordering in GeoJSON files: \"lonlat\", \"latlon\", or \"auto\" to detect it")
+	facilityId = flag.String("facility", "", "only convert the video maps used by the given facility's STARS configuration (e.g., N90)")
+	towerCab   = flag.Bool("tower", false, "also convert the tower cab video maps, writing them to <base>-tower-videomaps.gob and <base>-tower-manifest.gob")
+	asdex      = flag.Bool("asdex", false, "also convert the ASDE-X surface maps, writing them to <base>-asdex.gob")
+	eram       = flag.Bool("eram", false, "also convert the ERAM GeoMaps, writing them to <base>-erammaps.gob")
+	skipEmpty  = flag.Bool("skip-empty", false, "leave out maps that don't have any lines or labels after conversion")
+	boundaryFn = flag.String("boundary", "", "GeoJSON file with the facility's boundary polygon, for reporting maps that are far outside of it (by default, the ARTCC's visibility centers are used)")
+	sarifOut   = flag.String("sarif", "", "write the problems found with the maps to the given file as a SARIF log, e.g. for GitHub code scanning")
+	annotate   = flag.Bool("github-annotations", false, "print the problems found with the maps as GitHub Actions workflow commands, so that they're shown with the files they're in")
+	fixLabels  = flag.Bool("fix-labels", false, "make DCB labels that are too long or use characters STARS can't display fit, keeping them distinct")
+	includeTDM = flag.Bool("tdm", false, "include TDM-only video maps in the output")
+	defaultCRS = flag.String("crs", "", "coordinate reference system of GeoJSON files with projected coordinates but no \"crs\" member (e.g., EPSG:32618)")
+
+	datReference = flag.String("dat-ref", "", "reference point (\"latitude,longitude\") for the offsets in .dat and .map video map files")
+	datScale     = flag.Float64("dat-scale", 1, "nautical miles per unit in .dat and .map video map files")
+
+	shpNameField = flag.String("shp-name-field", "", "attribute in shapefiles' .dbf files used to group shapes into named maps")
+
+	outName         = flag.String("name", "", "base name for the output files, instead of the ARTCC or facility name (or OUTNAME)")
+	outPrefix       = flag.String("prefix", "", "prefix to add to the base name of the output files (e.g., \"test-\" for test-ZNY-videomaps.gob)")
+	output          = flag.String("output", "", "file to write the video maps to instead of <base>-videomaps.gob (no manifest is written), or \"-\" for standard output")
+	zstdGOB         = flag.Bool("zstd", false, "compress the video maps with zstd, writing <base>-videomaps.gob.zst")
+	splitCategories = flag.Bool("split-categories", false, "write category A and B maps to separate files, <base>-A-videomaps.gob and <base>-B-videomaps.gob, each with its own manifest")
+	signKey         = flag.String("sign", "", "Ed25519 private key file (see keygen) with which to sign the video map and manifest files, writing each one's signature to <file>.sig")
+	airacFlag       = flag.String("airac", "", "AIRAC cycle of the maps (e.g., 2410) or a date (YYYY-MM-DD) in it, recorded in the output; by default, it's found from the ARTCC definition's last update")
+	maxSize         = flag.String("max-size", "", "largest video map file to write (e.g., 64M); if the maps are larger, they are split into <base>-videomaps-1.gob, <base>-videomaps-2.gob, and so forth, with <base>-index.gob recording which file each map is in")
+	perMap          = flag.Bool("per-map", false, "write each video map to its own file in a <base>-videomaps/ directory, along with an index")
+	stateFile       = flag.String("state", "", "file in which to record the maps' checksums after each conversion, reporting which maps were added, removed, or changed since the last one")
+	simplifyTol     = flag.String("simplify-tolerance", "", "simplify lines, removing vertices within the given distance (e.g., 10m or 0.0001deg) of the simplified line")
+	maxVertices     = flag.Int("max-vertices-per-map", 0, "simplify the lines of maps with more than this many vertices until they fit")
+	densify         = flag.String("densify", "", "add vertices along great circles so that line segments are no longer than the given distance (e.g., 10nm)")
+	lodArg          = flag.String("lod", "", "also store each map simplified with the given tolerances (e.g., 25m,100m,500m) so that vice can draw coarser versions when zoomed out")
+	sortLinesFlag   = flag.Bool("sort-lines", false, "sort each map's lines along a Hilbert curve so that lines near each other are stored together")
+	precisionArg    = flag.String("precision", "", "round coordinates to the given precision (e.g., 1m or 0.00001deg), removing vertices that then coincide")
+	clipArg         = flag.String("clip", "", "only convert geometry within the given bounding box (minlon,minlat,maxlon,maxlat) or the polygons of the given GeoJSON file")
+	clipRadius      = flag.String("clip-radius", "", "only convert geometry within the given distance of a facility's tower or a point (e.g., JFK:60nm or 40.64,-73.78:60nm)")
+	snapArg         = flag.String("snap", "", "move vertices within the given distance (e.g., 0.5m or 0.000005deg) of each other in a map to the same location")
+	joinArg         = flag.String("join-lines", "", "join lines whose endpoints are within the given distance (e.g., 1m or 0.00001deg) into longer ones")
+	dedup           = flag.Bool("dedup-lines", false, "store lines that are in multiple maps only once, which requires a vice release that supports shared lines")
+	maxInput        = flag.String("max-input-size", "256M", "largest input file to read (e.g., 64M); 0 for no limit")
+	maxFeatures     = flag.Int("max-features", 1000000, "most features to allow in a GeoJSON file; 0 for no limit")
+	maxCoords       = flag.Int("max-coords", 1000000, "most coordinates to allow in a LineString or polygon ring; 0 for no limit")
+	verifyOutput    = flag.Bool("verify-output", false, "read the video map and manifest files back after writing them and check that they match what was intended")
+	backup          = flag.Bool("backup", false, "before overwriting a video map or manifest file, copy it to <file>.<modification time>.bak")
+	install         = flag.Bool("install", false, "write the output files to vice's resources/videomaps directory")
+	targetVice      = flag.String("target-vice-version", "", "write video maps that the given version of vice (e.g., 0.9.3) can read")
+	formatFlag      = flag.Int("format-version", 0, "video map format version to write: 1, or 2 for newer fields and compression (default 1, or 2 if -zstd, -dedup-lines, or -lod is given)")
+	encoding        = flag.String("encoding", "gob", "encoding of the video map and manifest files: \"gob\", which vice reads, or \"cbor\" or \"msgpack\", which replace the .gob extension")
+	manifest        = flag.String("manifest", "gob", "manifest format: \"gob\" (<base>-manifest.gob), \"json\" (<base>-manifest.json), or \"both\"")
+	svgPreviews     = flag.Bool("svg-previews", false, "also write an SVG preview of each map to the <base>-previews/ directory")
+	pngPreviews     = flag.Bool("png-previews", false, "also write a PNG preview of each map, drawn like a STARS scope, to the <base>-previews/ directory")
+	thumbnails      = flag.Bool("thumbnails", false, "also write <base>-previews/index.html, a page with a thumbnail of each map labeled with its name, category, and id")
+	previewSize     = flag.Int("preview-size", 1024, "size in pixels of the longer side of preview images")
+	vSTARSXML       = flag.Bool("vstars", false, "also write the maps in vSTARS video map XML format to <base>-vstars.xml")
+	protobufOut     = flag.Bool("protobuf", false, "also write the maps as a protocol buffer (see videomaps.proto) to <base>-videomaps.pb")
+	topoJSONOut     = flag.Bool("topojson", false, "also write the maps to <base>.topojson, storing shared boundaries once")
+	geoJSONL        = flag.Bool("geojsonl", false, "also write every feature to <base>.geojsonl as newline-delimited GeoJSON, with its map's name, label, category, and id as properties")
+	viceConfig      = flag.Bool("vice-config", false, "also write <base>-vice.json, the video map settings for a vice scenario's \"stars_config\"")
+	csvSummary      = flag.Bool("csv", false, "also write <base>-summary.csv, listing each map's name, label, category, id, size, and bounding box")
+	htmlViewer      = flag.Bool("html", false, "also write <base>.html, a web page for viewing the maps over a basemap")
+	kmlFormat       = flag.String("kml", "", "also write the maps to <base>.kml or <base>.kmz for viewing in Google Earth: \"kml\" or \"kmz\"")
+
+	includeTags, excludeTags stringList
+	gpkgLayers               stringList
+	mapDirs                  stringList
+	mapGroups                stringList
+
+	// The original standard output, in case os.Stdout is redirected.
+	stdout = os.Stdout
+)
+
+func init() {
+	flag.Var(&includeTags, "tag", "only convert maps with the given CRC tag (may be repeated)")
+	flag.Var(&excludeTags, "exclude-tag", "don't convert maps with the given CRC tag (may be repeated)")
+	flag.Var(&gpkgLayers, "gpkg-layer", "only convert the named layer from GeoPackage files (may be repeated)")
+	flag.Var(&mapGroups, "map-group", "only convert maps in the given CRC map group, specified by id or, if no group has that id, 1-based index (may be repeated)")
+	flag.Var(&mapDirs, "map-dir", "additional directory to search (including subdirectories) for video map GeoJSON files (may be repeated)")
+}
+
 ///////////////////////////////////////////////////////////////////////////
 // Type definitions for GeoJSON / CRC config parsing
 
 type ARTCC struct {
-	VideoMaps []VideoMapSpec `json:"videoMaps"`
+	LastUpdatedAt     string         `json:"lastUpdatedAt"`
+	Facility          CRCFacility    `json:"facility"`
+	VideoMaps         []VideoMapSpec `json:"videoMaps"`
+	VisibilityCenters []CRCLatLon    `json:"visibilityCenters"`
+}
+
+type CRCLatLon struct {
+	Lat float32 `json:"lat"`
+	Lon float32 `json:"lon"`
+}
+
+func (ll CRCLatLon) Point2LL() Point2LL {
+	return Point2LL{ll.Lon, ll.Lat}
 }
 
 type VideoMapSpec struct {
-	Id        string `json:"id"`                      // corresponds to GeoJSON filename
-	Name      string `json:"name"`                    // full name; will use for identification in scenarios
-	ShortName string `json:"shortName"`               // for use in DCB menu
-	Category  string `json:"starsBrightnessCategory"` // "A" or "B"
-	STARSId   int    `json:"starsId"`                 // not yet used
+	Id             string   `json:"id"`                      // corresponds to GeoJSON filename
+	Name           string   `json:"name"`                    // full name; will use for identification in scenarios
+	ShortName      string   `json:"shortName"`               // for use in DCB menu
+	Category       string   `json:"starsBrightnessCategory"` // "A" or "B"
+	STARSId        int      `json:"starsId"`                 // not yet used
+	TDMOnly        bool     `json:"tdmOnly"`
+	AlwaysVisible  bool     `json:"starsAlwaysVisible"` // displayed regardless of DCB selections
+	Tags           []string `json:"tags"`
+	SourceFileName string   `json:"sourceFileName"` // original filename; helps find renamed GeoJSON
+
+	// Not part of CRC's format; may be added by hand to override the
+	// -coord-order command-line option for individual maps.
+	CoordOrder string `json:"coordOrder"`
+	// Also not part of CRC's format: an http(s) URL to download the
+	// GeoJSON from. (The Id may also be a URL.)
+	URL string `json:"url"`
 }
 
 type GeoJSON struct {
 	Type     string           `json:"type"`
+	CRS      *GeoJSONCRS      `json:"crs"` // obsolete but still found in the wild
 	Features []GeoJSONFeature `json:"features"`
 }
 
 type GeoJSONFeature struct {
-	Type     string `json:"type"`
-	Geometry struct {
-		Type        string             `json:"type"`
-		Coordinates GeoJSONCoordinates `json:"coordinates"`
-	} `json:"geometry"`
+	Type       string                 `json:"type"`
+	Geometry   GeoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+
+	index int // in the file's features, for messages
+	line  int // where the feature starts in the file, if known
+}
+
+type GeoJSONGeometry struct {
+	Type        string
+	Coordinates GeoJSONCoordinates // LineString
+	Rings       [][]Point2LL       // Polygon: the exterior ring then any holes
+
+	// err records why the coordinates couldn't be decoded; the rest of
+	// the file is still used, but the feature is reported as skipped.
+	err error
+}
+
+func (g *GeoJSONGeometry) UnmarshalJSON(d []byte) error {
+	var raw struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	}
+	if err := json.Unmarshal(d, &raw); err != nil {
+		return err
+	}
+
+	*g = GeoJSONGeometry{Type: raw.Type}
+	if len(raw.Coordinates) == 0 {
+		return nil
+	}
+	switch raw.Type {
+	case "LineString":
+		if g.err = json.Unmarshal(raw.Coordinates, &g.Coordinates); g.err == nil {
+			g.err = checkCoordinateCount(len(g.Coordinates))
+		}
+		if g.err != nil {
+			g.Coordinates = nil
+		}
+	case "Polygon":
+		g.err = json.Unmarshal(raw.Coordinates, &g.Rings)
+		for _, r := range g.Rings {
+			if g.err == nil {
+				g.err = checkCoordinateCount(len(r))
+			}
+		}
+		if g.err != nil {
+			g.Rings = nil
+		}
+	}
+	return nil
+}
+
+// NumberProperty returns the value of the named numeric property, if
+// the feature has it.
+func (f GeoJSONFeature) NumberProperty(name string) (float64, bool) {
+	v, ok := f.Properties[name].(float64)
+	return v, ok
+}
+
+// ZIndex returns the feature's draw order; features with higher values
+// are drawn on top of ones with lower values.
+func (f GeoJSONFeature) ZIndex() int {
+	z, _ := f.NumberProperty("zIndex")
+	return int(z)
 }
 
 // We only extract lines (at the moment at least) and so we only worry
 // about [][2]float32s for coordinates. (For points, this would be
 // a single [2]float32 and for polygons, it would be [][][2]float32...)
+// Errors are returned so that GeoJSONGeometry can record them.
 type GeoJSONCoordinates []Point2LL
 
 func (c *GeoJSONCoordinates) UnmarshalJSON(d []byte) error {
 	*c = nil
 
 	var coords []Point2LL
-	if err := json.Unmarshal(d, &coords); err == nil {
-		*c = coords
+	if err := json.Unmarshal(d, &coords); err != nil {
+		return err
 	}
-	// Don't report any errors but assume that it's a point, polygon, ...
+	*c = coords
 	return nil
 }
 
 ///////////////////////////////////////////////////////////////////////////
 
-// Note: this should match STARSMap in stars.go
+// Note: this should match STARSMap in stars.go. (Fields that vice doesn't
+// know about are ignored when it decodes the GOB file.)
 type STARSMap struct {
-	Group int
-	Label string
-	Name  string
-	Id    int
-	Lines [][]Point2LL
+	Group         int
+	Label         string
+	Name          string
+	Id            int
+	Lines         [][]Point2LL // sorted in drawing order
+	AlwaysVisible bool
+	Order         int             // index of the map in the CRC ARTCC definition
+	Labels        []STARSMapLabel // text annotations, e.g. MVA altitudes
+	MapGroups     []STARSMapGroup // CRC DCB map groups that include the map
+	FormatVersion int             // zero for version 1; see format.go
+	Bounds        []Point2LL      // {min, max} longitude-latitude; empty if the map has no lines or labels
+	AIRAC         string          // cycle of the source data (e.g., "2410"), if known; see airac.go
+	EffectiveDate string          // start of the AIRAC cycle, YYYY-MM-DD
+	SharedLines   []STARSLineRef  // lines stored with other maps; see dedup.go
+	LODs          []STARSMapLOD   // simplified versions of Lines, from finest to coarsest; see lod.go
+}
+
+type STARSMapGroup struct {
+	Id       string   `json:"id"`
+	Position int      `json:"position"`       // index of the map's button in the group
+	TCPs     []string `json:"tcps,omitempty"` // positions that use the group
+}
+
+type STARSMapLabel struct {
+	P    Point2LL `json:"p"`
+	Text string   `json:"text"`
 }
 
 ///////////////////////////////////////////////////////////////////////////
@@ -79,31 +285,460 @@ func errorExit(msg string, err error) {
 }
 
 func write(maps []STARSMap, fn string) {
-	// Write the GOB file with everything
-	gfn := fn + "-videomaps.gob"
-	fmt.Printf("Writing %s... ", gfn)
-	gf, err := os.Create(gfn)
-	errorExit("creating file", err)
-	defer gf.Close()
-	err = gob.NewEncoder(gf).Encode(maps)
-	errorExit("GOB error", err)
-
-	// Write the manifest file (without the lines)
-	names := make(map[string]interface{})
-	for _, m := range maps {
-		names[m.Name] = nil
-	}
-	mfn := fn + "-manifest.gob"
-	fmt.Printf("Writing %s... ", mfn)
-	mf, err := os.Create(mfn)
-	errorExit("creating file", err)
-	defer mf.Close()
-	err = gob.NewEncoder(mf).Encode(names)
-	errorExit("GOB error", err)
+	if *perMap {
+		writePerMap(maps, fn)
+		writeExports(maps, fn)
+		fmt.Printf("Done.\n")
+		return
+	}
 
+	if *splitCategories {
+		// Each category gets its own pair of files, named as if it were
+		// a separate facility (e.g., ZNY-A-videomaps.gob).
+		for _, category := range []string{"A", "B"} {
+			var cmaps []STARSMap
+			for _, m := range maps {
+				if groupCategory(m.Group) == category {
+					cmaps = append(cmaps, m)
+				}
+			}
+			if len(cmaps) > 0 {
+				writeVideoMaps(cmaps, fn+"-"+category)
+			}
+		}
+	} else {
+		writeVideoMaps(maps, fn)
+	}
+
+	writeExports(maps, fn)
 	fmt.Printf("Done.\n")
 }
 
+// writeVideoMaps writes the maps and their manifest, naming the files
+// after fn.
+func writeVideoMaps(maps []STARSMap, fn string) {
+	ext := outputExt()
+	if *zstdGOB {
+		ext += ".zst"
+	}
+	vmaps := versionedMaps(maps)
+
+	// Write the video map file, or with -max-size, possibly several of
+	// them along with an index of which maps are in each.
+	var files []jsonManifestFile
+	shards := shardMaps(vmaps)
+	if len(shards) == 1 {
+		gobfn := fn + "-videomaps" + ext
+		files = append(files, jsonManifestFile{File: filepath.Base(gobfn), SHA256: writeEncoded(storedMaps(vmaps), gobfn)})
+		if *viceConfig {
+			writeViceConfig(maps, filepath.Base(gobfn), fn+"-vice.json")
+		}
+	} else {
+		if *viceConfig {
+			fmt.Printf("\r%s: warning: vice can't load maps split across files; not writing -vice-config\n", fn)
+		}
+		index := make(map[string]string)
+		for i, shard := range shards {
+			gobfn := fmt.Sprintf("%s-videomaps-%d%s", fn, i+1, ext)
+			files = append(files, jsonManifestFile{File: filepath.Base(gobfn), SHA256: writeEncoded(storedMaps(shard), gobfn)})
+			for _, m := range shard {
+				index[m.Name] = filepath.Base(gobfn)
+			}
+		}
+		if *manifest == "gob" || *manifest == "both" {
+			writeEncoded(index, fn+"-index"+outputExt())
+		}
+		if *manifest == "json" || *manifest == "both" {
+			writeJSON(index, fn+"-index.json")
+		}
+	}
+
+	// Write the manifest file (without the lines). Each map's checksum
+	// is recorded so that mismatched or corrupt map files can be
+	// detected; vice only looks at the names in the GOB manifest, so
+	// they can be stored there as well.
+	sums := make(map[string]string)
+	for _, m := range vmaps {
+		sums[m.Name] = mapChecksum(m)
+	}
+	var gobManifestSum string
+	if *manifest == "gob" || *manifest == "both" {
+		names := make(map[string]interface{})
+		for name, sum := range sums {
+			if formatVersion >= 2 {
+				names[name] = sum
+			} else {
+				names[name] = nil
+			}
+		}
+		gobManifestSum = writeEncoded(names, fn+"-manifest"+outputExt())
+	}
+	if *manifest == "json" || *manifest == "both" {
+		// JSON objects are written with sorted keys, one per line, so
+		// that changes are easily seen in diffs.
+		m := jsonManifest{
+			ManifestVersion: jsonManifestVersion,
+			FormatVersion:   formatVersion,
+			SharedLines:     *dedup,
+			Maps:            make(map[string]jsonManifestMap),
+		}
+		if airac != nil {
+			m.AIRAC, m.EffectiveDate = airac.String(), airac.EffectiveDate()
+		}
+		prov := currentProvenance()
+		m.Provenance = &prov
+		for _, vm := range vmaps {
+			m.Maps[vm.Name] = newJSONManifestMap(vm, sums[vm.Name])
+		}
+		if len(files) == 1 {
+			m.VideoMaps = &files[0]
+		} else {
+			m.Shards = files
+		}
+		writeJSON(m, fn+"-manifest.json")
+	}
+
+	if *verifyOutput {
+		fmt.Printf("Verifying... ")
+		dir := filepath.Dir(fn)
+		for i, f := range files {
+			gobfn := filepath.Join(dir, f.File)
+			errorExit(gobfn, verifyVideoMapFile(gobfn, f.SHA256, shards[i]))
+		}
+		if *manifest == "gob" || *manifest == "both" {
+			mfn := fn + "-manifest" + outputExt()
+			errorExit(mfn, verifyGOBManifest(mfn, gobManifestSum, sums))
+		}
+		if *manifest == "json" || *manifest == "both" {
+			mfn := fn + "-manifest.json"
+			errorExit(mfn, verifyJSONManifest(mfn, files, sums))
+		}
+		fmt.Printf("Done.\n")
+	}
+}
+
+// jsonManifestVersion is the version of the JSON manifest's layout; it
+// should be incremented whenever that changes in a way that readers need
+// to know about. Manifests without a version are version 1, where maps'
+// entries were just their checksums. (The GOB manifest's layout is fixed
+// by vice, which takes every key to be a map name, so it isn't versioned
+// separately from the video map format.)
+const jsonManifestVersion = 2
+
+// jsonManifest is the manifest written with -manifest json. The checksums
+// are hex-encoded SHA-256 hashes; see mapChecksum for the maps'. When the
+// maps are split into multiple files with -max-size, those are listed in
+// Shards instead of VideoMaps.
+type jsonManifest struct {
+	ManifestVersion int                        `json:"manifestVersion"`
+	FormatVersion   int                        `json:"formatVersion"`
+	AIRAC           string                     `json:"airac,omitempty"`
+	EffectiveDate   string                     `json:"effectiveDate,omitempty"`
+	SharedLines     bool                       `json:"sharedLines,omitempty"` // see dedup.go
+	VideoMaps       *jsonManifestFile          `json:"videomaps,omitempty"`
+	Shards          []jsonManifestFile         `json:"shards,omitempty"`
+	Maps            map[string]jsonManifestMap `json:"maps"`
+	Provenance      *provenance                `json:"provenance,omitempty"`
+}
+
+type jsonManifestFile struct {
+	File   string `json:"file"`
+	SHA256 string `json:"sha256"`
+}
+
+// jsonManifestMap describes a map in the JSON manifest so that other
+// tools can decide which maps they need without reading the video map
+// file. (The GOB manifest only has the maps' checksums: vice decodes it
+// as a map[string]interface{}, which can't hold other types.)
+type jsonManifestMap struct {
+	Label    string     `json:"label"`
+	Category string     `json:"category"`
+	Id       int        `json:"id"`
+	Lines    int        `json:"lines"`
+	Labels   int        `json:"labels"`
+	Vertices int        `json:"vertices"`
+	Bounds   []Point2LL `json:"bounds,omitempty"` // [[min lon, min lat], [max lon, max lat]]
+	SHA256   string     `json:"sha256"`
+
+	Features *featureCounts    `json:"features,omitempty"` // of the map's GeoJSON file, if it had one
+	LODs     []jsonManifestLOD `json:"lods,omitempty"`
+}
+
+type jsonManifestLOD struct {
+	Tolerance float32 `json:"tolerance"` // meters
+	Vertices  int     `json:"vertices"`
+}
+
+func newJSONManifestMap(m STARSMap, sum string) jsonManifestMap {
+	mm := jsonManifestMap{
+		Label:    m.Label,
+		Category: groupCategory(m.Group),
+		Id:       m.Id,
+		Lines:    len(m.Lines),
+		Labels:   len(m.Labels),
+		Vertices: countVertices(m.Lines),
+		SHA256:   sum,
+		Features: mapFeatureCounts[m.Name],
+	}
+	for _, lod := range m.LODs {
+		mm.LODs = append(mm.LODs, jsonManifestLOD{Tolerance: lod.Tolerance, Vertices: countVertices(lod.Lines)})
+	}
+	if lo, hi, ok := mapBounds(m); ok {
+		mm.Bounds = []Point2LL{lo, hi}
+	}
+	return mm
+}
+
+// mapChecksum returns the SHA-256 hash of the encoding of the map by
+// itself (which is also the contents of its file with -per-map).
+func mapChecksum(m STARSMap) string {
+	b, err := encodeValue(m)
+	errorExit("encoding error", err)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// writeExports writes the maps in the other formats that were requested,
+// naming the files after fn. (With -per-map, writePerMap takes care of
+// KML.)
+func writeExports(maps []STARSMap, fn string) {
+	if *kmlFormat != "" && !*perMap {
+		writeKML(maps, fn+"."+*kmlFormat)
+	}
+	if *vSTARSXML {
+		writeVSTARS(maps, fn+"-vstars.xml")
+	}
+	if *protobufOut {
+		writeProtobuf(maps, fn+"-videomaps.pb")
+	}
+	if *topoJSONOut {
+		writeTopoJSON(maps, fn+".topojson")
+	}
+	if *geoJSONL {
+		writeGeoJSONL(maps, fn+".geojsonl")
+	}
+	if *csvSummary {
+		writeSummaryCSV(maps, fn+"-summary.csv")
+	}
+	if *htmlViewer {
+		writeHTML(maps, fn+".html")
+	}
+	writePreviews(maps, fn)
+}
+
+// writePerMap writes each map to a separate GOB file in the directory
+// fn-videomaps so that they can be loaded individually. The files are
+// named after the maps; index.gob (and/or index.json, following
+// -manifest; the extension of the former follows -encoding) maps from map
+// names to filenames.
+func writePerMap(maps []STARSMap, fn string) {
+	dir := fn + "-videomaps"
+	errorExit(dir, os.MkdirAll(dir, 0o755))
+
+	ext := outputExt()
+	if *zstdGOB {
+		ext += ".zst"
+	}
+	index := make(map[string]string)
+	used := map[string]bool{"index" + ext: true, "index.json": true}
+	for i, m := range versionedMaps(maps) {
+		file := uniqueFilename(used, sanitizeFilename(m.Name), ext)
+		index[m.Name] = file
+		writeEncoded(m, filepath.Join(dir, file))
+		if *kmlFormat != "" {
+			writeKML(maps[i:i+1], filepath.Join(dir, strings.TrimSuffix(file, ext)+"."+*kmlFormat))
+		}
+	}
+
+	if *manifest == "gob" || *manifest == "both" {
+		writeEncoded(index, filepath.Join(dir, "index"+outputExt()))
+	}
+	if *manifest == "json" || *manifest == "both" {
+		writeJSON(index, filepath.Join(dir, "index.json"))
+	}
+}
+
+// uniqueFilename returns name+ext, adding a numeric suffix to the name if
+// needed so that it differs (case-insensitively) from the filenames
+// already in used, and then adds it to used.
+func uniqueFilename(used map[string]bool, name, ext string) string {
+	file := name + ext
+	for i := 2; used[strings.ToLower(file)]; i++ {
+		file = fmt.Sprintf("%s-%d%s", name, i, ext)
+	}
+	used[strings.ToLower(file)] = true
+	return file
+}
+
+// sanitizeFilename returns the given name with characters that may not be
+// allowed in filenames replaced with underscores.
+func sanitizeFilename(name string) string {
+	s := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, strings.TrimSpace(name))
+	if s = strings.Trim(s, "."); s == "" {
+		s = "map"
+	}
+	return s
+}
+
+// outputPath returns the base path for the output files: the given base
+// name, or the one given with -name, with the -prefix added, and in vice's
+// video map directory with -install.
+func outputPath(base string) string {
+	if *outName != "" {
+		base = *outName
+	}
+	return installPath(*outPrefix + base)
+}
+
+// writeOutput writes the given maps to the file specified with -output,
+// if any, and otherwise to the regular video map and manifest files.
+func writeOutput(maps []STARSMap, fn string) {
+	maps = prepareMaps(maps)
+	reportChanges(maps)
+
+	if *output == "" {
+		write(maps, fn)
+	} else {
+		out := *output
+		if *zstdGOB && out != "-" && !strings.HasSuffix(out, ".zst") {
+			out += ".zst"
+		}
+		vmaps := versionedMaps(maps)
+		sum := writeEncoded(storedMaps(vmaps), out)
+		if *verifyOutput && out != "-" {
+			errorExit(out, verifyVideoMapFile(out, sum, vmaps))
+		}
+		if out != "-" {
+			// Name any other files after the output file.
+			fn = strings.TrimSuffix(strings.TrimSuffix(out, ".zst"), outputExt())
+		}
+		writeExports(maps, fn)
+		fmt.Printf("Done.\n")
+	}
+}
+
+// writeEncoded encodes the given value using the -encoding format (GOB
+// by default) and writes it to the named file, or to the standard output
+// if the filename is "-". Files with a .zst extension, and the standard
+// output with -zstd, are zstd-compressed. The hex-encoded SHA-256 hash of
+// the data written is returned.
+func writeEncoded(v any, fn string) string {
+	fmt.Printf("Writing %s... ", fn)
+	b, err := encodeValue(v)
+	errorExit("encoding error", err)
+	if strings.HasSuffix(fn, ".zst") || (fn == "-" && *zstdGOB) {
+		b = zstdCompress(b)
+	}
+
+	if fn == "-" {
+		_, err = stdout.Write(b)
+	} else {
+		if *backup {
+			backupFile(fn, b)
+		}
+		err = writeFileAtomic(fn, b, 0o644)
+	}
+	errorExit(fn, err)
+	signFile(fn, b)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// backupFile copies the existing file fn, if any, to a file named using
+// its modification time, fn.YYYYMMDD-HHMMSS.bak, before it's replaced
+// with b. Nothing is done if the contents are unchanged.
+func backupFile(fn string, b []byte) {
+	fi, err := os.Stat(fn)
+	if err != nil {
+		return
+	}
+	old, err := os.ReadFile(fn)
+	errorExit(fn, err)
+	if bytes.Equal(old, b) {
+		return
+	}
+	bak := fn + "." + fi.ModTime().Format("20060102-150405") + ".bak"
+	fmt.Printf("Backing up to %s... ", bak)
+	errorExit("creating backup", writeFileAtomic(bak, old, fi.Mode().Perm()))
+}
+
+// writeFileAtomic writes the data to the named file by way of a temporary
+// file in the same directory that is then renamed into place, so that if
+// the program is interrupted or the disk fills up, an existing file is
+// left intact rather than partially overwritten.
+func writeFileAtomic(fn string, b []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(fn), "."+filepath.Base(fn)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmp, fn)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// writeJSON writes the given value to the named file as indented JSON.
+func writeJSON(v any, fn string) {
+	fmt.Printf("Writing %s... ", fn)
+	b, err := json.MarshalIndent(v, "", "  ")
+	errorExit("JSON error", err)
+	b = append(b, '\n')
+	err = writeFileAtomic(fn, b, 0o644)
+	errorExit("creating file", err)
+	signFile(fn, b)
+}
+
+// stringList is a flag.Value for options that may be given multiple
+// times; comma-separated values are also accepted.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, strings.Split(v, ",")...)
+	return nil
+}
+
+// hasTag returns true if the map has any of the given tags; tags are
+// matched case-insensitively.
+func (m VideoMapSpec) hasTag(tags []string) bool {
+	return slices.ContainsFunc(m.Tags, func(t string) bool {
+		return slices.ContainsFunc(tags, func(tag string) bool { return strings.EqualFold(t, tag) })
+	})
+}
+
+// appendSegment adds the line segment from p0 to p1 to the given lines,
+// extending the last line if the segment continues it.
+func appendSegment(lines [][]Point2LL, p0, p1 Point2LL) [][]Point2LL {
+	if n := len(lines); n > 0 && lines[n-1][len(lines[n-1])-1] == p0 {
+		lines[n-1] = append(lines[n-1], p1)
+		return lines
+	}
+	return append(lines, []Point2LL{p0, p1})
+}
+
 // MapSlice returns the slice that is the result of applying the provided
 // xform function to all of the elements of the given slice.
 func MapSlice[F, T any](from []F, xform func(F) T) []T {
@@ -116,70 +751,774 @@ func MapSlice[F, T any](from []F, xform func(F) T) []T {
 
 type Point2LL [2]float32
 
+func (p Point2LL) Longitude() float32 { return p[0] }
+func (p Point2LL) Latitude() float32  { return p[1] }
+
+func radians(d float32) float64 {
+	return float64(d) / 180 * math.Pi
+}
+
+// nmdistance2ll returns the distance in nautical miles between two
+// locations specified in latitude and longitude.
+func nmdistance2ll(a, b Point2LL) float32 {
+	const R = 3440.065 // radius of the earth in nm
+	dlat, dlon := radians(b.Latitude()-a.Latitude()), radians(b.Longitude()-a.Longitude())
+	x := math.Sin(dlat/2)*math.Sin(dlat/2) +
+		math.Cos(radians(a.Latitude()))*math.Cos(radians(b.Latitude()))*math.Sin(dlon/2)*math.Sin(dlon/2)
+	return float32(2 * R * math.Atan2(math.Sqrt(x), math.Sqrt(1-x)))
+}
+
+// Extent2D represents a 2D bounding box.
+type Extent2D struct {
+	P0, P1 Point2LL
+}
+
+func EmptyExtent2D() Extent2D {
+	return Extent2D{
+		P0: Point2LL{math.MaxFloat32, math.MaxFloat32},
+		P1: Point2LL{-math.MaxFloat32, -math.MaxFloat32},
+	}
+}
+
+func (e Extent2D) IsEmpty() bool {
+	return e.P0[0] > e.P1[0] || e.P0[1] > e.P1[1]
+}
+
+func (e Extent2D) Center() Point2LL {
+	return Point2LL{(e.P0[0] + e.P1[0]) / 2, (e.P0[1] + e.P1[1]) / 2}
+}
+
+func (e *Extent2D) Add(p Point2LL) {
+	e.P0[0], e.P0[1] = min(e.P0[0], p[0]), min(e.P0[1], p[1])
+	e.P1[0], e.P1[1] = max(e.P1[0], p[0]), max(e.P1[1], p[1])
+}
+
+func LinesExtent(lines [][]Point2LL) Extent2D {
+	e := EmptyExtent2D()
+	for _, l := range lines {
+		for _, p := range l {
+			e.Add(p)
+		}
+	}
+	return e
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Coordinate fixups
+
+// coordinatesSwapped tries to determine whether the provided lines were
+// specified with [lat, lon] coordinates rather than GeoJSON's [lon,
+// lat]. If they seem to be, it returns true along with a description of
+// why it thinks so. The provided centers, if any, are the ARTCC's
+// visibility centers and are used to check whether the geometry is in
+// the right part of the world.
+func coordinatesSwapped(lines [][]Point2LL, centers []Point2LL) (bool, string) {
+	// First see if either component is out of range for a latitude;
+	// if so, it must be the longitude.
+	firstLon, secondLon := false, false
+	for _, l := range lines {
+		for _, p := range l {
+			firstLon = firstLon || math.Abs(float64(p[0])) > 90
+			secondLon = secondLon || math.Abs(float64(p[1])) > 90
+		}
+	}
+	if secondLon && !firstLon {
+		return true, "latitudes exceed 90 degrees"
+	} else if firstLon || len(centers) == 0 {
+		return false, ""
+	}
+
+	e := LinesExtent(lines)
+	if e.IsEmpty() {
+		return false, ""
+	}
+
+	// Otherwise see how far the bounding box is from the ARTCC with the
+	// coordinates as given and swapped.
+	closest := func(p Point2LL) float32 {
+		d := float32(math.MaxFloat32)
+		for _, c := range centers {
+			d = min(d, nmdistance2ll(p, c))
+		}
+		return d
+	}
+	c := e.Center()
+	d, ds := closest(c), closest(Point2LL{c[1], c[0]})
+	if d > 1000 && 4*ds < d {
+		return true, fmt.Sprintf("bounding box is %.0f nm from the ARTCC, %.0f nm if swapped", d, ds)
+	}
+	return false, ""
+}
+
+// splitAntimeridian splits lines with segments that cross the ±180
+// degree meridian (as happens for ZAN and ZUA) into separate lines that
+// end at the meridian so that they aren't drawn wrapping around the
+// entire world.
+func splitAntimeridian(lines [][]Point2LL) [][]Point2LL {
+	var split [][]Point2LL
+	for _, l := range lines {
+		var cur []Point2LL
+		for i, p := range l {
+			if i > 0 && math.Abs(float64(p[0]-l[i-1][0])) > 180 {
+				prev := l[i-1]
+				edge, lon := float32(180), p[0]+360
+				if prev[0] < 0 {
+					edge, lon = -180, p[0]-360
+				}
+				t := (edge - prev[0]) / (lon - prev[0])
+				lat := prev[1] + t*(p[1]-prev[1])
+
+				split = append(split, append(cur, Point2LL{edge, lat}))
+				cur = []Point2LL{{-edge, lat}}
+			}
+			cur = append(cur, p)
+		}
+		split = append(split, cur)
+	}
+	return split
+}
+
+func checkCoordOrder(order string) error {
+	if order != "auto" && order != "lonlat" && order != "latlon" {
+		return fmt.Errorf("%q: invalid coordinate order; expected \"lonlat\", \"latlon\", or \"auto\"", order)
+	}
+	return nil
+}
+
+// mapBounds returns the corners of the map's bounding box, including its
+// labels; ok is false if the map is empty.
+func mapBounds(m STARSMap) (lo, hi Point2LL, ok bool) {
+	e := LinesExtent(m.Lines)
+	for _, l := range m.Labels {
+		e.Add(l.P)
+	}
+	return e.P0, e.P1, !e.IsEmpty()
+}
+
+func swapCoordinates(lines [][]Point2LL) {
+	for _, l := range lines {
+		for i, p := range l {
+			l[i] = Point2LL{p[1], p[0]}
+		}
+	}
+}
+
 ///////////////////////////////////////////////////////////////////////////
 // main
 
+func usage() {
+	fmt.Fprintf(os.Stderr, `usage: crc2vice [options] ARTCC
+       crc2vice [options] files OUTNAME [[LABEL[,NAME[,CATEGORY]]=]FILE...]
+       crc2vice [options] merge OUTNAME ARTCC...
+       crc2vice [options] validate ARTCC
+       crc2vice [options] check ARTCC
+       crc2vice scenario SCENARIO [VIDEOMAPS]
+       crc2vice export GOBFILE...
+       crc2vice [options] migrate GOBFILE...
+       crc2vice keygen NAME
+       crc2vice verify PUBKEY FILE...
+
+The first form converts the STARS video maps of an installed CRC ARTCC
+(e.g., ZNY) or of a facility within one (e.g., N90, which is equivalent
+to -facility N90 ZNY). ARTCCs/ZNY.yaml may be used in place of
+ARTCCs/ZNY.json for hand-maintained definitions, and the JSON may include
+comments and trailing commas. The second converts the given files (or glob patterns) to
+OUTNAME-videomaps.gob; map labels, names, and brightness categories may be
+specified along with each file and "-" reads GeoJSON from standard input.
+Supported formats: GeoJSON (.geojson, .json), TopoJSON (.topojson, or
+.json), VRC and EuroScope sector files (.sct2, .sct), EuroScope .ese
+files, .zip archives of sector files such as GNG packages, vSTARS facility
+and vERAM GeoMap files (.xml, .gz), FAA video map files (.dat, .map; see
+-dat-ref), KML (.kml, .kmz), ESRI shapefiles (.shp, or zipped; see
+-shp-name-field), GeoPackages (.gpkg; see -gpkg-layer), FlatGeobuf
+(.fgb), and AIXM airspace and MVA files (.xml).
+
+The third converts the STARS video maps of several ARTCCs (or facilities
+within them) and writes them all to OUTNAME-videomaps.gob, for scenarios
+that span ARTCC boundaries; map names must be unique across them.
+
+The fourth goes the other way, writing each map in the given video map
+files (e.g., ZNY-videomaps.gob, possibly compressed) as GeoJSON to the
+directory ZNY-geojson.
+
+validate checks the maps of an ARTCC (or facility) without writing any
+output, reporting invalid coordinates with their file, feature, and
+vertex, maps with the same name, DCB label, or STARS id, and maps
+without any lines or labels; these are also reported when converting.
+
+check converts the maps of an ARTCC (or facility) without writing any
+output and exits with an error if the result differs from the video map
+and manifest files that were written before, listing the maps that were
+added, removed, or changed, so that CI can ensure that committed files
+are kept up to date.
+
+scenario checks that all of the video maps that a vice scenario file
+refers to are in the given video map file or manifest (by default, the
+scenario's "video_map_file" in vice's resources directory), exiting with
+an error if any aren't, and lists the maps that it doesn't use.
+
+migrate rewrites the maps in video map files written by older versions
+of crc2vice in the current format (see -format-version and
+-target-vice-version) with a new manifest, replacing the originals (see -backup).
+
+keygen writes a new Ed25519 key pair to NAME.key and NAME.pub for use
+with -sign, and verify checks the signatures (FILE.sig) of the given
+files using the public key.
+
+Options:
+`)
+	flag.PrintDefaults()
+}
+
 func main() {
-	if len(os.Args) != 2 {
+	flag.Usage = usage
+	flag.Parse()
+	if *output == "-" {
+		// Send status messages to stderr so that they don't end up in
+		// the output.
+		os.Stdout = os.Stderr
+	}
+	errorExit("-coord-order", checkCoordOrder(*coordOrder))
+	if *output != "" && (*perMap || *install) {
+		fmt.Fprintf(os.Stderr, "crctovice: -output can't be used with -per-map or -install\n")
+		os.Exit(1)
+	}
+	if *viceConfig && (*output != "" || *perMap) {
+		fmt.Fprintf(os.Stderr, "crctovice: -vice-config can't be used with -output or -per-map\n")
+		os.Exit(1)
+	}
+	if *splitCategories && (*output != "" || *perMap) {
+		fmt.Fprintf(os.Stderr, "crctovice: -split-categories can't be used with -output or -per-map\n")
+		os.Exit(1)
+	}
+	if *manifest != "gob" && *manifest != "json" && *manifest != "both" {
+		fmt.Fprintf(os.Stderr, "%s: -manifest must be \"gob\", \"json\", or \"both\"\n", *manifest)
+		os.Exit(1)
+	}
+	var err error
+	formatVersion, err = chooseFormatVersion(*formatFlag, *targetVice, *zstdGOB || *dedup || *lodArg != "")
+	errorExit("crctovice", err)
+	if *airacFlag != "" {
+		c, err := parseAIRAC(*airacFlag)
+		errorExit("-airac", err)
+		airac = &c
+	}
+	if *signKey != "" {
+		signingKey, err = readPrivateKey(*signKey)
+		errorExit(*signKey, err)
+	}
+	if *boundaryFn != "" {
+		boundary, err = readBoundary(*boundaryFn)
+		errorExit(*boundaryFn, err)
+	}
+	errorExit("crctovice", parseInputLimits())
+	simplifyTolerance, err = parseTolerance(*simplifyTol)
+	errorExit("-simplify-tolerance", err)
+	clipRegion, err = parseClip(*clipArg)
+	errorExit("-clip", err)
+	errorExit("-clip-radius", parseClipRadius(*clipRadius))
+	snapTolerance, err = parseTolerance(*snapArg)
+	errorExit("-snap", err)
+	joinTolerance, err = parseTolerance(*joinArg)
+	errorExit("-join-lines", err)
+	if *densify != "" {
+		densifyDistance, err = parseNM(*densify)
+		errorExit("-densify", err)
+	}
+	lodTolerances, err = parseLODs(*lodArg)
+	errorExit("-lod", err)
+	precision, err = parseTolerance(*precisionArg)
+	errorExit("-precision", err)
+	if *maxVertices < 0 {
+		errorExit("-max-vertices-per-map", fmt.Errorf("%d: must not be negative", *maxVertices))
+	}
+	maxOutputSize, err = parseSize(*maxSize)
+	errorExit("-max-size", err)
+	if *maxSize != "" && (*output != "" || *perMap) {
+		fmt.Fprintf(os.Stderr, "crctovice: -max-size can't be used with -output or -per-map\n")
+		os.Exit(1)
+	}
+	if *dedup && *perMap {
+		fmt.Fprintf(os.Stderr, "crctovice: -dedup-lines can't be used with -per-map\n")
+		os.Exit(1)
+	}
+	if *dedup && formatVersion < 2 {
+		fmt.Fprintf(os.Stderr, "crctovice: -dedup-lines requires format version 2\n")
+		os.Exit(1)
+	}
+	if *zstdGOB && formatVersion < 2 {
+		fmt.Fprintf(os.Stderr, "crctovice: -zstd requires format version 2\n")
+		os.Exit(1)
+	}
+	if *lodArg != "" && formatVersion < 2 {
+		fmt.Fprintf(os.Stderr, "crctovice: -lod requires format version 2\n")
+		os.Exit(1)
+	}
+	if *encoding != "gob" && *encoding != "cbor" && *encoding != "msgpack" {
+		fmt.Fprintf(os.Stderr, "%s: -encoding must be \"gob\", \"cbor\", or \"msgpack\"\n", *encoding)
+		os.Exit(1)
+	}
+	if *previewSize < 16 {
+		fmt.Fprintf(os.Stderr, "%d: -preview-size must be at least 16\n", *previewSize)
+		os.Exit(1)
+	}
+	if *kmlFormat != "" && *kmlFormat != "kml" && *kmlFormat != "kmz" {
+		fmt.Fprintf(os.Stderr, "%s: -kml must be \"kml\" or \"kmz\"\n", *kmlFormat)
+		os.Exit(1)
+	}
+
+	switch {
+	case flag.NArg() >= 2 && flag.Arg(0) == "files":
+		convertFiles(flag.Arg(1), flag.Args()[2:])
+	case flag.NArg() >= 2 && flag.Arg(0) == "merge":
+		mergeARTCCs(flag.Arg(1), flag.Args()[2:])
+	case flag.NArg() == 2 && flag.Arg(0) == "keygen":
+		generateKeys(flag.Arg(1))
+	case flag.NArg() >= 3 && flag.Arg(0) == "verify":
+		verifyFiles(flag.Arg(1), flag.Args()[2:])
+	case flag.NArg() == 2 && flag.Arg(0) == "validate":
+		validateARTCC(flag.Arg(1))
+	case flag.NArg() == 2 && flag.Arg(0) == "check":
+		checkARTCC(flag.Arg(1))
+	case (flag.NArg() == 2 || flag.NArg() == 3) && flag.Arg(0) == "scenario":
+		checkScenario(flag.Arg(1), flag.Arg(2))
+	case flag.NArg() >= 2 && flag.Arg(0) == "migrate":
+		migrateFiles(flag.Args()[1:])
+	case flag.NArg() >= 2 && flag.Arg(0) == "export":
+		exportGeoJSON(flag.Args()[1:])
+	case flag.NArg() == 1:
+		convertARTCC(flag.Arg(0))
+	default:
 		fmt.Fprintf(os.Stderr, "crctovice: expected ARTCC name as program argument (e.g., ZNY)\n")
+		usage()
 		os.Exit(1)
 	}
-	base := os.Args[1]
+	finishRun()
+}
+
+// artccFilename returns the path of the ARTCC definition in fsys, which
+// may be either JSON or YAML, or "" if there isn't one.
+func artccFilename(fsys fs.FS, base string) string {
+	for _, ext := range []string{".json", ".yaml", ".yml"} {
+		fn := "ARTCCs/" + base + ext
+		if _, err := fs.Stat(fsys, fn); err == nil {
+			return fn
+		}
+	}
+	return ""
+}
+
+// readARTCC reads the ARTCC definition at the given path in fsys.
+func readARTCC(fsys fs.FS, fn string) (ARTCC, error) {
+	b, err := readARTCCJSON(fsys, fn)
+	if err != nil {
+		return ARTCC{}, err
+	}
+	return parseARTCC(b)
+}
+
+// readARTCCJSON returns the ARTCC definition in the given file as
+// standard JSON, converting it from YAML if necessary.
+func readARTCCJSON(fsys fs.FS, fn string) ([]byte, error) {
+	b, err := readInputFile(fsys, fn)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read ARTCC definition: %w", err)
+	}
+	return artccJSON(fn, b)
+}
+
+// artccJSON converts the contents of the given ARTCC definition file to
+// standard JSON.
+func artccJSON(fn string, b []byte) ([]byte, error) {
+	b = decodeText(b)
+
+	if ext := path.Ext(fn); ext == ".yaml" || ext == ".yml" {
+		var err error
+		if b, err = yamlToJSON(b); err != nil {
+			return nil, err
+		}
+	}
+	return standardizeJSON(b), nil
+}
+
+func parseARTCC(b []byte) (ARTCC, error) {
+	var artcc ARTCC
+	if err := UnmarshalJSON(b, &artcc); err != nil {
+		return ARTCC{}, fmt.Errorf("JSON error: %w", err)
+	}
+	return artcc, nil
+}
+
+// findParentARTCC returns the name of the ARTCC in fsys that includes the
+// facility with the given id (e.g., a TRACON), or "" if there is none.
+func findParentARTCC(fsys fs.FS, id string) string {
+	files, _ := fs.Glob(fsys, "ARTCCs/*")
+	for _, fn := range files {
+		ext := path.Ext(fn)
+		if ext != ".json" && ext != ".yaml" && ext != ".yml" {
+			continue
+		}
+		if artcc, err := readARTCC(fsys, fn); err == nil && artcc.Facility.Find(id) != nil {
+			return strings.TrimSuffix(path.Base(fn), ext)
+		}
+	}
+	return ""
+}
+
+func convertARTCC(base string) {
+	artcc, base, maps, centers := readARTCCMaps(base)
+
+	outbase := facilityOutputBase(base)
+	writeOutput(maps, outbase)
+
+	if *eram {
+		writeEncoded(convertERAMMaps(artcc, base, centers), outbase+"-erammaps"+outputExt())
+		fmt.Printf("Done.\n")
+	}
+	if *asdex {
+		writeEncoded(convertASDEXMaps(artcc, base, *facilityId, centers), outbase+"-asdex"+outputExt())
+		fmt.Printf("Done.\n")
+	}
+	if *towerCab {
+		write(prepareMaps(convertTowerCabMaps(artcc, base, *facilityId, centers)), outbase+"-tower")
+	}
+}
+
+// facilityOutputBase returns the base path of the output files for the
+// given ARTCC, or for the facility given with -facility.
+func facilityOutputBase(base string) string {
+	if *facilityId != "" {
+		base = strings.ToUpper(*facilityId)
+	}
+	return outputPath(base)
+}
+
+// readARTCCMaps reads the definition of the given ARTCC, or of the ARTCC
+// that the given facility is in, and converts its STARS video maps. The
+// name of the ARTCC and its visibility centers are returned as well, for
+// converting its other maps.
+func readARTCCMaps(base string) (ARTCC, string, []STARSMap, []Point2LL) {
+	if *remote {
+		if *source != "." || *gitRepo != "" || *gitHub != "" {
+			fmt.Fprintf(os.Stderr, "crctovice: -remote can't be used with -source, -git, or -github\n")
+			os.Exit(1)
+		}
+		srcFS = newVNASFS(*vnasURL)
+	} else if *gitHub != "" {
+		var err error
+		srcFS, err = openGitHubRelease(*gitHub, base)
+		errorExit(*gitHub, err)
+	} else if *gitRepo != "" {
+		dir, err := gitCheckout(*gitRepo, *gitRef)
+		errorExit(*gitRepo, err)
+		srcFS, err = openSource(filepath.Join(dir, *source), base)
+		errorExit(*gitRepo, err)
+	} else if *source != "." {
+		var err error
+		srcFS, err = openSource(*source, base)
+		errorExit(*source, err)
+	} else if artccFilename(srcFS, base) == "" && findParentARTCC(srcFS, base) == "" {
+		// Not running in the CRC directory; see if CRC has the ARTCC.
+		if dir, err := crcDirectory(); err == nil {
+			if crcFS := os.DirFS(dir); artccFilename(crcFS, base) != "" || findParentARTCC(crcFS, base) != "" {
+				fmt.Printf("Reading from CRC directory %s\n", dir)
+				srcFS = crcFS
+			}
+		}
+	}
 
 	fn := "ARTCCs/" + base + ".json"
-	artccFile, err := os.ReadFile(fn)
-	errorExit(fmt.Sprintf("%s: unable to read ARTCC definition", fn), err)
+	if !*remote { // the vNAS API only provides JSON
+		if afn := artccFilename(srcFS, base); afn != "" {
+			fn = afn
+		} else if parent := findParentARTCC(srcFS, base); parent != "" {
+			// A TRACON or other facility was specified; convert its maps
+			// from the ARTCC that it's in.
+			fmt.Printf("%s: using ARTCC %s\n", base, parent)
+			if *facilityId == "" && !strings.EqualFold(base, parent) {
+				*facilityId = base
+			}
+			base = parent
+			fn = artccFilename(srcFS, base)
+		}
+	}
 
-	artcc := ARTCC{}
-	err = json.Unmarshal(artccFile, &artcc)
-	errorExit(fmt.Sprintf("%s: JSON error", artccFile), err)
+	// Only the definition that's used is recorded as a source, not the
+	// others that findParentARTCC may have looked at.
+	b, err := readInputFile(srcFS, fn)
+	if err != nil {
+		errorExit(fn, fmt.Errorf("unable to read ARTCC definition: %w", err))
+	}
+	recordSource(fn, b)
+	b, err = artccJSON(fn, b)
+	errorExit(fn, err)
+	checkARTCCSchema(fn, b)
+	artcc, err := parseARTCC(b)
+	errorExit(fn, err)
 	fmt.Printf("Read ARTCC definition: %s\n", fn)
+	if airac == nil && artcc.LastUpdatedAt != "" {
+		if t, err := time.Parse(time.RFC3339, artcc.LastUpdatedAt); err != nil {
+			fmt.Printf("%s: warning: %s: unable to parse lastUpdatedAt\n", fn, artcc.LastUpdatedAt)
+		} else {
+			c := airacForDate(t)
+			airac = &c
+			fmt.Printf("Using AIRAC cycle %s (effective %s)\n", c, c.EffectiveDate())
+		}
+	}
 
-	var maps []STARSMap
-	for _, m := range artcc.VideoMaps {
-		group := 1
-		if m.Category == "A" {
-			group = 0
+	var facilityMapIds []string
+	if *facilityId != "" {
+		fac := artcc.Facility.Find(*facilityId)
+		if fac == nil {
+			fmt.Fprintf(os.Stderr, "%s: facility not found in %s. Available facilities: %s\n", *facilityId, fn,
+				strings.Join(artcc.Facility.FacilityIds(), ", "))
+			os.Exit(1)
+		}
+		facilityMapIds = fac.STARSVideoMapIds()
+		if len(facilityMapIds) == 0 {
+			fmt.Printf("%s: warning: facility has no STARS video maps\n", fac.Id)
+		}
+	}
+
+	// Map groups, for both the output and -map-group.
+	groups := artcc.Scope(*facilityId).MapGroups()
+	groupPositions := MapSlice(groups, func(g CRCMapGroup) map[string]int { return g.Positions(artcc.VideoMaps) })
+	var groupMapIds []string
+	for _, mg := range mapGroups {
+		// Ids take precedence over indices, since they may be numbers too.
+		idx := slices.IndexFunc(groups, func(g CRCMapGroup) bool { return g.Id == mg })
+		if idx == -1 {
+			idx = slices.IndexFunc(groups, func(g CRCMapGroup) bool { return strings.EqualFold(g.Id, mg) })
+		}
+		if n, err := strconv.Atoi(mg); err == nil && idx == -1 && n >= 1 && n <= len(groups) {
+			idx = n - 1
+		}
+		if idx == -1 {
+			fmt.Fprintf(os.Stderr, "%s: map group not found. Available groups: %s\n", mg,
+				strings.Join(MapSlice(groups, func(g CRCMapGroup) string { return g.Id }), ", "))
+			os.Exit(1)
 		}
-		sm := STARSMap{
-			Group: group,
-			Label: m.ShortName,
-			Name:  m.Name,
-			Id:    m.STARSId,
+		for id := range groupPositions[idx] {
+			groupMapIds = append(groupMapIds, id)
 		}
+	}
 
-		fn := path.Join("VideoMaps", base, m.Id) + ".geojson"
-		file, err := os.ReadFile(fn)
-		errorExit(fmt.Sprintf("%s: unable to read file", fn), err)
+	centers := MapSlice(artcc.VisibilityCenters, func(ll CRCLatLon) Point2LL { return ll.Point2LL() })
+	addBoundaryCenters(centers)
+	findClipCenter(artcc, fn)
 
-		var gj GeoJSON
-		err = UnmarshalJSON(file, &gj)
-		if err != nil {
-			fmt.Printf("\r" + fn + ": warning: " + err.Error() + "\n")
+	var maps []STARSMap
+	for order, m := range artcc.VideoMaps {
+		if m.TDMOnly && !*includeTDM {
+			fmt.Printf("\r%s: skipping TDM-only map\n", m.Name)
+			continue
+		}
+		if (len(includeTags) > 0 && !m.hasTag(includeTags)) || m.hasTag(excludeTags) {
+			continue
+		}
+		if *facilityId != "" && !slices.Contains(facilityMapIds, m.Id) {
+			continue
+		}
+		if len(mapGroups) > 0 && !slices.Contains(groupMapIds, m.Id) {
+			continue
 		}
 
-		for _, f := range gj.Features {
-			if f.Type != "Feature" {
-				continue
+		sm := convertSTARSMap(m, order, base, centers)
+		for i, g := range groups {
+			if pos, ok := groupPositions[i][m.Id]; ok {
+				sm.MapGroups = append(sm.MapGroups, STARSMapGroup{Id: g.Id, Position: pos, TCPs: g.TCPs})
 			}
+		}
+		maps = append(maps, sm)
+	}
+	fmt.Printf("\rRead video maps                                               \n")
+	checkUnreferencedFiles(artcc, base)
+
+	return artcc, base, maps, centers
+}
+
+// convertSTARSMap reads the GeoJSON for the given video map and returns
+// the corresponding STARSMap. order gives the map's index in the ARTCC
+// definition.
+func convertSTARSMap(m VideoMapSpec, order int, base string, centers []Point2LL) STARSMap {
+	m = completeVideoMapSpec(m)
+	sm := newSTARSMap(m, order)
+	features := readVideoMap(base, m, centers)
+	checkSkippedFeatures(mapFiles[m.Name], m, features)
+	sm.Lines = featureLines(features)
+	return sm
+}
+
+// categoryGroup returns the STARSMap group for the given brightness
+// category.
+func categoryGroup(category string) int {
+	if category == "A" {
+		return 0
+	}
+	return 1
+}
+
+// groupCategory is the inverse of categoryGroup.
+func groupCategory(group int) string {
+	if group == 0 {
+		return "A"
+	}
+	return "B"
+}
+
+// newSTARSMap returns a STARSMap initialized using the video map's
+// specification but without any lines.
+func newSTARSMap(m VideoMapSpec, order int) STARSMap {
+	return STARSMap{
+		Group: categoryGroup(m.Category),
+		Label: m.ShortName,
+		Name:  m.Name,
+		Id:    m.STARSId,
+
+		AlwaysVisible: m.AlwaysVisible,
+		Order:         order,
+	}
+}
+
+// completeVideoMapSpec returns the spec with fallbacks for its name, short
+// name, and brightness category if they're missing, since otherwise the
+// map would have a blank DCB button that can't be selected in vice. The
+// name is derived from the id, the short name from the name (or the id,
+// if the name has nothing that STARS can display), and the category is
+// A, as with the files command.
+func completeVideoMapSpec(m VideoMapSpec) VideoMapSpec {
+	var missing []string
+	if strings.TrimSpace(m.Name) == "" {
+		m.Name = m.Id
+		if isURL(m.Id) {
+			m.Name = strings.TrimSuffix(path.Base(m.Id), path.Ext(m.Id))
+		}
+		missing = append(missing, fmt.Sprintf("name (using %q)", m.Name))
+	}
+	if strings.TrimSpace(m.ShortName) == "" {
+		m.ShortName = dcbLabel(m.Name)
+		if m.ShortName == "" {
+			m.ShortName = dcbLabel(m.Id)
+		}
+		if m.ShortName == "" {
+			m.ShortName = "MAP"
+		}
+		missing = append(missing, fmt.Sprintf("shortName (using %q)", m.ShortName))
+	}
+	if m.Category == "" {
+		m.Category = "A"
+		missing = append(missing, "starsBrightnessCategory (using A)")
+	}
+
+	if len(missing) > 0 {
+		report(finding{Severity: "warning", Rule: "incomplete-map", Map: m.Name, Feature: -1, Vertex: -1,
+			Message: "video map has no " + strings.Join(missing, ", ")})
+	}
+	return m
+}
+
+// readVideoMap reads the GeoJSON file for the given video map and returns
+// its features in drawing order. The coordinates of LineString and Polygon
+// features have been converted to longitude-latitude, if needed.
+func readVideoMap(base string, m VideoMapSpec, centers []Point2LL) []GeoJSONFeature {
+	var fn string
+	var file []byte
+	var err error
+	if url := m.URL; url != "" || isURL(m.Id) {
+		if url == "" {
+			url = m.Id
+		}
+		fn = url
+		file, err = fetchURL(url)
+	} else {
+		fn, file, err = readVideoMapFile(base, m)
+	}
+	errorExit(fmt.Sprintf("%s: unable to read file", fn), err)
+	recordSource(fn, file)
+	mapFiles[m.Name] = fn
 
-			if f.Geometry.Type != "LineString" {
-				continue
+	return parseVideoMap(fn, file, m, centers)
+}
+
+// parseVideoMap does the work for readVideoMap given the contents of the
+// GeoJSON file; fn is only used for warning messages.
+func parseVideoMap(fn string, file []byte, m VideoMapSpec, centers []Point2LL) []GeoJSONFeature {
+	var gj GeoJSON
+	if err := UnmarshalJSON(file, &gj); err != nil {
+		fmt.Printf("\r" + fn + ": warning: " + err.Error() + "\n")
+	}
+	errorExit(fn, checkFeatureCount(len(gj.Features)))
+
+	var features []GeoJSONFeature
+	starts := featureLineNumbers(decodeText(file))
+	for i, f := range gj.Features {
+		if f.Type == "Feature" {
+			f.index = i
+			if i < len(starts) {
+				f.line = starts[i]
 			}
+			features = append(features, f)
+		}
+	}
 
-			sm.Lines = append(sm.Lines, f.Geometry.Coordinates)
+	// Draw lower z-index features first, otherwise maintaining the
+	// order from the file.
+	slices.SortStableFunc(features, func(a, b GeoJSONFeature) int { return a.ZIndex() - b.ZIndex() })
+
+	var lines [][]Point2LL
+	for _, f := range features {
+		if len(f.Geometry.Coordinates) > 0 {
+			lines = append(lines, f.Geometry.Coordinates)
 		}
+		lines = append(lines, f.Geometry.Rings...)
+	}
 
-		maps = append(maps, sm)
+	// The fixups below modify the lines in place and thus update the
+	// features' coordinates as well.
+	if err := reproject(lines, gj.CRS, *defaultCRS); err != nil {
+		fmt.Printf("\r%s: warning: %v; ignoring its lines\n", fn, err)
+		return slices.DeleteFunc(features, func(f GeoJSONFeature) bool {
+			return len(f.Geometry.Coordinates) > 0 || len(f.Geometry.Rings) > 0
+		})
+	}
+
+	order := *coordOrder
+	if m.CoordOrder != "" {
+		if err := checkCoordOrder(m.CoordOrder); err != nil {
+			fmt.Printf("\r%s: warning: %v; using \"%s\"\n", m.Name, err, order)
+		} else {
+			order = m.CoordOrder
+		}
+	}
+	if order == "latlon" {
+		swapCoordinates(lines)
+	} else if order == "auto" && *autoSwap {
+		if swapped, why := coordinatesSwapped(lines, centers); swapped {
+			fmt.Printf("\r%s: warning: coordinates appear to be [lat, lon] (%s); swapping\n", fn, why)
+			swapCoordinates(lines)
+		}
 	}
-	fmt.Printf("\rRead video maps                                               \n")
 
-	write(maps, base)
+	checkFeatures(fn, m, features)
+	return features
+}
+
+// featureLines returns the lines of the given LineString features,
+// split at the antimeridian as needed.
+func featureLines(features []GeoJSONFeature) [][]Point2LL {
+	var lines [][]Point2LL
+	for _, f := range features {
+		if f.Geometry.Type == "LineString" {
+			lines = append(lines, f.Geometry.Coordinates)
+		}
+	}
+	return splitAntimeridian(lines)
 }
 
 // Unmarshal the bytes into the given type but go through some efforts to
 // return useful error messages when the JSON is invalid...
 func UnmarshalJSON[T any](b []byte, out *T) error {
+	b = decodeText(b)
 	err := json.Unmarshal(b, out)
 	if err == nil {
 		return nil
@@ -212,3 +1551,61 @@ func UnmarshalJSON[T any](b []byte, out *T) error {
 		return err
 	}
 }
+
+// standardizeJSON returns a copy of b with comments and trailing commas,
+// as are often found in hand-edited files, replaced with spaces. Offsets
+// in the result match the original so that errors are reported at the
+// right place.
+func standardizeJSON(b []byte) []byte {
+	b = slices.Clone(b)
+	blank := func(i, j int) {
+		for ; i < j; i++ {
+			if b[i] != '\n' {
+				b[i] = ' '
+			}
+		}
+	}
+
+	lastComma := -1
+	for i := 0; i < len(b); i++ {
+		switch c := b[i]; {
+		case c == '"':
+			for i++; i < len(b) && b[i] != '"'; i++ {
+				if b[i] == '\\' {
+					i++
+				}
+			}
+			lastComma = -1
+
+		case c == '/' && i+1 < len(b) && b[i+1] == '/':
+			j := i
+			for j < len(b) && b[j] != '\n' {
+				j++
+			}
+			blank(i, j)
+			i = j
+
+		case c == '/' && i+1 < len(b) && b[i+1] == '*':
+			j := i + 2
+			for j+1 < len(b) && !(b[j] == '*' && b[j+1] == '/') {
+				j++
+			}
+			j = min(j+2, len(b))
+			blank(i, j)
+			i = j - 1
+
+		case c == ',':
+			lastComma = i
+
+		case c == ']' || c == '}':
+			if lastComma != -1 {
+				b[lastComma] = ' '
+			}
+			lastComma = -1
+
+		case c != ' ' && c != '\t' && c != '\n' && c != '\r':
+			lastComma = -1
+		}
+	}
+	return b
+}
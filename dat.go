@@ -0,0 +1,103 @@
+// dat.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// FAA video map exchange files (.dat, .map)
+
+// Real-world video map files give line segments as x/y offsets from a
+// geodetic reference point rather than as latitude-longitude. We accept
+// the plain text form of these: each line gives a segment as "x0 y0 x1
+// y1", with x east and y north, in units of -dat-scale nautical miles.
+// Blank lines and lines starting with "!", "#", or ";" are ignored.
+
+func importDAT(fn string) ([]STARSMap, error) {
+	if *datReference == "" {
+		return nil, fmt.Errorf("the -dat-ref option must be given to convert .dat and .map files")
+	}
+	ref, err := parseLatLon(*datReference)
+	if err != nil {
+		return nil, fmt.Errorf("-dat-ref: %w", err)
+	}
+
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	stem := strings.TrimSuffix(filepath.Base(fn), filepath.Ext(fn))
+	sm := STARSMap{
+		Group: 0,
+		Label: strings.ToUpper(stem),
+		Name:  stem,
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(decodeText(b)))
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.ContainsAny(line[:1], "!#;") {
+			continue
+		}
+
+		f := strings.Fields(line)
+		if len(f) != 4 {
+			return nil, fmt.Errorf("%s:%d: expected \"x0 y0 x1 y1\"", fn, lineno)
+		}
+		var v [4]float64
+		for i := range f {
+			if v[i], err = strconv.ParseFloat(f[i], 64); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", fn, lineno, err)
+			}
+			v[i] *= *datScale
+		}
+
+		sm.Lines = appendSegment(sm.Lines, offsetLL(ref, v[0], v[1]), offsetLL(ref, v[2], v[3]))
+	}
+
+	return []STARSMap{sm}, nil
+}
+
+// offsetLL returns the point at the given offset in nautical miles east
+// and north of the reference point. It uses an equirectangular
+// approximation, which is accurate to well under a pixel over the range
+// of a terminal video map.
+func offsetLL(ref Point2LL, east, north float64) Point2LL {
+	const nmPerDegree = 60
+	lat := float64(ref.Latitude()) + north/nmPerDegree
+	lon := float64(ref.Longitude()) + east/(nmPerDegree*math.Cos(radians(ref.Latitude())))
+	return Point2LL{float32(lon), float32(lat)}
+}
+
+// parseLatLon parses a "latitude,longitude" pair given in decimal
+// degrees.
+func parseLatLon(s string) (Point2LL, error) {
+	lat, lon, ok := strings.Cut(s, ",")
+	if !ok {
+		return Point2LL{}, fmt.Errorf("%q: expected \"latitude,longitude\"", s)
+	}
+	la, err := strconv.ParseFloat(strings.TrimSpace(lat), 32)
+	if err != nil {
+		return Point2LL{}, fmt.Errorf("%q: %w", s, err)
+	}
+	lo, err := strconv.ParseFloat(strings.TrimSpace(lon), 32)
+	if err != nil {
+		return Point2LL{}, fmt.Errorf("%q: %w", s, err)
+	}
+	if math.Abs(la) > 90 || math.Abs(lo) > 180 {
+		return Point2LL{}, fmt.Errorf("%q: latitude or longitude out of range", s)
+	}
+	return Point2LL{float32(lo), float32(la)}, nil
+}
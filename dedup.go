@@ -0,0 +1,104 @@
+// dedup.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// Shared lines
+//
+// Many maps repeat the same lines as others (airport outlines, airspace
+// boundaries, and so forth). With -dedup-lines, each distinct line is
+// only stored once in a video map file; later maps that have it refer to
+// where it's stored with their SharedLines. This requires a loader that
+// knows to expand them (see expandSharedLines); vice releases that don't
+// will silently omit the shared lines, so it's not done by default. The
+// JSON manifest's "sharedLines" member records whether it was done.
+
+// STARSLineRef refers to lines stored with another map in the same file.
+// The lines maps[Map].Lines[Start:Start+Count] go at index At of the
+// map's lines, where maps is the file's maps as stored.
+type STARSLineRef struct {
+	At           int
+	Map          int
+	Start, Count int
+}
+
+// lineKey returns a string that's the same for two lines exactly when
+// they have the same vertices.
+func lineKey(l []Point2LL) string {
+	b := make([]byte, 0, 8*len(l))
+	for _, p := range l {
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(p[0]))
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(p[1]))
+	}
+	return string(b)
+}
+
+// storedMaps returns the maps as they should be stored in a single video
+// map file: with -dedup-lines, each line that was already stored with an
+// earlier map (or earlier in the same map) is replaced with a reference to
+// it. References to consecutive lines are coalesced.
+func storedMaps(maps []STARSMap) []STARSMap {
+	if !*dedup {
+		return maps
+	}
+
+	type location struct{ m, i int }
+	stored := make(map[string]location)
+	sm := make([]STARSMap, len(maps))
+	for i, m := range maps {
+		sm[i] = m
+		sm[i].Lines, sm[i].SharedLines = nil, nil
+		for at, l := range m.Lines {
+			key := lineKey(l)
+			loc, ok := stored[key]
+			if !ok {
+				stored[key] = location{i, len(sm[i].Lines)}
+				sm[i].Lines = append(sm[i].Lines, l)
+				continue
+			}
+
+			refs := sm[i].SharedLines
+			if n := len(refs); n > 0 && refs[n-1].Map == loc.m && refs[n-1].At+refs[n-1].Count == at &&
+				refs[n-1].Start+refs[n-1].Count == loc.i {
+				refs[n-1].Count++
+			} else {
+				sm[i].SharedLines = append(refs, STARSLineRef{At: at, Map: loc.m, Start: loc.i, Count: 1})
+			}
+		}
+	}
+	return sm
+}
+
+// expandSharedLines returns the maps read from a video map file with the
+// lines that they share with other maps restored.
+func expandSharedLines(maps []STARSMap) []STARSMap {
+	expanded := make([]STARSMap, len(maps))
+	for i, m := range maps {
+		expanded[i] = m
+		if len(m.SharedLines) == 0 {
+			continue
+		}
+
+		var lines [][]Point2LL
+		own := m.Lines
+		for _, r := range m.SharedLines {
+			n := min(max(r.At-len(lines), 0), len(own))
+			lines, own = append(lines, own[:n]...), own[n:]
+			if r.Map >= 0 && r.Map < len(maps) {
+				if src := maps[r.Map].Lines; r.Start >= 0 && r.Count >= 0 && r.Start+r.Count <= len(src) {
+					lines = append(lines, src[r.Start:r.Start+r.Count]...)
+				}
+			}
+		}
+		expanded[i].Lines = append(lines, own...)
+		expanded[i].SharedLines = nil
+	}
+	return expanded
+}
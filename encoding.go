@@ -0,0 +1,268 @@
+// encoding.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// Output encodings
+//
+// GOB is what vice reads, but it's awkward to use from languages other
+// than Go, so the output can also be written using CBOR (RFC 8949) or
+// MessagePack. For those, structs are encoded as maps keyed by their Go
+// field names, the same as in the GOB files.
+
+// outputExt returns the extension for files written in the selected
+// encoding.
+func outputExt() string {
+	return "." + *encoding
+}
+
+// encodeValue returns v encoded using the selected encoding.
+func encodeValue(v any) ([]byte, error) {
+	switch *encoding {
+	case "cbor":
+		e := &cborEncoder{}
+		err := encodeReflect(e, reflect.ValueOf(v))
+		return e.buf, err
+	case "msgpack":
+		e := &msgpackEncoder{}
+		err := encodeReflect(e, reflect.ValueOf(v))
+		return e.buf, err
+	default:
+		var b bytes.Buffer
+		err := gob.NewEncoder(&b).Encode(v)
+		return b.Bytes(), err
+	}
+}
+
+// valueEncoder is implemented by the CBOR and MessagePack encoders.
+type valueEncoder interface {
+	null()
+	bool(b bool)
+	int(v int64)
+	uint(v uint64)
+	float32(v float32)
+	float64(v float64)
+	string(s string)
+	arrayHeader(n int)
+	mapHeader(n int)
+}
+
+// encodeReflect encodes v with e; only the types that can be represented
+// in both formats are supported.
+func encodeReflect(e valueEncoder, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Invalid:
+		e.null()
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			e.null()
+			return nil
+		}
+		return encodeReflect(e, v.Elem())
+	case reflect.Bool:
+		e.bool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.int(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		e.uint(v.Uint())
+	case reflect.Float32:
+		e.float32(float32(v.Float()))
+	case reflect.Float64:
+		e.float64(v.Float())
+	case reflect.String:
+		e.string(v.String())
+	case reflect.Slice, reflect.Array:
+		e.arrayHeader(v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if err := encodeReflect(e, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("%s: map keys must be strings", v.Type())
+		}
+		keys := v.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int {
+			if a.String() < b.String() {
+				return -1
+			} else if a.String() > b.String() {
+				return 1
+			}
+			return 0
+		})
+		e.mapHeader(len(keys))
+		for _, k := range keys {
+			e.string(k.String())
+			if err := encodeReflect(e, v.MapIndex(k)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		t := v.Type()
+		var fields []int
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).IsExported() {
+				fields = append(fields, i)
+			}
+		}
+		e.mapHeader(len(fields))
+		for _, i := range fields {
+			e.string(t.Field(i).Name)
+			if err := encodeReflect(e, v.Field(i)); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%s: unsupported type", v.Type())
+	}
+	return nil
+}
+
+type cborEncoder struct {
+	buf []byte
+}
+
+// head appends the initial bytes of a CBOR data item.
+func (c *cborEncoder) head(major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		c.buf = append(c.buf, major|byte(n))
+	case n <= math.MaxUint8:
+		c.buf = append(c.buf, major|24, byte(n))
+	case n <= math.MaxUint16:
+		c.buf = binary.BigEndian.AppendUint16(append(c.buf, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		c.buf = binary.BigEndian.AppendUint32(append(c.buf, major|26), uint32(n))
+	default:
+		c.buf = binary.BigEndian.AppendUint64(append(c.buf, major|27), n)
+	}
+}
+
+func (c *cborEncoder) null() { c.buf = append(c.buf, 0xf6) }
+
+func (c *cborEncoder) bool(b bool) {
+	if b {
+		c.buf = append(c.buf, 0xf5)
+	} else {
+		c.buf = append(c.buf, 0xf4)
+	}
+}
+
+func (c *cborEncoder) int(v int64) {
+	if v >= 0 {
+		c.head(0, uint64(v))
+	} else {
+		c.head(1, uint64(-1-v))
+	}
+}
+
+func (c *cborEncoder) uint(v uint64) { c.head(0, v) }
+
+func (c *cborEncoder) float32(v float32) {
+	c.buf = binary.BigEndian.AppendUint32(append(c.buf, 0xfa), math.Float32bits(v))
+}
+
+func (c *cborEncoder) float64(v float64) {
+	c.buf = binary.BigEndian.AppendUint64(append(c.buf, 0xfb), math.Float64bits(v))
+}
+
+func (c *cborEncoder) string(s string) {
+	c.head(3, uint64(len(s)))
+	c.buf = append(c.buf, s...)
+}
+
+func (c *cborEncoder) arrayHeader(n int) { c.head(4, uint64(n)) }
+func (c *cborEncoder) mapHeader(n int)   { c.head(5, uint64(n)) }
+
+type msgpackEncoder struct {
+	buf []byte
+}
+
+func (m *msgpackEncoder) null() { m.buf = append(m.buf, 0xc0) }
+
+func (m *msgpackEncoder) bool(b bool) {
+	if b {
+		m.buf = append(m.buf, 0xc3)
+	} else {
+		m.buf = append(m.buf, 0xc2)
+	}
+}
+
+func (m *msgpackEncoder) int(v int64) {
+	switch {
+	case v >= 0:
+		m.uint(uint64(v))
+	case v >= -32:
+		m.buf = append(m.buf, byte(v))
+	case v >= math.MinInt8:
+		m.buf = append(m.buf, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		m.buf = binary.BigEndian.AppendUint16(append(m.buf, 0xd1), uint16(v))
+	case v >= math.MinInt32:
+		m.buf = binary.BigEndian.AppendUint32(append(m.buf, 0xd2), uint32(v))
+	default:
+		m.buf = binary.BigEndian.AppendUint64(append(m.buf, 0xd3), uint64(v))
+	}
+}
+
+func (m *msgpackEncoder) uint(v uint64) {
+	switch {
+	case v < 128:
+		m.buf = append(m.buf, byte(v))
+	case v <= math.MaxUint8:
+		m.buf = append(m.buf, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		m.buf = binary.BigEndian.AppendUint16(append(m.buf, 0xcd), uint16(v))
+	case v <= math.MaxUint32:
+		m.buf = binary.BigEndian.AppendUint32(append(m.buf, 0xce), uint32(v))
+	default:
+		m.buf = binary.BigEndian.AppendUint64(append(m.buf, 0xcf), v)
+	}
+}
+
+func (m *msgpackEncoder) float32(v float32) {
+	m.buf = binary.BigEndian.AppendUint32(append(m.buf, 0xca), math.Float32bits(v))
+}
+
+func (m *msgpackEncoder) float64(v float64) {
+	m.buf = binary.BigEndian.AppendUint64(append(m.buf, 0xcb), math.Float64bits(v))
+}
+
+// header appends a header for a string, array, or map of length n given
+// the "fix" type for short ones, its maximum length, and the codes for
+// 8-, 16-, and 32-bit lengths; code8 is zero for arrays and maps, which
+// don't have an 8-bit form.
+func (m *msgpackEncoder) header(n int, fix byte, fixMax int, code8, code16, code32 byte) {
+	switch {
+	case n <= fixMax:
+		m.buf = append(m.buf, fix|byte(n))
+	case code8 != 0 && n <= math.MaxUint8:
+		m.buf = append(m.buf, code8, byte(n))
+	case n <= math.MaxUint16:
+		m.buf = binary.BigEndian.AppendUint16(append(m.buf, code16), uint16(n))
+	default:
+		m.buf = binary.BigEndian.AppendUint32(append(m.buf, code32), uint32(n))
+	}
+}
+
+func (m *msgpackEncoder) string(s string) {
+	m.header(len(s), 0xa0, 31, 0xd9, 0xda, 0xdb)
+	m.buf = append(m.buf, s...)
+}
+
+func (m *msgpackEncoder) arrayHeader(n int) { m.header(n, 0x90, 15, 0, 0xdc, 0xdd) }
+func (m *msgpackEncoder) mapHeader(n int)   { m.header(n, 0x80, 15, 0, 0xde, 0xdf) }
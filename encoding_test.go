@@ -0,0 +1,399 @@
+// encoding_test.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func encodeAs(t *testing.T, enc string, v any) []byte {
+	t.Helper()
+	defer func(e string) { *encoding = e }(*encoding)
+	*encoding = enc
+	b, err := encodeValue(v)
+	if err != nil {
+		t.Fatalf("%s: %v", enc, err)
+	}
+	return b
+}
+
+func seq(n int) []int {
+	s := make([]int, n)
+	for i := range s {
+		s[i] = i + 1
+	}
+	return s
+}
+
+type encodingTest struct {
+	v   any
+	hex string
+}
+
+// The examples from RFC 8949, Appendix A, that can be encoded with the
+// types we use; floats are always written at their Go precision, so only
+// the ones given as single or double precision there are included.
+var cborTests = []encodingTest{
+	{0, "00"},
+	{1, "01"},
+	{10, "0a"},
+	{23, "17"},
+	{24, "1818"},
+	{25, "1819"},
+	{100, "1864"},
+	{1000, "1903e8"},
+	{1000000, "1a000f4240"},
+	{1000000000000, "1b000000e8d4a51000"},
+	{uint64(18446744073709551615), "1bffffffffffffffff"},
+	{-1, "20"},
+	{-10, "29"},
+	{-100, "3863"},
+	{-1000, "3903e7"},
+	{1.1, "fb3ff199999999999a"},
+	{float32(100000.0), "fa47c35000"},
+	{float32(3.4028234663852886e+38), "fa7f7fffff"},
+	{1.0e+300, "fb7e37e43c8800759c"},
+	{-4.1, "fbc010666666666666"},
+	{float32(math.Inf(1)), "fa7f800000"},
+	{float32(math.Inf(-1)), "faff800000"},
+	{math.Inf(1), "fb7ff0000000000000"},
+	{false, "f4"},
+	{true, "f5"},
+	{nil, "f6"},
+	{"", "60"},
+	{"a", "6161"},
+	{"IETF", "6449455446"},
+	{"\"\\", "62225c"},
+	{"ü", "62c3bc"},
+	{"水", "63e6b0b4"},
+	{[]int{}, "80"},
+	{[]int{1, 2, 3}, "83010203"},
+	{[]any{1, []int{2, 3}, []int{4, 5}}, "8301820203820405"},
+	{seq(25), "98190102030405060708090a0b0c0d0e0f101112131415161718181819"},
+	{map[string]int{}, "a0"},
+	{map[string]any{"a": 1, "b": []int{2, 3}}, "a26161016162820203"},
+	{[]any{"a", map[string]string{"b": "c"}}, "826161a161626163"},
+	{map[string]string{"a": "A", "b": "B", "c": "C", "d": "D", "e": "E"},
+		"a56161614161626142616361436164614461656145"},
+	// Structs are maps keyed by their exported fields' names, in order.
+	{struct {
+		B int
+		a int
+		A []float32
+	}{1, 2, []float32{1.5}}, "a2614201614181fa3fc00000"},
+}
+
+// Examples following the MessagePack specification's format definitions,
+// at the boundaries between the forms of each type.
+var msgpackTests = []encodingTest{
+	{nil, "c0"},
+	{false, "c2"},
+	{true, "c3"},
+	{0, "00"},
+	{127, "7f"},
+	{128, "cc80"},
+	{255, "ccff"},
+	{256, "cd0100"},
+	{65535, "cdffff"},
+	{65536, "ce00010000"},
+	{4294967295, "ceffffffff"},
+	{4294967296, "cf0000000100000000"},
+	{uint64(18446744073709551615), "cfffffffffffffffff"},
+	{-1, "ff"},
+	{-32, "e0"},
+	{-33, "d0df"},
+	{-128, "d080"},
+	{-129, "d1ff7f"},
+	{-32768, "d18000"},
+	{-32769, "d2ffff7fff"},
+	{-2147483648, "d280000000"},
+	{-2147483649, "d3ffffffff7fffffff"},
+	{int64(math.MinInt64), "d38000000000000000"},
+	{float32(1.5), "ca3fc00000"},
+	{1.1, "cb3ff199999999999a"},
+	{"", "a0"},
+	{"a", "a161"},
+	{strings.Repeat("x", 31), "bf" + strings.Repeat("78", 31)},
+	{strings.Repeat("x", 32), "d920" + strings.Repeat("78", 32)},
+	{strings.Repeat("x", 255), "d9ff" + strings.Repeat("78", 255)},
+	{strings.Repeat("x", 256), "da0100" + strings.Repeat("78", 256)},
+	{strings.Repeat("x", 65536), "db00010000" + strings.Repeat("78", 65536)},
+	{[]int{}, "90"},
+	{[]int{1, 2, 3}, "93010203"},
+	{seq(15), "9f0102030405060708090a0b0c0d0e0f"},
+	{seq(16), "dc00100102030405060708090a0b0c0d0e0f10"},
+	{map[string]int{}, "80"},
+	{map[string]int{"a": 1}, "81a16101"},
+	{struct {
+		B int
+		a int
+		A []float32
+	}{1, 2, []float32{1.5}}, "82a14201a14191ca3fc00000"},
+}
+
+func TestEncodingVectors(t *testing.T) {
+	for _, enc := range []struct {
+		name  string
+		tests []encodingTest
+	}{{"cbor", cborTests}, {"msgpack", msgpackTests}} {
+		for _, test := range enc.tests {
+			got := hex.EncodeToString(encodeAs(t, enc.name, test.v))
+			if got != test.hex {
+				name := fmt.Sprintf("%#v", test.v)
+				if len(name) > 40 {
+					name = name[:40] + "..."
+				}
+				t.Errorf("%s: %s: got %s, want %s", enc.name, name, got, test.hex)
+			}
+		}
+	}
+}
+
+func TestEncodingRoundTrip(t *testing.T) {
+	maps := []STARSMap{
+		{
+			Group: 0,
+			Label: "JFK",
+			Name:  "JFK MAIN",
+			Id:    1,
+			Lines: [][]Point2LL{
+				{{-73.79, 40.6225}, {-73.7662, 40.6455}},
+				{{-73.7714, 40.6426}, {-73.7536, 40.6561}, {-73.75, 40.659}},
+			},
+			AlwaysVisible: true,
+			Labels:        []STARSMapLabel{{P: Point2LL{-73.7781, 40.6413}, Text: "KJFK"}},
+			MapGroups:     []STARSMapGroup{{Id: "N90", Position: 3, TCPs: []string{"2A", "2B"}}},
+			FormatVersion: 2,
+			Bounds:        []Point2LL{{-73.79, 40.6225}, {-73.75, 40.659}},
+			AIRAC:         "2410",
+			EffectiveDate: "2024-10-03",
+			LODs:          []STARSMapLOD{{Tolerance: 100, Lines: [][]Point2LL{{{-73.79, 40.6225}, {-73.75, 40.659}}}}},
+		},
+		{
+			Group:       1,
+			Label:       "LGA",
+			Name:        "LGA ü水",
+			Id:          -1,
+			Order:       300,
+			SharedLines: []STARSLineRef{{At: 0, Map: 0, Start: 1, Count: 1}},
+		},
+	}
+
+	for _, enc := range []string{"cbor", "msgpack"} {
+		b := encodeAs(t, enc, maps)
+		d := &testDecoder{b: b, msgpack: enc == "msgpack"}
+		got := d.value()
+		if d.err != nil {
+			t.Errorf("%s: %v", enc, d.err)
+		} else if d.off != len(b) {
+			t.Errorf("%s: %d bytes left over", enc, len(b)-d.off)
+		} else if want := genericValue(reflect.ValueOf(maps)); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v\nwant %v", enc, got, want)
+		}
+	}
+}
+
+// genericValue returns v as the decoder returns it: structs and maps
+// become map[string]any, slices []any, and numbers int64, uint64, or
+// float64.
+func genericValue(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Int() >= 0 {
+			return uint64(v.Int())
+		}
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.String:
+		return v.String()
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return []any{}
+		}
+		s := []any{}
+		for i := 0; i < v.Len(); i++ {
+			s = append(s, genericValue(v.Index(i)))
+		}
+		return s
+	case reflect.Map:
+		m := make(map[string]any)
+		for _, k := range v.MapKeys() {
+			m[k.String()] = genericValue(v.MapIndex(k))
+		}
+		return m
+	case reflect.Struct:
+		m := make(map[string]any)
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				m[v.Type().Field(i).Name] = genericValue(v.Field(i))
+			}
+		}
+		return m
+	default:
+		panic(v.Type().String())
+	}
+}
+
+// testDecoder decodes the subset of CBOR and MessagePack that
+// encodeValue writes.
+type testDecoder struct {
+	b       []byte
+	off     int
+	msgpack bool
+	err     error
+}
+
+func (d *testDecoder) bytes(n uint64) []byte {
+	if d.err != nil || n > uint64(len(d.b)-d.off) {
+		d.err = fmt.Errorf("truncated at %d", d.off)
+		return make([]byte, n)
+	}
+	b := d.b[d.off : d.off+int(n)]
+	d.off += int(n)
+	return b
+}
+
+func (d *testDecoder) uint(n int) uint64 {
+	b := d.bytes(uint64(n))
+	switch n {
+	case 1:
+		return uint64(b[0])
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b))
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b))
+	default:
+		return binary.BigEndian.Uint64(b)
+	}
+}
+
+func (d *testDecoder) array(n uint64) any {
+	s := []any{}
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		s = append(s, d.value())
+	}
+	return s
+}
+
+func (d *testDecoder) mapValue(n uint64) any {
+	m := make(map[string]any)
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		k, ok := d.value().(string)
+		if !ok {
+			d.err = fmt.Errorf("map key at %d isn't a string", d.off)
+			return nil
+		}
+		m[k] = d.value()
+	}
+	return m
+}
+
+func (d *testDecoder) value() any {
+	if d.msgpack {
+		return d.msgpackValue()
+	}
+	return d.cborValue()
+}
+
+func (d *testDecoder) cborValue() any {
+	ib := d.bytes(1)[0]
+	major, info := ib>>5, ib&0x1f
+	var n uint64
+	switch {
+	case info < 24:
+		n = uint64(info)
+	case info <= 27:
+		n = d.uint(1 << (info - 24))
+	default:
+		d.err = fmt.Errorf("unexpected additional info %d", info)
+		return nil
+	}
+
+	switch major {
+	case 0:
+		return n
+	case 1:
+		return -1 - int64(n)
+	case 3:
+		return string(d.bytes(n))
+	case 4:
+		return d.array(n)
+	case 5:
+		return d.mapValue(n)
+	case 7:
+		switch info {
+		case 20:
+			return false
+		case 21:
+			return true
+		case 22:
+			return nil
+		case 26:
+			return float64(math.Float32frombits(uint32(n)))
+		case 27:
+			return math.Float64frombits(n)
+		}
+	}
+	d.err = fmt.Errorf("unexpected initial byte %#x", ib)
+	return nil
+}
+
+func (d *testDecoder) msgpackValue() any {
+	c := d.bytes(1)[0]
+	switch {
+	case c < 0x80:
+		return uint64(c)
+	case c >= 0xe0:
+		return int64(int8(c))
+	case c&0xf0 == 0x80:
+		return d.mapValue(uint64(c & 0xf))
+	case c&0xf0 == 0x90:
+		return d.array(uint64(c & 0xf))
+	case c&0xe0 == 0xa0:
+		return string(d.bytes(uint64(c & 0x1f)))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil
+	case 0xc2:
+		return false
+	case 0xc3:
+		return true
+	case 0xca:
+		return float64(math.Float32frombits(uint32(d.uint(4))))
+	case 0xcb:
+		return math.Float64frombits(d.uint(8))
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return d.uint(1 << (c - 0xcc))
+	case 0xd0:
+		return int64(int8(d.uint(1)))
+	case 0xd1:
+		return int64(int16(d.uint(2)))
+	case 0xd2:
+		return int64(int32(d.uint(4)))
+	case 0xd3:
+		return int64(d.uint(8))
+	case 0xd9, 0xda, 0xdb:
+		return string(d.bytes(d.uint(1 << (c - 0xd9))))
+	case 0xdc, 0xdd:
+		return d.array(d.uint(2 << (c - 0xdc)))
+	case 0xde, 0xdf:
+		return d.mapValue(d.uint(2 << (c - 0xde)))
+	}
+	d.err = fmt.Errorf("unexpected type byte %#x", c)
+	return nil
+}
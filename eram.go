@@ -0,0 +1,118 @@
+// eram.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// ERAM GeoMaps
+
+// Note: these should match ERAMMap and ERAMMapGroup in vice's eram.go.
+type ERAMMap struct {
+	BcgName    string
+	LabelLine1 string
+	LabelLine2 string
+	Name       string
+	Lines      [][]Point2LL
+}
+
+type ERAMMapGroup struct {
+	Maps       []ERAMMap
+	LabelLine1 string
+	LabelLine2 string
+}
+
+// ERAMMapGroups is indexed by GeoMap name.
+type ERAMMapGroups map[string]ERAMMapGroup
+
+// convertERAMMaps converts all of the GeoMaps in the ARTCC's ERAM
+// configuration. Within each of a GeoMap's video maps, lines are grouped
+// into ERAMMaps according to their brightness control group (BCG) and
+// filter.
+func convertERAMMaps(artcc ARTCC, base string, centers []Point2LL) ERAMMapGroups {
+	specs := artcc.VideoMapSpecs()
+
+	groups := make(ERAMMapGroups)
+	artcc.Facility.Walk(func(fac *CRCFacility) {
+		if fac.ERAMConfiguration == nil {
+			return
+		}
+
+		for _, gm := range fac.ERAMConfiguration.GeoMaps {
+			group := ERAMMapGroup{LabelLine1: gm.LabelLine1, LabelLine2: gm.LabelLine2}
+
+			for _, id := range gm.VideoMapIds {
+				spec, ok := specs[id]
+				if !ok {
+					fmt.Printf("\r%s: warning: video map %s not found\n", gm.Name, id)
+					continue
+				}
+
+				maps := make(map[[2]int]int) // (bcg, filter) -> index into group.Maps
+				var defaults map[string]interface{}
+				for _, f := range readVideoMap(base, spec, centers) {
+					if b, _ := f.Properties["isLineDefaults"].(bool); b {
+						defaults = f.Properties
+						continue
+					}
+					if f.Geometry.Type != "LineString" {
+						continue
+					}
+
+					bcg, filter := eramLineBCG(f, defaults), eramLineFilter(f, defaults)
+					idx, ok := maps[[2]int{bcg, filter}]
+					if !ok {
+						em := ERAMMap{Name: spec.Name}
+						if bcg >= 1 && bcg <= len(gm.BCGMenu) {
+							em.BcgName = gm.BCGMenu[bcg-1].Label
+						}
+						if filter >= 1 && filter <= len(gm.FilterMenu) {
+							em.LabelLine1 = gm.FilterMenu[filter-1].LabelLine1
+							em.LabelLine2 = gm.FilterMenu[filter-1].LabelLine2
+						}
+						idx = len(group.Maps)
+						maps[[2]int{bcg, filter}] = idx
+						group.Maps = append(group.Maps, em)
+					}
+					group.Maps[idx].Lines = append(group.Maps[idx].Lines, featureLines([]GeoJSONFeature{f})...)
+				}
+			}
+
+			if _, ok := groups[gm.Name]; ok {
+				fmt.Printf("\r%s: warning: multiple GeoMaps with this name; using the last one\n", gm.Name)
+			}
+			groups[gm.Name] = group
+		}
+	})
+
+	return groups
+}
+
+// eramLineBCG returns the 1-based BCG index for the feature, falling back
+// to the map's line defaults if the feature doesn't specify one.
+func eramLineBCG(f GeoJSONFeature, defaults map[string]interface{}) int {
+	if bcg, ok := f.NumberProperty("bcg"); ok {
+		return int(bcg)
+	}
+	bcg, _ := defaults["bcg"].(float64)
+	return int(bcg)
+}
+
+// eramLineFilter returns the first of the 1-based filter indices for the
+// feature, again falling back to the line defaults.
+func eramLineFilter(f GeoJSONFeature, defaults map[string]interface{}) int {
+	filters, ok := f.Properties["filters"].([]interface{})
+	if !ok {
+		filters, _ = defaults["filters"].([]interface{})
+	}
+	if len(filters) > 0 {
+		if v, ok := filters[0].(float64); ok {
+			return int(v)
+		}
+	}
+	return 0
+}
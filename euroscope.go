@@ -0,0 +1,139 @@
+// euroscope.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// EuroScope .ese files
+
+// importESE converts the sector lines in the [AIRSPACE] section of a
+// EuroScope .ese file into a map. Named points used in CIRCLE_SECTORLINE
+// definitions are taken from the .sct file with the same name, if there
+// is one.
+func importESE(fn string) ([]STARSMap, error) {
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	sct := &sectorFile{points: make(map[string]Point2LL)}
+	sctfn := strings.TrimSuffix(fn, filepath.Ext(fn)) + ".sct"
+	if sb, err := os.ReadFile(sctfn); err == nil {
+		sct = parseSectorFile(sctfn, sb, false)
+	}
+
+	return parseESE(fn, b, sct), nil
+}
+
+func parseESE(fn string, b []byte, sct *sectorFile) []STARSMap {
+	sm := STARSMap{
+		Group: 1,
+		Label: "SECTORS",
+		Name:  strings.TrimSuffix(filepath.Base(fn), filepath.Ext(fn)) + " SECTORLINES",
+	}
+
+	var line []Point2LL
+	flush := func() {
+		if len(line) > 1 {
+			sm.Lines = append(sm.Lines, line)
+		}
+		line = nil
+	}
+
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(decodeText(b)))
+	for lineno := 1; scanner.Scan(); lineno++ {
+		text := scanner.Text()
+		if c := strings.Index(text, ";"); c != -1 {
+			text = text[:c]
+		}
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "[") {
+			flush()
+			section = strings.ToUpper(strings.Trim(text, "[]"))
+			continue
+		}
+		if section != "AIRSPACE" {
+			continue
+		}
+
+		f := strings.Split(text, ":")
+		switch strings.ToUpper(f[0]) {
+		case "SECTORLINE":
+			flush()
+
+		case "COORD":
+			if len(f) < 3 {
+				fmt.Printf("\r%s:%d: warning: expected COORD:lat:lon\n", fn, lineno)
+			} else if p, ok := sct.lookupLatLon(f[1], f[2]); !ok {
+				fmt.Printf("\r%s:%d: warning: unable to parse coordinates\n", fn, lineno)
+			} else {
+				line = append(line, p)
+			}
+
+		case "CIRCLE_SECTORLINE":
+			// CIRCLE_SECTORLINE:name:lat:lon:radius or
+			// CIRCLE_SECTORLINE:name:point:radius
+			flush()
+			var center Point2LL
+			var ok bool
+			var radius float64
+			var err error
+			if len(f) == 5 {
+				center, ok = sct.lookupLatLon(f[2], f[3])
+				radius, err = strconv.ParseFloat(f[4], 64)
+			} else if len(f) == 4 {
+				center, ok = sct.points[strings.ToUpper(f[2])]
+				radius, err = strconv.ParseFloat(f[3], 64)
+			}
+			if !ok || err != nil {
+				fmt.Printf("\r%s:%d: warning: unable to parse CIRCLE_SECTORLINE\n", fn, lineno)
+				continue
+			}
+			sm.Lines = append(sm.Lines, circleLL(center, float32(radius), 72))
+
+		default:
+			// Other definitions (SECTOR, OWNER, etc.) end the current
+			// sector line.
+			flush()
+		}
+	}
+	flush()
+
+	if len(sm.Lines) == 0 {
+		return nil
+	}
+	return []STARSMap{sm}
+}
+
+// circleLL returns a closed line approximating a circle with the given
+// radius in nautical miles around the given point.
+func circleLL(center Point2LL, radius float32, n int) []Point2LL {
+	const nmPerDegree = 60
+	nmPerLongitude := nmPerDegree * math.Cos(radians(center.Latitude()))
+
+	var circle []Point2LL
+	for i := 0; i <= n; i++ {
+		theta := 2 * math.Pi * float64(i%n) / float64(n)
+		circle = append(circle, Point2LL{
+			center[0] + float32(float64(radius)*math.Sin(theta)/nmPerLongitude),
+			center[1] + float32(float64(radius)*math.Cos(theta)/nmPerDegree),
+		})
+	}
+	return circle
+}
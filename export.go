@@ -0,0 +1,167 @@
+// export.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// export
+
+// exportGeoJSON writes each of the maps in the given GOB files to a
+// separate GeoJSON file. The files for ZNY-videomaps.gob go in the
+// directory ZNY-geojson.
+func exportGeoJSON(args []string) {
+	for _, fn := range args {
+		maps, err := readVideoMapGOB(fn)
+		errorExit(fn, err)
+
+		dir := videoMapStem(filepath.Base(fn)) + "-geojson"
+		errorExit(dir, os.MkdirAll(dir, 0o755))
+
+		used := make(map[string]bool)
+		for _, m := range maps {
+			file := uniqueFilename(used, sanitizeFilename(m.Name), ".geojson")
+			writeJSON(mapGeoJSON(m), filepath.Join(dir, file))
+		}
+	}
+	fmt.Printf("Done.\n")
+}
+
+// videoMapStem returns the base name that the given video map file was
+// written with, e.g. ZNY for ZNY-videomaps.gob.zst.
+func videoMapStem(fn string) string {
+	fn = strings.TrimSuffix(fn, ".zst")
+	fn = strings.TrimSuffix(fn, ".gob")
+	return strings.TrimSuffix(fn, "-videomaps")
+}
+
+// readVideoMapGOB reads the maps from a GOB file written by crc2vice,
+// which may be zstd-compressed and may hold either all of the maps or
+// just one, as with -per-map.
+func readVideoMapGOB(fn string) ([]STARSMap, error) {
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) >= 4 && binary.LittleEndian.Uint32(b) == zstdMagic {
+		if b, err = zstdDecompress(b); err != nil {
+			return nil, err
+		}
+	}
+
+	var maps []STARSMap
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&maps); err != nil {
+		var m STARSMap
+		if gob.NewDecoder(bytes.NewReader(b)).Decode(&m) != nil {
+			return nil, err
+		}
+		maps = []STARSMap{m}
+	}
+	return expandSharedLines(maps), nil
+}
+
+// geoJSONFeature is a GeoJSON Feature as written by the exporters.
+type geoJSONFeature struct {
+	Type     string `json:"type"`
+	Geometry struct {
+		Type        string `json:"type"`
+		Coordinates any    `json:"coordinates"`
+	} `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// mapFeatures returns the map's lines as LineStrings and its labels as
+// Points with CRC's "text" property. Each feature's properties start out
+// as a copy of props.
+func mapFeatures(m STARSMap, props map[string]interface{}) []geoJSONFeature {
+	newFeature := func(geom string, coords any) geoJSONFeature {
+		f := geoJSONFeature{Type: "Feature", Properties: make(map[string]interface{})}
+		for k, v := range props {
+			f.Properties[k] = v
+		}
+		f.Geometry.Type = geom
+		f.Geometry.Coordinates = coords
+		return f
+	}
+
+	features := []geoJSONFeature{}
+	for _, l := range m.Lines {
+		features = append(features, newFeature("LineString", l))
+	}
+	for _, l := range m.Labels {
+		f := newFeature("Point", l.P)
+		f.Properties["text"] = []string{l.Text}
+		features = append(features, f)
+	}
+	return features
+}
+
+// mapGeoJSON returns a GeoJSON FeatureCollection of the map's features.
+// The other map details are included as foreign members so that nothing
+// is lost.
+func mapGeoJSON(m STARSMap) any {
+	category := groupCategory(m.Group)
+	// RFC 7946's bbox is [west, south, east, north].
+	var bbox []float32
+	if len(m.Bounds) == 2 {
+		bbox = []float32{m.Bounds[0][0], m.Bounds[0][1], m.Bounds[1][0], m.Bounds[1][1]}
+	}
+	return struct {
+		Type          string           `json:"type"`
+		BBox          []float32        `json:"bbox,omitempty"`
+		Name          string           `json:"name"`
+		Label         string           `json:"label"`
+		Category      string           `json:"category"`
+		Id            int              `json:"id"`
+		AlwaysVisible bool             `json:"alwaysVisible,omitempty"`
+		MapGroups     []STARSMapGroup  `json:"mapGroups,omitempty"`
+		Features      []geoJSONFeature `json:"features"`
+	}{
+		Type:          "FeatureCollection",
+		BBox:          bbox,
+		Name:          m.Name,
+		Label:         m.Label,
+		Category:      category,
+		Id:            m.Id,
+		AlwaysVisible: m.AlwaysVisible,
+		MapGroups:     m.MapGroups,
+		Features:      mapFeatures(m, nil),
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////
+// GeoJSONL
+
+// writeGeoJSONL writes the features of all of the maps as newline-delimited
+// GeoJSON, one compact Feature per line, with the name, label, category,
+// and id of its map as properties.
+func writeGeoJSONL(maps []STARSMap, fn string) {
+	fmt.Printf("Writing %s... ", fn)
+	var buf bytes.Buffer
+	for _, m := range maps {
+		props := map[string]interface{}{
+			"name":     m.Name,
+			"label":    m.Label,
+			"category": groupCategory(m.Group),
+			"id":       m.Id,
+		}
+		for _, f := range mapFeatures(m, props) {
+			b, err := json.Marshal(f)
+			errorExit("JSON error", err)
+			buf.Write(b)
+			buf.WriteByte('\n')
+		}
+	}
+	errorExit("creating file", writeFileAtomic(fn, buf.Bytes(), 0o644))
+}
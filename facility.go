@@ -0,0 +1,173 @@
+// facility.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// CRC facility hierarchy
+
+// CRCFacility is a facility in a CRC ARTCC definition. The ARTCC itself
+// is the root and its children are TRACONs, towers, and so forth, which
+// may have children of their own.
+type CRCFacility struct {
+	Id              string        `json:"id"`
+	Type            string        `json:"type"` // "Artcc", "Tracon", "AtctTracon", "Atct", ...
+	Name            string        `json:"name"`
+	ChildFacilities []CRCFacility `json:"childFacilities"`
+
+	ERAMConfiguration     *CRCERAMConfiguration     `json:"eramConfiguration"`
+	STARSConfiguration    *CRCSTARSConfiguration    `json:"starsConfiguration"`
+	TowerCabConfiguration *CRCTowerCabConfiguration `json:"towerCabConfiguration"`
+	ASDEXConfiguration    *CRCASDEXConfiguration    `json:"asdexConfiguration"`
+}
+
+type CRCERAMConfiguration struct {
+	GeoMaps []CRCGeoMap `json:"geoMaps"`
+}
+
+type CRCGeoMap struct {
+	Id          string          `json:"id"`
+	Name        string          `json:"name"`
+	LabelLine1  string          `json:"labelLine1"`
+	LabelLine2  string          `json:"labelLine2"`
+	FilterMenu  []CRCFilterItem `json:"filterMenu"`
+	BCGMenu     []CRCBCGItem    `json:"bcgMenu"`
+	VideoMapIds []string        `json:"videoMapIds"`
+}
+
+type CRCFilterItem struct {
+	Id         string `json:"id"`
+	LabelLine1 string `json:"labelLine1"`
+	LabelLine2 string `json:"labelLine2"`
+}
+
+type CRCBCGItem struct {
+	Id    string `json:"id"`
+	Label string `json:"label"`
+}
+
+type CRCSTARSConfiguration struct {
+	VideoMapIds []string      `json:"videoMapIds"`
+	MapGroups   []CRCMapGroup `json:"mapGroups"`
+}
+
+// CRCMapGroup is a set of maps that are shown together in the DCB. MapIds
+// gives the map for each of its buttons in order; it may have nulls for
+// empty buttons. Maps are generally given by their STARS ids, though
+// video map ids are also accepted.
+type CRCMapGroup struct {
+	Id     string            `json:"id"`
+	MapIds []json.RawMessage `json:"mapIds"`
+	TCPs   []string          `json:"tcps"`
+}
+
+type CRCTowerCabConfiguration struct {
+	VideoMapId    string     `json:"videoMapId"`
+	TowerLocation *CRCLatLon `json:"towerLocation"`
+}
+
+type CRCASDEXConfiguration struct {
+	VideoMapId string `json:"videoMapId"`
+}
+
+// Walk calls the provided callback for the facility and then
+// recursively for all of its child facilities.
+func (f *CRCFacility) Walk(cb func(*CRCFacility)) {
+	cb(f)
+	for i := range f.ChildFacilities {
+		f.ChildFacilities[i].Walk(cb)
+	}
+}
+
+// Find returns the facility with the given id, searching the facility
+// and all of its descendants. Ids are matched case-insensitively.
+func (f *CRCFacility) Find(id string) *CRCFacility {
+	var found *CRCFacility
+	f.Walk(func(c *CRCFacility) {
+		if found == nil && strings.EqualFold(c.Id, id) {
+			found = c
+		}
+	})
+	return found
+}
+
+// STARSVideoMapIds returns the ids of all of the video maps referenced
+// by the STARS configurations of the facility and its descendants.
+func (f *CRCFacility) STARSVideoMapIds() []string {
+	var ids []string
+	f.Walk(func(c *CRCFacility) {
+		if c.STARSConfiguration != nil {
+			for _, id := range c.STARSConfiguration.VideoMapIds {
+				if !slices.Contains(ids, id) {
+					ids = append(ids, id)
+				}
+			}
+		}
+	})
+	return ids
+}
+
+// MapGroups returns the STARS map groups of the facility and its
+// descendants.
+func (f *CRCFacility) MapGroups() []CRCMapGroup {
+	var groups []CRCMapGroup
+	f.Walk(func(c *CRCFacility) {
+		if c.STARSConfiguration != nil {
+			groups = append(groups, c.STARSConfiguration.MapGroups...)
+		}
+	})
+	return groups
+}
+
+// Positions returns the DCB button positions of the given maps in the
+// group, indexed by video map id.
+func (g CRCMapGroup) Positions(maps []VideoMapSpec) map[string]int {
+	pos := make(map[string]int)
+	for i, raw := range g.MapIds {
+		var starsId int
+		var id string
+		if string(raw) == "null" {
+			continue
+		} else if json.Unmarshal(raw, &starsId) == nil {
+			if idx := slices.IndexFunc(maps, func(m VideoMapSpec) bool { return m.STARSId == starsId }); idx != -1 {
+				pos[maps[idx].Id] = i
+			}
+		} else if json.Unmarshal(raw, &id) == nil && id != "" {
+			pos[id] = i
+		}
+	}
+	return pos
+}
+
+// FacilityIds returns the ids of the facility and all of its
+// descendants.
+func (f *CRCFacility) FacilityIds() []string {
+	var ids []string
+	f.Walk(func(c *CRCFacility) { ids = append(ids, c.Id) })
+	return ids
+}
+
+// Scope returns the facility with the given id or the ARTCC's root
+// facility if no id is given.
+func (a *ARTCC) Scope(facilityId string) *CRCFacility {
+	if facilityId == "" {
+		return &a.Facility
+	}
+	return a.Facility.Find(facilityId)
+}
+
+// VideoMapSpecs returns the ARTCC's video maps, indexed by id.
+func (a *ARTCC) VideoMapSpecs() map[string]VideoMapSpec {
+	specs := make(map[string]VideoMapSpec)
+	for _, m := range a.VideoMaps {
+		specs[m.Id] = m
+	}
+	return specs
+}
@@ -0,0 +1,93 @@
+// fetch.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// cacheDirectory returns the directory used to cache downloaded files.
+func cacheDirectory() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "crc2vice"), nil
+}
+
+// fetchURL returns the contents of the given URL. Responses are cached
+// locally along with their ETag so that unchanged files aren't downloaded
+// again; if the server can't be reached, the cached copy is used.
+func fetchURL(url string) ([]byte, error) {
+	var cached, etagFn string
+	if dir, err := cacheDirectory(); err == nil {
+		h := sha256.Sum256([]byte(url))
+		cached = filepath.Join(dir, hex.EncodeToString(h[:]))
+		etagFn = cached + ".etag"
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cached != "" {
+		if etag, err := os.ReadFile(etagFn); err == nil {
+			if _, err := os.Stat(cached); err == nil {
+				req.Header.Set("If-None-Match", string(etag))
+			}
+		}
+	}
+
+	client := &http.Client{Timeout: 2 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		if cached != "" {
+			if b, cerr := os.ReadFile(cached); cerr == nil {
+				fmt.Printf("\r%s: warning: %v; using cached copy\n", url, err)
+				return b, nil
+			}
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return os.ReadFile(cached)
+
+	case http.StatusOK:
+		b, err := readInput(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		if cached != "" {
+			// Failing to cache isn't fatal.
+			if err := os.MkdirAll(filepath.Dir(cached), 0o755); err == nil {
+				if err := writeFileAtomic(cached, b, 0o644); err == nil {
+					if etag := resp.Header.Get("ETag"); etag != "" {
+						writeFileAtomic(etagFn, []byte(etag), 0o644)
+					} else {
+						os.Remove(etagFn)
+					}
+				}
+			}
+		}
+		return b, nil
+
+	default:
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+}
@@ -0,0 +1,156 @@
+// files.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// importers maps (lower case) filename extensions to functions that
+// convert files of that format into STARSMaps. GeoJSON files are handled
+// separately.
+var importers = map[string]func(fn string) ([]STARSMap, error){
+	".sct2":     importSCT2,
+	".sct":      importSCT2,
+	".ese":      importESE,
+	".zip":      importZip,
+	".xml":      importXML,
+	".gz":       importXML,
+	".dat":      importDAT,
+	".map":      importDAT,
+	".kml":      importKML,
+	".kmz":      importKML,
+	".shp":      importShapefile,
+	".gpkg":     importGeoPackage,
+	".topojson": importTopoJSON,
+	".fgb":      importFlatGeobuf,
+}
+
+// convertFiles converts loose files that aren't part of a CRC ARTCC and
+// writes them using the given output name. Each argument is a filename or
+// glob pattern, optionally preceded by "LABEL[,NAME[,CATEGORY]]=" to
+// specify the DCB label, name, and brightness category ("A" or "B") of
+// the resulting maps. By default, the label and name of GeoJSON maps are
+// taken from the filename and maps are in category A. Other formats may
+// hold multiple maps, in which case their own names are used.
+func convertFiles(outbase string, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "crctovice: no files specified\n")
+		os.Exit(1)
+	}
+
+	var maps []STARSMap
+	for _, arg := range args {
+		var label, name, category string
+		pattern := arg
+		if opts, p, ok := strings.Cut(arg, "="); ok {
+			f := strings.Split(opts, ",")
+			if len(f) > 3 {
+				fmt.Fprintf(os.Stderr, "%s: expected LABEL[,NAME[,CATEGORY]] before \"=\"\n", arg)
+				os.Exit(1)
+			}
+			label = f[0]
+			if len(f) > 1 {
+				name = f[1]
+			}
+			if len(f) > 2 {
+				category = strings.ToUpper(f[2])
+			}
+			pattern = p
+		}
+
+		filenames := []string{"-"} // standard input
+		if pattern != "-" {
+			var err error
+			filenames, err = filepath.Glob(pattern)
+			errorExit(pattern, err)
+			if len(filenames) == 0 {
+				fmt.Fprintf(os.Stderr, "%s: no such file\n", pattern)
+				os.Exit(1)
+			}
+		}
+
+		for _, fn := range filenames {
+			if imp, ok := importers[strings.ToLower(filepath.Ext(fn))]; ok {
+				errorExit(fn, checkInputSize(fn))
+				im, err := imp(fn)
+				errorExit(fn, err)
+				recordSourceFile(fn)
+
+				if len(im) == 1 {
+					if label != "" {
+						im[0].Label = label
+					}
+					if name != "" {
+						im[0].Name = name
+					}
+				}
+				for _, sm := range im {
+					if category != "" {
+						sm.Group = categoryGroup(category)
+					}
+					sm.Order = len(maps)
+					maps = append(maps, sm)
+				}
+				continue
+			}
+
+			stem := strings.TrimSuffix(filepath.Base(fn), filepath.Ext(fn))
+			if fn == "-" {
+				stem = "stdin"
+			}
+			m := VideoMapSpec{
+				Id:        fn,
+				Name:      name,
+				ShortName: label,
+				Category:  category,
+			}
+			if m.Name == "" || len(filenames) > 1 {
+				// Use the filename if there are multiple matches so
+				// that the maps have unique names.
+				m.Name = stem
+			}
+			if m.ShortName == "" {
+				m.ShortName = strings.ToUpper(stem)
+			}
+			if m.Category == "" {
+				m.Category = "A"
+			}
+
+			var file []byte
+			var err error
+			if fn == "-" {
+				file, err = readInput(os.Stdin)
+			} else {
+				file, err = readLocalInputFile(fn)
+			}
+			errorExit(fmt.Sprintf("%s: unable to read file", fn), err)
+			recordSource(fn, file)
+
+			if isTopoJSON(file) {
+				im, err := parseTopoJSON(file)
+				errorExit(fn, err)
+				for _, sm := range im {
+					sm.Group = categoryGroup(m.Category)
+					sm.Order = len(maps)
+					maps = append(maps, sm)
+				}
+				continue
+			}
+
+			sm := newSTARSMap(m, len(maps))
+			features := parseVideoMap(fn, file, m, nil)
+			checkSkippedFeatures(fn, m, features)
+			sm.Lines = featureLines(features)
+			maps = append(maps, sm)
+		}
+	}
+	fmt.Printf("\rRead %d maps\n", len(maps))
+
+	writeOutput(maps, outputPath(outbase))
+}
@@ -0,0 +1,310 @@
+// flatgeobuf.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// FlatGeobuf
+
+var fgbMagic = []byte{'f', 'g', 'b', 3, 'f', 'g', 'b'}
+
+// FlatGeobuf geometry types
+const (
+	fgbLineString      = 2
+	fgbPolygon         = 3
+	fgbMultiLineString = 5
+	fgbMultiPolygon    = 6
+	fgbCollection      = 7
+)
+
+// importFlatGeobuf converts a FlatGeobuf file into a single map, named
+// after the dataset or the file. Features are read one at a time so that
+// very large files don't need to be held in memory. Z and M values are
+// stored separately from the coordinates and are ignored.
+func importFlatGeobuf(fn string) ([]STARSMap, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stem := strings.TrimSuffix(filepath.Base(fn), filepath.Ext(fn))
+	sm := STARSMap{Group: 0, Label: strings.ToUpper(stem), Name: stem}
+	if err := readFlatGeobuf(bufio.NewReader(f), &sm); err != nil {
+		return nil, err
+	}
+	return []STARSMap{sm}, nil
+}
+
+func readFlatGeobuf(r io.Reader, sm *STARSMap) error {
+	var magic [8]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return err
+	}
+	if !bytes.Equal(magic[:len(fgbMagic)], fgbMagic) {
+		return fmt.Errorf("not a FlatGeobuf file")
+	}
+
+	hb, err := fgbReadSized(r)
+	if err != nil {
+		return err
+	}
+	header := fgbRoot(hb)
+	geomType := header.uint8(2, 0)
+	if name := header.string(0); name != "" {
+		sm.Name, sm.Label = name, strings.ToUpper(name)
+	}
+	var srs int32
+	if crs, ok := header.table(10); ok {
+		if org := crs.string(0); org == "" || strings.EqualFold(org, "EPSG") {
+			srs = crs.int32(1, 0)
+		}
+	}
+
+	// Skip the packed R-tree spatial index, if present. Its size is
+	// computed as in the reference implementation's PackedRTree::size;
+	// note that there's always a root node, even with a single feature.
+	count, nodeSize := header.uint64(8, 0), uint64(header.uint16(9, 16))
+	if count > 0 && nodeSize > 0 {
+		if count > math.MaxInt64/80 {
+			return fmt.Errorf("%d: invalid feature count", count)
+		}
+		nodeSize = max(nodeSize, 2)
+		nodes, n := count, count
+		for {
+			n = (n + nodeSize - 1) / nodeSize
+			nodes += n
+			if n == 1 {
+				break
+			}
+		}
+		if _, err := io.CopyN(io.Discard, r, int64(nodes*40)); err != nil {
+			return err
+		}
+	}
+
+	for {
+		fb, err := fgbReadSized(r)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if g, ok := fgbRoot(fb).table(0); ok {
+			if err := fgbGeometryLines(g, geomType, &sm.Lines); err != nil {
+				return err
+			}
+		}
+	}
+
+	if srs > 0 && srs != 4326 {
+		return reproject(sm.Lines, namedCRS(fmt.Sprintf("EPSG:%d", srs)), "")
+	}
+	return nil
+}
+
+// fgbReadSized reads a size-prefixed flatbuffer.
+func fgbReadSized(r io.Reader) ([]byte, error) {
+	var size uint32
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return nil, err
+	}
+	// Read incrementally rather than allocating the given size up front,
+	// so that a corrupt size doesn't make us allocate gigabytes.
+	b, err := io.ReadAll(io.LimitReader(r, int64(size)))
+	if err != nil {
+		return nil, err
+	} else if len(b) < int(size) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if size < 4 {
+		return nil, fmt.Errorf("truncated flatbuffer")
+	}
+	return b, nil
+}
+
+// fgbGeometryLines appends the lines and polygon rings of a Geometry table
+// to lines; geomType is the header's geometry type, which applies if the
+// geometry doesn't specify its own.
+func fgbGeometryLines(g fgbTable, geomType uint8, lines *[][]Point2LL) error {
+	if t := g.uint8(6, 0); t != 0 {
+		geomType = t
+	}
+
+	switch geomType {
+	case fgbLineString, fgbPolygon, fgbMultiLineString:
+		xy, ok := g.vector(1, 8)
+		if !ok {
+			return nil
+		}
+		pt := func(i int) Point2LL {
+			x := math.Float64frombits(binary.LittleEndian.Uint64(xy.elt(2 * i)))
+			y := math.Float64frombits(binary.LittleEndian.Uint64(xy.elt(2*i + 1)))
+			return Point2LL{float32(x), float32(y)}
+		}
+		ends := []int{xy.n / 2}
+		if ev, ok := g.vector(0, 4); ok && ev.n > 0 {
+			ends = ends[:0]
+			for i := 0; i < ev.n; i++ {
+				ends = append(ends, int(binary.LittleEndian.Uint32(ev.elt(i))))
+			}
+		}
+		start := 0
+		for _, end := range ends {
+			if end > xy.n/2 || end < start {
+				return fmt.Errorf("invalid geometry ends")
+			}
+			if end-start > 1 {
+				var l []Point2LL
+				for i := start; i < end; i++ {
+					l = append(l, pt(i))
+				}
+				*lines = append(*lines, l)
+			}
+			start = end
+		}
+
+	case fgbMultiPolygon, fgbCollection:
+		parts, ok := g.vector(7, 4)
+		if !ok {
+			return nil
+		}
+		// MultiPolygon parts are Polygons; collections must give the
+		// types of their parts.
+		partType := uint8(0)
+		if geomType == fgbMultiPolygon {
+			partType = fgbPolygon
+		}
+		for i := 0; i < parts.n; i++ {
+			if err := fgbGeometryLines(parts.table(i), partType, lines); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////
+// flatbuffers
+//
+// Just enough of the flatbuffers encoding to read FlatGeobuf files. Out of
+// range offsets cause a panic, which is recovered from by returning an
+// empty table or vector.
+
+type fgbTable struct {
+	b   []byte
+	pos int
+}
+
+type fgbVector struct {
+	b       []byte
+	pos, n  int
+	eltSize int
+}
+
+func fgbRoot(b []byte) fgbTable {
+	return fgbTable{b: b, pos: int(binary.LittleEndian.Uint32(b))}
+}
+
+// field returns the position of the given field, or 0 if it's absent.
+func (t fgbTable) field(i int) (p int) {
+	defer func() {
+		if recover() != nil {
+			p = 0
+		}
+	}()
+	vt := t.pos - int(int32(binary.LittleEndian.Uint32(t.b[t.pos:])))
+	vtSize := int(binary.LittleEndian.Uint16(t.b[vt:]))
+	if 4+2*i+2 > vtSize {
+		return 0
+	}
+	if off := int(binary.LittleEndian.Uint16(t.b[vt+4+2*i:])); off != 0 {
+		return t.pos + off
+	}
+	return 0
+}
+
+func (t fgbTable) uint8(i int, def uint8) uint8 {
+	if p := t.field(i); p != 0 && p < len(t.b) {
+		return t.b[p]
+	}
+	return def
+}
+
+func (t fgbTable) uint16(i int, def uint16) uint16 {
+	if p := t.field(i); p != 0 && p+2 <= len(t.b) {
+		return binary.LittleEndian.Uint16(t.b[p:])
+	}
+	return def
+}
+
+func (t fgbTable) int32(i int, def int32) int32 {
+	if p := t.field(i); p != 0 && p+4 <= len(t.b) {
+		return int32(binary.LittleEndian.Uint32(t.b[p:]))
+	}
+	return def
+}
+
+func (t fgbTable) uint64(i int, def uint64) uint64 {
+	if p := t.field(i); p != 0 && p+8 <= len(t.b) {
+		return binary.LittleEndian.Uint64(t.b[p:])
+	}
+	return def
+}
+
+// indirect follows the offset stored in the given field.
+func (t fgbTable) indirect(i int) int {
+	if p := t.field(i); p != 0 && p+4 <= len(t.b) {
+		if q := p + int(binary.LittleEndian.Uint32(t.b[p:])); q+4 <= len(t.b) {
+			return q
+		}
+	}
+	return 0
+}
+
+func (t fgbTable) table(i int) (fgbTable, bool) {
+	p := t.indirect(i)
+	return fgbTable{b: t.b, pos: p}, p != 0
+}
+
+func (t fgbTable) vector(i int, eltSize int) (fgbVector, bool) {
+	p := t.indirect(i)
+	if p == 0 {
+		return fgbVector{}, false
+	}
+	n := int(binary.LittleEndian.Uint32(t.b[p:]))
+	if p+4+n*eltSize > len(t.b) {
+		return fgbVector{}, false
+	}
+	return fgbVector{b: t.b, pos: p + 4, n: n, eltSize: eltSize}, true
+}
+
+func (t fgbTable) string(i int) string {
+	if v, ok := t.vector(i, 1); ok {
+		return string(v.b[v.pos : v.pos+v.n])
+	}
+	return ""
+}
+
+func (v fgbVector) elt(i int) []byte {
+	return v.b[v.pos+i*v.eltSize:]
+}
+
+// table returns the i'th element of a vector of tables.
+func (v fgbVector) table(i int) fgbTable {
+	p := v.pos + i*4
+	return fgbTable{b: v.b, pos: p + int(binary.LittleEndian.Uint32(v.b[p:]))}
+}
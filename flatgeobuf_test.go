@@ -0,0 +1,92 @@
+// flatgeobuf_test.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The files in testdata/fgb are written by gen.py there.
+
+func TestFlatGeobufFixtures(t *testing.T) {
+	ring := []Point2LL{{-74, 40}, {-73, 40}, {-73, 41}, {-74, 40}}
+	hole := []Point2LL{{-73.8, 40.2}, {-73.2, 40.2}, {-73.2, 40.8}, {-73.8, 40.2}}
+	var lines [][]Point2LL
+	for i := 0; i < 20; i++ {
+		f := float64(i)
+		lines = append(lines, []Point2LL{
+			{float32(-74 + f/100), 40}, {float32(-74 + f/100), 40.5}, {float32(-73.9 + f/100), 40.5}})
+	}
+
+	for _, test := range []struct {
+		file, name string
+		lines      [][]Point2LL
+		tol        float64 // for reprojected coordinates
+	}{
+		// One feature, whose index has two nodes.
+		{"one.fgb", "boundary", [][]Point2LL{ring}, 0},
+		{"lines.fgb", "lines", lines, 0},
+		{"lines4.fgb", "lines", lines, 0},
+		// No index; the points are ignored.
+		{"mixed.fgb", "mixed", [][]Point2LL{
+			ring, hole,
+			{{-72, 39}, {-71, 39}}, {{-71, 38}, {-70, 38}},
+			ring, hole,
+		}, 0},
+		{"utm.fgb", "utm", [][]Point2LL{{{-75, 0}, {-75, 40}}}, 1e-4},
+	} {
+		t.Run(test.file, func(t *testing.T) {
+			maps, err := importFlatGeobuf(filepath.Join("testdata", "fgb", test.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(maps) != 1 {
+				t.Fatalf("got %d maps, want 1", len(maps))
+			}
+			m := maps[0]
+			if m.Name != test.name {
+				t.Errorf("got name %q, want %q", m.Name, test.name)
+			}
+			if len(m.Lines) != len(test.lines) {
+				t.Fatalf("got %d lines, want %d", len(m.Lines), len(test.lines))
+			}
+			for i := range test.lines {
+				if !pointsNear(m.Lines[i], test.lines[i], test.tol) {
+					t.Errorf("line %d: got %v, want %v", i, m.Lines[i], test.lines[i])
+				}
+			}
+		})
+	}
+}
+
+// Truncated and corrupted files should give errors, not panics.
+func TestFlatGeobufCorrupt(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("testdata", "fgb", "mixed.fgb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for _, n := range []int{0, 4, 8, 12, 100, len(b) - 1} {
+		fn := filepath.Join(dir, "truncated.fgb")
+		if err := os.WriteFile(fn, b[:n], 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := importFlatGeobuf(fn); err == nil {
+			t.Errorf("truncated to %d bytes: expected an error", n)
+		}
+	}
+
+	for off := 8; off < len(b); off++ {
+		c := append([]byte(nil), b...)
+		c[off] ^= 0xff
+		fn := filepath.Join(dir, "corrupt.fgb")
+		if err := os.WriteFile(fn, c, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		importFlatGeobuf(fn)
+	}
+}
@@ -0,0 +1,72 @@
+// git.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gitCheckout fetches the given ref (a branch, tag, or commit; "HEAD" for
+// the default branch) of a Git repository into a checkout in the cache
+// directory and returns the checkout's path. Repeated runs update the
+// existing checkout; if the fetch fails but the repository was fetched
+// previously, the existing checkout is used. The git command must be
+// installed.
+func gitCheckout(url, ref string) (string, error) {
+	cache, err := cacheDirectory()
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256([]byte(url))
+	dir := filepath.Join(cache, "git", hex.EncodeToString(h[:8]))
+
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return "", err
+		}
+		if err := runGit(dir, "init", "-q"); err != nil {
+			return "", err
+		}
+		if err := runGit(dir, "remote", "add", "origin", url); err != nil {
+			return "", err
+		}
+	} else if err := runGit(dir, "remote", "set-url", "origin", url); err != nil {
+		return "", err
+	}
+
+	fmt.Printf("Fetching %s %s\n", url, ref)
+	if err := runGit(dir, "fetch", "-q", "--depth", "1", "origin", ref); err != nil {
+		if runGit(dir, "rev-parse", "-q", "--verify", "HEAD") != nil {
+			return "", err
+		}
+		fmt.Printf("\r%s: warning: %v; using previously fetched files\n", url, err)
+		return dir, nil
+	}
+	if err := runGit(dir, "checkout", "-q", "--force", "--detach", "FETCH_HEAD"); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+func runGit(dir string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n"); msg != "" {
+			return fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return nil
+}
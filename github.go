@@ -0,0 +1,75 @@
+// github.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+type gitHubRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// openGitHubRelease returns a file system for the .zip archive attached
+// to a GitHub release; spec is "OWNER/REPO" for the latest release or
+// "OWNER/REPO@TAG" for a specific one. If the release has multiple .zip
+// assets, the one whose name includes the ARTCC's is used.
+func openGitHubRelease(spec, base string) (fs.FS, error) {
+	repo, tag, _ := strings.Cut(spec, "@")
+	if strings.Count(repo, "/") != 1 {
+		return nil, fmt.Errorf("expected OWNER/REPO[@TAG]")
+	}
+
+	url := strings.TrimSuffix(*gitHubAPI, "/") + "/repos/" + repo + "/releases/"
+	if tag == "" {
+		url += "latest"
+	} else {
+		url += "tags/" + tag
+	}
+	b, err := fetchURL(url)
+	if err != nil {
+		return nil, err
+	}
+	var rel gitHubRelease
+	if err := json.Unmarshal(b, &rel); err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+
+	var asset string
+	for _, a := range rel.Assets {
+		if !strings.EqualFold(path.Ext(a.Name), ".zip") {
+			continue
+		}
+		if strings.Contains(strings.ToUpper(a.Name), strings.ToUpper(base)) {
+			asset = a.URL
+			break
+		} else if asset == "" {
+			asset = a.URL
+		}
+	}
+	if asset == "" {
+		return nil, fmt.Errorf("%s: release has no .zip assets", rel.TagName)
+	}
+
+	fmt.Printf("Using %s release %s: %s\n", repo, rel.TagName, asset)
+	if b, err = fetchURL(asset); err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", asset, err)
+	}
+	return zipRoot(zr, base)
+}
@@ -0,0 +1,234 @@
+// gpkg.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// GeoPackage
+
+// importGeoPackage converts the feature layers of a GeoPackage into maps,
+// one per layer. If -gpkg-layer is given, only the specified layers are
+// converted.
+func importGeoPackage(fn string) ([]STARSMap, error) {
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	db, err := openSQLite(b)
+	if err != nil {
+		return nil, err
+	}
+
+	gc, err := db.Table("gpkg_geometry_columns")
+	if err != nil {
+		return nil, err
+	}
+	tcol, ccol, scol := gc.Column("table_name"), gc.Column("column_name"), gc.Column("srs_id")
+	if tcol == -1 || ccol == -1 || scol == -1 {
+		return nil, fmt.Errorf("gpkg_geometry_columns: unexpected schema")
+	}
+
+	type layer struct {
+		table, column string
+		srs           int64
+	}
+	var layers []layer
+	err = db.Scan(gc, func(row []any) error {
+		t, _ := row[tcol].(string)
+		c, _ := row[ccol].(string)
+		srs, _ := row[scol].(int64)
+		layers = append(layers, layer{table: t, column: c, srs: srs})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range gpkgLayers {
+		if !slices.ContainsFunc(layers, func(ly layer) bool { return strings.EqualFold(ly.table, l) }) {
+			return nil, fmt.Errorf("%s: layer not found", l)
+		}
+	}
+
+	var maps []STARSMap
+	for _, l := range layers {
+		if len(gpkgLayers) > 0 && !slices.ContainsFunc(gpkgLayers, func(s string) bool { return strings.EqualFold(s, l.table) }) {
+			continue
+		}
+
+		t, err := db.Table(l.table)
+		if err != nil {
+			return nil, err
+		}
+		col := t.Column(l.column)
+		if col == -1 {
+			return nil, fmt.Errorf("%s: geometry column %q not found", l.table, l.column)
+		}
+
+		sm := STARSMap{Group: 0, Label: strings.ToUpper(l.table), Name: l.table}
+		err = db.Scan(t, func(row []any) error {
+			if blob, ok := row[col].([]byte); ok {
+				lines, err := gpkgLines(blob)
+				if err != nil {
+					return fmt.Errorf("%s: %w", l.table, err)
+				}
+				sm.Lines = append(sm.Lines, lines...)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// GeoPackage SRS ids are generally EPSG codes; 4326 and the
+		// undefined ones (0, -1) don't need reprojection.
+		if l.srs > 0 && l.srs != 4326 {
+			if err := reproject(sm.Lines, namedCRS(fmt.Sprintf("EPSG:%d", l.srs)), ""); err != nil {
+				return nil, fmt.Errorf("%s: %w", l.table, err)
+			}
+		}
+
+		maps = append(maps, sm)
+	}
+
+	return maps, nil
+}
+
+// gpkgLines returns the lines in a GeoPackage geometry blob, which is a
+// small header followed by WKB.
+func gpkgLines(b []byte) ([][]Point2LL, error) {
+	if len(b) < 8 || b[0] != 'G' || b[1] != 'P' {
+		return nil, fmt.Errorf("invalid GeoPackage geometry")
+	}
+	flags := b[3]
+	if flags&0x20 != 0 {
+		return nil, fmt.Errorf("extended GeoPackage geometries are not supported")
+	}
+	envelope := []int{0, 32, 48, 48, 64}
+	e := int(flags>>1) & 7
+	if e >= len(envelope) {
+		return nil, fmt.Errorf("invalid GeoPackage envelope")
+	}
+	off := 8 + envelope[e]
+	if flags&0x10 != 0 || off > len(b) { // empty
+		return nil, nil
+	}
+	return wkbLines(b[off:])
+}
+
+// wkbLines returns the LineStrings and polygon rings in well-known binary
+// (WKB) geometry; points are ignored.
+func wkbLines(b []byte) ([][]Point2LL, error) {
+	r := &wkbReader{b: b}
+	var lines [][]Point2LL
+	r.geometry(&lines, 0)
+	return lines, r.err
+}
+
+type wkbReader struct {
+	b     []byte
+	off   int
+	order binary.ByteOrder
+	err   error
+}
+
+func (r *wkbReader) uint32() uint32 {
+	if r.err != nil || r.off+4 > len(r.b) {
+		r.err = fmt.Errorf("truncated WKB geometry")
+		return 0
+	}
+	v := r.order.Uint32(r.b[r.off:])
+	r.off += 4
+	return v
+}
+
+func (r *wkbReader) float64() float64 {
+	if r.err != nil || r.off+8 > len(r.b) {
+		r.err = fmt.Errorf("truncated WKB geometry")
+		return 0
+	}
+	v := math.Float64frombits(r.order.Uint64(r.b[r.off:]))
+	r.off += 8
+	return v
+}
+
+func (r *wkbReader) points(dims int) []Point2LL {
+	n := int(r.uint32())
+	if r.err == nil && r.off+n*dims*8 > len(r.b) {
+		r.err = fmt.Errorf("truncated WKB geometry")
+	}
+	var pts []Point2LL
+	for i := 0; i < n && r.err == nil; i++ {
+		x, y := r.float64(), r.float64()
+		for j := 2; j < dims; j++ {
+			r.float64() // z, m
+		}
+		pts = append(pts, Point2LL{float32(x), float32(y)})
+	}
+	return pts
+}
+
+func (r *wkbReader) geometry(lines *[][]Point2LL, depth int) {
+	if r.err != nil {
+		return
+	} else if depth > 16 || r.off >= len(r.b) {
+		r.err = fmt.Errorf("invalid WKB geometry")
+		return
+	}
+	if r.b[r.off] == 0 {
+		r.order = binary.BigEndian
+	} else {
+		r.order = binary.LittleEndian
+	}
+	r.off++
+
+	// Handle both ISO (+1000 for Z, etc.) and EWKB (high bits) variants.
+	t := r.uint32()
+	dims := 2
+	if t&0x80000000 != 0 {
+		dims++
+	}
+	if t&0x40000000 != 0 {
+		dims++
+	}
+	t &= 0x0fffffff
+	switch t / 1000 {
+	case 1, 2:
+		dims = 3
+	case 3:
+		dims = 4
+	}
+
+	switch t % 1000 {
+	case 1: // Point
+		r.off += 8 * dims
+	case 2: // LineString
+		if l := r.points(dims); len(l) > 1 {
+			*lines = append(*lines, l)
+		}
+	case 3: // Polygon
+		n := int(r.uint32())
+		for i := 0; i < n && r.err == nil; i++ {
+			if l := r.points(dims); len(l) > 1 {
+				*lines = append(*lines, l)
+			}
+		}
+	case 4, 5, 6, 7: // Multi* and GeometryCollection
+		n := int(r.uint32())
+		for i := 0; i < n && r.err == nil; i++ {
+			r.geometry(lines, depth+1)
+		}
+	default:
+		r.err = fmt.Errorf("unsupported WKB geometry type %d", t)
+	}
+}
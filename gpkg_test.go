@@ -0,0 +1,172 @@
+// gpkg_test.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testdata/gpkg/layers.gpkg is written by SQLite; see gen.py there for
+// how, and for the geometry of the "grid" layer's lines.
+
+const gpkgFixture = "testdata/gpkg/layers.gpkg"
+
+func gpkgGridLines() [][]Point2LL {
+	var lines [][]Point2LL
+	for i := 0; i < 2000; i++ {
+		f := float64(i)
+		lines = append(lines, []Point2LL{
+			{float32(-74 + f/1000), float32(40 + f/2000)},
+			{float32(-73.5 + f/1000), float32(40.25 + f/2000)},
+		})
+	}
+	var long []Point2LL
+	for i := 0; i < 3000; i++ {
+		long = append(long, Point2LL{float32(-75 + float64(i)/10000), float32(41 + float64(i%7)/100)})
+	}
+	return append(lines, long)
+}
+
+func TestGeoPackageLayers(t *testing.T) {
+	maps, err := importGeoPackage(gpkgFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		name  string
+		lines [][]Point2LL
+		tol   float64 // for reprojected coordinates
+	}{
+		{"runways", [][]Point2LL{
+			{{-73.7900, 40.6225}, {-73.7662, 40.6455}},
+			{{-73.7794, 40.6279}, {-73.7583, 40.6513}},
+			{{-73.7714, 40.6426}, {-73.7536, 40.6561}, {-73.7500, 40.6590}},
+		}, 0},
+		{"airspace", [][]Point2LL{
+			{{-74, 40}, {-73, 40}, {-73, 41}, {-74, 40}},
+			{{-73.8, 40.2}, {-73.2, 40.2}, {-73.2, 40.8}, {-73.8, 40.2}},
+			{{-72, 39}, {-71, 39}},
+			{{-71, 39}, {-71, 38}},
+			{{-70, 35}, {-69, 35}, {-69, 36}},
+		}, 0},
+		{"grid", gpkgGridLines(), 0},
+		// On zone 18's central meridian, at the equator and at 40N.
+		{"utm", [][]Point2LL{{{-75, 0}, {-75, 40}}}, 1e-4},
+	}
+
+	if len(maps) != len(want) {
+		t.Fatalf("got %d maps, want %d", len(maps), len(want))
+	}
+	for i, w := range want {
+		m := maps[i]
+		if m.Name != w.name {
+			t.Errorf("map %d: got name %q, want %q", i, m.Name, w.name)
+			continue
+		}
+		if len(m.Lines) != len(w.lines) {
+			t.Errorf("%s: got %d lines, want %d", w.name, len(m.Lines), len(w.lines))
+			continue
+		}
+		for j := range w.lines {
+			if !pointsNear(m.Lines[j], w.lines[j], w.tol) {
+				t.Errorf("%s: line %d: got %v, want %v", w.name, j, m.Lines[j], w.lines[j])
+			}
+		}
+	}
+}
+
+// Geometry blobs built following the GeoPackage specification, section
+// 2.1.3, independently of gen.py.
+func TestGeoPackageGeometryHeader(t *testing.T) {
+	line := []byte{1, 2, 0, 0, 0, 2, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0xf0, 0x3f, 0, 0, 0, 0, 0, 0, 0, 0x40, // (1, 2)
+		0, 0, 0, 0, 0, 0, 0x08, 0x40, 0, 0, 0, 0, 0, 0, 0x10, 0x40} // (3, 4)
+	emptyLine := []byte{1, 2, 0, 0, 0, 0, 0, 0, 0}
+	header := func(flags byte) []byte { return []byte{'G', 'P', 0, flags, 0xe6, 0x10, 0, 0} }
+
+	for _, test := range []struct {
+		name  string
+		blob  []byte
+		lines int
+		err   bool
+	}{
+		{"standard", append(header(0x01), line...), 1, false},
+		{"envelope", append(append(header(0x03), make([]byte, 32)...), line...), 1, false},
+		{"empty", append(header(0x11), emptyLine...), 0, false},
+		{"empty with envelope", append(append(header(0x13), make([]byte, 32)...), emptyLine...), 0, false},
+		{"extended", append(header(0x21), line...), 0, true},
+		{"invalid envelope", append(header(0x0b), line...), 0, true},
+		{"not GP", append([]byte{'X', 'P', 0, 1, 0, 0, 0, 0}, line...), 0, true},
+	} {
+		lines, err := gpkgLines(test.blob)
+		if (err != nil) != test.err || len(lines) != test.lines {
+			t.Errorf("%s: got %d lines, error %v", test.name, len(lines), err)
+		}
+	}
+}
+
+func pointsNear(a, b []Point2LL, tol float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		for c := 0; c < 2; c++ {
+			if math.Abs(float64(a[i][c]-b[i][c])) > tol {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestGeoPackageLayerFlag(t *testing.T) {
+	defer func(l stringList) { gpkgLayers = l }(gpkgLayers)
+
+	gpkgLayers = stringList{"UTM", "runways"}
+	maps, err := importGeoPackage(gpkgFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(maps) != 2 || maps[0].Name != "runways" || maps[1].Name != "utm" {
+		t.Errorf("got %d maps, want runways and utm", len(maps))
+	}
+
+	gpkgLayers = stringList{"taxiways"}
+	if _, err := importGeoPackage(gpkgFixture); err == nil {
+		t.Errorf("expected an error for a missing layer")
+	}
+}
+
+// Truncated and corrupted files should give errors, not panics.
+func TestGeoPackageCorrupt(t *testing.T) {
+	b, err := os.ReadFile(gpkgFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for i, n := range []int{0, 50, 100, 1024, 5000, len(b) / 2} {
+		fn := filepath.Join(dir, "truncated.gpkg")
+		if err := os.WriteFile(fn, b[:n], 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := importGeoPackage(fn); err == nil && i < 3 {
+			t.Errorf("truncated to %d bytes: expected an error", n)
+		}
+	}
+
+	for off := 100; off < len(b); off += 997 {
+		c := append([]byte(nil), b...)
+		c[off] ^= 0xff
+		fn := filepath.Join(dir, "corrupt.gpkg")
+		if err := os.WriteFile(fn, c, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		importGeoPackage(fn)
+	}
+}
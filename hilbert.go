@@ -0,0 +1,93 @@
+// hilbert.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// Spatial ordering
+//
+// With -sort-lines, each map's lines are reordered along a Hilbert curve
+// through the centers of their bounding boxes, so that lines that are
+// near each other are also near each other in the file and in memory.
+// That improves cache behavior when vice draws large maps and lets it
+// cull ranges of lines that are off-screen. A map's lines are all drawn
+// the same way, so their order doesn't otherwise matter.
+
+// hilbertOrder is the order of the Hilbert curve used; it has 2^16 cells
+// in each dimension across the map's bounds.
+const hilbertOrder = 16
+
+// hilbertIndex returns the distance along the Hilbert curve of the given
+// order to the cell (x, y).
+func hilbertIndex(x, y uint32, order uint) uint64 {
+	var d uint64
+	for s := uint32(1) << (order - 1); s > 0; s /= 2 {
+		var rx, ry uint32
+		if x&s != 0 {
+			rx = 1
+		}
+		if y&s != 0 {
+			ry = 1
+		}
+		d += uint64(s) * uint64(s) * uint64((3*rx)^ry)
+		// Rotate the quadrant so that the curve is continuous.
+		if ry == 0 {
+			if rx == 1 {
+				x, y = s-1-x&(s-1), s-1-y&(s-1)
+			}
+			x, y = y, x
+		}
+	}
+	return d
+}
+
+// sortLines returns the lines sorted along a Hilbert curve through the
+// centers of their bounding boxes. The lines aren't modified.
+func sortLines(lines [][]Point2LL) [][]Point2LL {
+	if len(lines) < 2 {
+		return lines
+	}
+
+	bounds := LinesExtent(lines)
+	w, h := bounds.P1[0]-bounds.P0[0], bounds.P1[1]-bounds.P0[1]
+	const n = 1<<hilbertOrder - 1
+	type keyed struct {
+		line []Point2LL
+		key  uint64
+	}
+	k := make([]keyed, len(lines))
+	for i, l := range lines {
+		c := LinesExtent([][]Point2LL{l}).Center()
+		var x, y uint32
+		if w > 0 {
+			x = uint32(n * (c[0] - bounds.P0[0]) / w)
+		}
+		if h > 0 {
+			y = uint32(n * (c[1] - bounds.P0[1]) / h)
+		}
+		k[i] = keyed{line: l, key: hilbertIndex(min(x, n), min(y, n), hilbertOrder)}
+	}
+	slices.SortStableFunc(k, func(a, b keyed) int { return cmp.Compare(a.key, b.key) })
+	return MapSlice(k, func(k keyed) []Point2LL { return k.line })
+}
+
+// sortMapLines returns the maps with their lines sorted for -sort-lines.
+func sortMapLines(maps []STARSMap) []STARSMap {
+	if !*sortLinesFlag {
+		return maps
+	}
+
+	maps = slices.Clone(maps)
+	for i := range maps {
+		maps[i].Lines = sortLines(maps[i].Lines)
+	}
+	fmt.Printf("Sorted lines along a Hilbert curve\n")
+	return maps
+}
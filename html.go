@@ -0,0 +1,121 @@
+// html.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"path/filepath"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// HTML viewer
+
+// writeHTML writes a standalone web page that shows the maps as layers
+// over a basemap using Leaflet; each map can be toggled on and off. The
+// map data is included in the page but Leaflet and the basemap tiles are
+// loaded from the web.
+func writeHTML(maps []STARSMap, fn string) {
+	fmt.Printf("Writing %s... ", fn)
+
+	type label struct {
+		P    [2]float32 `json:"p"` // [lat, lon], as Leaflet expects
+		Text string     `json:"text"`
+	}
+	type viewerMap struct {
+		Name     string         `json:"name"`
+		Label    string         `json:"label"`
+		Category string         `json:"category"`
+		Lines    [][][2]float32 `json:"lines"`
+		Labels   []label        `json:"labels"`
+	}
+	var vm []viewerMap
+	for _, m := range maps {
+		v := viewerMap{Name: m.Name, Label: m.Label, Category: groupCategory(m.Group),
+			Lines: [][][2]float32{}, Labels: []label{}}
+		for _, l := range m.Lines {
+			v.Lines = append(v.Lines, MapSlice(l, func(p Point2LL) [2]float32 { return [2]float32{p[1], p[0]} }))
+		}
+		for _, l := range m.Labels {
+			v.Labels = append(v.Labels, label{P: [2]float32{l.P[1], l.P[0]}, Text: l.Text})
+		}
+		vm = append(vm, v)
+	}
+
+	var b bytes.Buffer
+	err := htmlViewerTemplate.Execute(&b, struct {
+		Title string
+		Maps  []viewerMap
+	}{
+		Title: strings.TrimSuffix(filepath.Base(fn), filepath.Ext(fn)),
+		Maps:  vm,
+	})
+	errorExit(fn, err)
+	errorExit("creating file", writeFileAtomic(fn, b.Bytes(), 0o644))
+}
+
+var htmlViewerTemplate = template.Must(template.New("viewer").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} video maps</title>
+<link rel="stylesheet" href="https://unpkg.com/leaflet@1.9.4/dist/leaflet.css">
+<script src="https://unpkg.com/leaflet@1.9.4/dist/leaflet.js"></script>
+<style>
+html, body, #map { height: 100%; margin: 0; }
+.leaflet-control-layers-overlays { max-height: 70vh; overflow-y: auto; }
+.map-label { color: #fff; font: 11px monospace; white-space: nowrap; text-shadow: 0 0 2px #000, 0 0 2px #000; }
+</style>
+</head>
+<body>
+<div id="map"></div>
+<script>
+const maps = {{.Maps}};
+const colors = { A: "#4fc3f7", B: "#ffb74d" };
+
+const dark = L.tileLayer("https://{s}.basemaps.cartocdn.com/dark_all/{z}/{x}/{y}{r}.png", {
+  attribution: "&copy; OpenStreetMap contributors &copy; CARTO", maxZoom: 19 });
+const osm = L.tileLayer("https://tile.openstreetmap.org/{z}/{x}/{y}.png", {
+  attribution: "&copy; OpenStreetMap contributors", maxZoom: 19 });
+const map = L.map("map", { preferCanvas: true, layers: [dark] });
+
+function escape(s) {
+  const d = document.createElement("div");
+  d.textContent = s;
+  return d.innerHTML;
+}
+
+const overlays = {};
+const bounds = L.latLngBounds([]);
+for (const m of maps) {
+  const group = L.layerGroup();
+  for (const l of m.lines) {
+    const pl = L.polyline(l, { color: colors[m.category], weight: 1 }).bindTooltip(escape(m.name), { sticky: true });
+    group.addLayer(pl);
+    bounds.extend(pl.getBounds());
+  }
+  for (const l of m.labels) {
+    const icon = L.divIcon({ className: "map-label", html: escape(l.text), iconSize: null });
+    group.addLayer(L.marker(l.p, { icon: icon, interactive: false }));
+    bounds.extend(l.p);
+  }
+  group.addTo(map);
+  overlays[escape(m.label + " — " + m.name + " (" + m.category + ")")] = group;
+}
+
+L.control.layers({ "Dark": dark, "OpenStreetMap": osm }, overlays, { collapsed: false }).addTo(map);
+L.control.scale().addTo(map);
+if (bounds.isValid()) {
+  map.fitBounds(bounds);
+} else {
+  map.setView([39, -98], 4);
+}
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,48 @@
+// install.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// viceVideoMapDirectory returns the directory that vice loads video maps
+// from: resources/videomaps in its configuration directory. That is
+// %LocalAppData%\Vice on Windows if it exists and is otherwise under the
+// platform's user configuration directory (%AppData% on Windows,
+// ~/Library/Application Support on macOS, and $XDG_CONFIG_HOME or
+// ~/.config elsewhere).
+func viceVideoMapDirectory() (string, error) {
+	dir := ""
+	if local := os.Getenv("LOCALAPPDATA"); local != "" {
+		if fi, err := os.Stat(filepath.Join(local, "Vice")); err == nil && fi.IsDir() {
+			dir = filepath.Join(local, "Vice")
+		}
+	}
+	if dir == "" {
+		config, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(config, "Vice")
+	}
+	return filepath.Join(dir, "resources", "videomaps"), nil
+}
+
+// installPath returns the path to write the output files with the given
+// base name to: in vice's video map directory with -install, and
+// otherwise unchanged.
+func installPath(base string) string {
+	if !*install {
+		return base
+	}
+	dir, err := viceVideoMapDirectory()
+	errorExit("unable to find vice's directory", err)
+	errorExit(dir, os.MkdirAll(dir, 0o755))
+	fmt.Printf("Installing in %s\n", dir)
+	return filepath.Join(dir, base)
+}
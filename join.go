@@ -0,0 +1,188 @@
+// join.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// Joining lines
+//
+// Maps converted from GIS data often have each segment of a road or
+// boundary as a separate LineString. With -join-lines, lines whose
+// endpoints are within the given distance of each other are joined into
+// longer ones, which reduces the number of lines that vice has to draw.
+
+// joinTolerance is given by -join-lines; it's zero if lines aren't joined.
+var joinTolerance tolerance
+
+// lineEnd identifies one of the endpoints of a line.
+type lineEnd struct {
+	line  int
+	atEnd bool // as opposed to the start
+}
+
+// joinLines returns the given lines with those that meet end to end
+// joined, reversing them as needed; lines are only joined where exactly
+// two of them meet. Closed lines are left as they are.
+func joinLines(lines [][]Point2LL, t tolerance) [][]Point2LL {
+	endpoint := func(e lineEnd) Point2LL {
+		if e.atEnd {
+			return lines[e.line][len(lines[e.line])-1]
+		}
+		return lines[e.line][0]
+	}
+
+	// Endpoints are bucketed by cell so that only the nearby ones need
+	// to be checked.
+	grid := make(map[[2]int][]lineEnd)
+	closed := make([]bool, len(lines))
+	for i, l := range lines {
+		if len(l) < 2 || t.near(l[0], l[len(l)-1]) {
+			closed[i] = true
+			continue
+		}
+		for _, e := range []lineEnd{{i, false}, {i, true}} {
+			c := t.cell(endpoint(e))
+			grid[c] = append(grid[c], e)
+		}
+	}
+
+	// matches returns the other endpoints that are near the given one.
+	matches := func(end lineEnd) []lineEnd {
+		var m []lineEnd
+		p := endpoint(end)
+		for _, c := range neighborCells(t.cell(p)) {
+			for _, e := range grid[c] {
+				if e != end && t.near(p, endpoint(e)) {
+					m = append(m, e)
+				}
+			}
+		}
+		return m
+	}
+
+	used := make([]bool, len(lines))
+	var joined [][]Point2LL
+	for i, l := range lines {
+		if used[i] {
+			continue
+		}
+		used[i] = true
+		if closed[i] {
+			joined = append(joined, l)
+			continue
+		}
+
+		cur := slices.Clone(l)
+		// Extend the line from its end and then, after reversing it, from
+		// its start. Reversal doesn't matter since the lines are all drawn
+		// the same way.
+		for _, end := range []lineEnd{{i, true}, {i, false}} {
+			for {
+				m := matches(end)
+				if len(m) != 1 || used[m[0].line] {
+					break // the end of the line or a junction
+				}
+				next := lines[m[0].line]
+				if m[0].atEnd {
+					next = slices.Clone(next)
+					slices.Reverse(next)
+				}
+				cur = append(cur, next[1:]...)
+				used[m[0].line] = true
+				end = lineEnd{m[0].line, !m[0].atEnd}
+			}
+			slices.Reverse(cur)
+		}
+		joined = append(joined, cur)
+	}
+	return joined
+}
+
+// joinMapLines returns the maps with their lines joined according to
+// -join-lines.
+func joinMapLines(maps []STARSMap) []STARSMap {
+	if joinTolerance == (tolerance{}) {
+		return maps
+	}
+
+	maps = slices.Clone(maps)
+	var before, after int
+	for i := range maps {
+		before += len(maps[i].Lines)
+		maps[i].Lines = joinLines(maps[i].Lines, joinTolerance)
+		after += len(maps[i].Lines)
+	}
+	if before > 0 {
+		fmt.Printf("Joined lines within %s: %d lines to %d\n", joinTolerance, before, after)
+	}
+	return maps
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Snapping
+//
+// Adjacent lines in GIS data often don't quite meet, which shows up as
+// hairline gaps when vice draws the map zoomed in. With -snap, vertices
+// within the given distance of each other in a map are moved to the same
+// location, which also lets -join-lines join the lines.
+
+// snapTolerance is given by -snap; it's zero if vertices aren't snapped.
+var snapTolerance tolerance
+
+// snapVertices returns the lines with each vertex that's near one that
+// came before it moved to that one, along with the number that were
+// moved. The lines aren't modified.
+func snapVertices(lines [][]Point2LL, t tolerance) ([][]Point2LL, int) {
+	grid := make(map[[2]int][]Point2LL)
+	// snap returns the vertex that p should be snapped to, if any.
+	snap := func(p Point2LL) (Point2LL, bool) {
+		for _, c := range neighborCells(t.cell(p)) {
+			for _, q := range grid[c] {
+				if t.near(p, q) {
+					return q, true
+				}
+			}
+		}
+		c := t.cell(p)
+		grid[c] = append(grid[c], p)
+		return p, false
+	}
+
+	snapped := make([][]Point2LL, len(lines))
+	n := 0
+	for i, l := range lines {
+		snapped[i] = make([]Point2LL, len(l))
+		for j, p := range l {
+			q, ok := snap(p)
+			if ok && q != p {
+				n++
+			}
+			snapped[i][j] = q
+		}
+	}
+	return snapped, n
+}
+
+// snapMaps returns the maps with their vertices snapped according to
+// -snap.
+func snapMaps(maps []STARSMap) []STARSMap {
+	if snapTolerance == (tolerance{}) {
+		return maps
+	}
+
+	maps = slices.Clone(maps)
+	total := 0
+	for i := range maps {
+		var n int
+		maps[i].Lines, n = snapVertices(maps[i].Lines, snapTolerance)
+		total += n
+	}
+	fmt.Printf("Snapped %d vertices to others within %s\n", total, snapTolerance)
+	return maps
+}
@@ -0,0 +1,215 @@
+// kml.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// KML and KMZ files
+
+// importKML converts the LineStrings and Polygon boundaries of all of the
+// Placemarks in a KML file (or the main KML file inside a KMZ archive)
+// into a single map.
+func importKML(fn string) ([]STARSMap, error) {
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(filepath.Ext(fn), ".kmz") {
+		if b, err = kmzDocument(b); err != nil {
+			return nil, err
+		}
+	}
+
+	lines, err := parseKML(b)
+	if err != nil {
+		return nil, err
+	}
+
+	stem := strings.TrimSuffix(filepath.Base(fn), filepath.Ext(fn))
+	return []STARSMap{{
+		Group: 0,
+		Label: strings.ToUpper(stem),
+		Name:  stem,
+		Lines: lines,
+	}}, nil
+}
+
+// kmzDocument returns the contents of the main KML file in a KMZ archive:
+// by convention, doc.kml, or otherwise the first .kml file.
+func kmzDocument(b []byte) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, err
+	}
+
+	var doc *zip.File
+	for _, f := range zr.File {
+		if strings.EqualFold(path.Ext(f.Name), ".kml") {
+			if doc == nil || strings.EqualFold(path.Base(f.Name), "doc.kml") {
+				doc = f
+			}
+		}
+	}
+	if doc == nil {
+		return nil, fmt.Errorf("no KML file found in archive")
+	}
+
+	r, err := doc.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readDecompressed(r)
+}
+
+// parseKML returns the coordinates of all LineString and LinearRing
+// (polygon boundary) elements in the KML.
+func parseKML(b []byte) ([][]Point2LL, error) {
+	var lines [][]Point2LL
+	inLine := false
+	d := newXMLDecoder(b)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "LineString", "LinearRing":
+				inLine = true
+			case "coordinates":
+				if !inLine {
+					continue
+				}
+				var coords string
+				if err := d.DecodeElement(&coords, &t); err != nil {
+					return nil, err
+				}
+				line, err := parseKMLCoordinates(coords)
+				if err != nil {
+					return nil, err
+				}
+				if len(line) > 1 {
+					lines = append(lines, line)
+				}
+			}
+
+		case xml.EndElement:
+			if t.Name.Local == "LineString" || t.Name.Local == "LinearRing" {
+				inLine = false
+			}
+		}
+	}
+	return lines, nil
+}
+
+// parseKMLCoordinates parses whitespace-separated lon,lat[,alt] tuples.
+func parseKMLCoordinates(s string) ([]Point2LL, error) {
+	var line []Point2LL
+	for _, tuple := range strings.Fields(s) {
+		c := strings.Split(tuple, ",")
+		if len(c) < 2 {
+			return nil, fmt.Errorf("%q: invalid KML coordinates", tuple)
+		}
+		lon, err := strconv.ParseFloat(c[0], 32)
+		if err != nil {
+			return nil, err
+		}
+		lat, err := strconv.ParseFloat(c[1], 32)
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, Point2LL{float32(lon), float32(lat)})
+	}
+	return line, nil
+}
+
+///////////////////////////////////////////////////////////////////////////
+// KML export
+
+// writeKML writes the maps to a KML file, or to a KMZ archive if fn has a
+// .kmz extension, with each map in its own folder. Lines are drawn in
+// white for category A maps and yellow for category B, and labels are
+// shown as placemark names.
+func writeKML(maps []STARSMap, fn string) {
+	fmt.Printf("Writing %s... ", fn)
+
+	var b bytes.Buffer
+	esc := func(s string) string {
+		var e bytes.Buffer
+		xml.EscapeText(&e, []byte(s))
+		return e.String()
+	}
+	coord := func(p Point2LL) string {
+		return strconv.FormatFloat(float64(p[0]), 'f', -1, 32) + "," + strconv.FormatFloat(float64(p[1]), 'f', -1, 32)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(fn), filepath.Ext(fn))
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2">
+<Document>
+<name>%s</name>
+<Style id="A"><LineStyle><color>ffffffff</color><width>1</width></LineStyle></Style>
+<Style id="B"><LineStyle><color>ff00ffff</color><width>1</width></LineStyle></Style>
+<Style id="label"><IconStyle><scale>0</scale></IconStyle></Style>
+`, esc(name))
+
+	for _, m := range maps {
+		category := groupCategory(m.Group)
+		fmt.Fprintf(&b, "<Folder>\n<name>%s</name>\n<description>%s (category %s)</description>\n",
+			esc(m.Name), esc(m.Label), category)
+
+		if len(m.Lines) > 0 {
+			fmt.Fprintf(&b, "<Placemark>\n<name>%s</name>\n<styleUrl>#%s</styleUrl>\n<MultiGeometry>\n", esc(m.Name), category)
+			for _, l := range m.Lines {
+				b.WriteString("<LineString><tessellate>1</tessellate><coordinates>")
+				for i, p := range l {
+					if i > 0 {
+						b.WriteByte(' ')
+					}
+					b.WriteString(coord(p))
+				}
+				b.WriteString("</coordinates></LineString>\n")
+			}
+			b.WriteString("</MultiGeometry>\n</Placemark>\n")
+		}
+		for _, l := range m.Labels {
+			fmt.Fprintf(&b, "<Placemark><name>%s</name><styleUrl>#label</styleUrl><Point><coordinates>%s</coordinates></Point></Placemark>\n",
+				esc(l.Text), coord(l.P))
+		}
+		b.WriteString("</Folder>\n")
+	}
+	b.WriteString("</Document>\n</kml>\n")
+
+	doc := b.Bytes()
+	if strings.EqualFold(filepath.Ext(fn), ".kmz") {
+		var z bytes.Buffer
+		zw := zip.NewWriter(&z)
+		w, err := zw.Create("doc.kml")
+		errorExit(fn, err)
+		_, err = w.Write(doc)
+		errorExit(fn, err)
+		errorExit(fn, zw.Close())
+		doc = z.Bytes()
+	}
+	errorExit("creating file", writeFileAtomic(fn, doc, 0o644))
+}
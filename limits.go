@@ -0,0 +1,139 @@
+// limits.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// Input limits
+//
+// A corrupt or malicious input file could otherwise make crc2vice run out
+// of memory, so the size of input files and of the data decompressed from
+// them is limited (-max-input-size), which in turn bounds how much memory
+// decoding them takes. So that such a file doesn't produce a video map
+// file that's too large for vice to load, there are also limits on the
+// number of features in a GeoJSON file (-max-features) and the number of
+// coordinates in a LineString or polygon ring (-max-coords); these are
+// checked after decoding and so don't limit memory use themselves. Zero
+// disables a limit.
+
+var inputLimits struct {
+	Size             int64
+	Features, Coords int
+}
+
+var errTooManyCoordinates = errors.New("too many coordinates")
+
+// parseInputLimits sets inputLimits from the command-line flags.
+func parseInputLimits() error {
+	if *maxInput != "0" {
+		var err error
+		if inputLimits.Size, err = parseSize(*maxInput); err != nil {
+			return fmt.Errorf("-max-input-size: %w", err)
+		}
+	}
+	if *maxFeatures < 0 || *maxCoords < 0 {
+		return errors.New("-max-features and -max-coords must not be negative")
+	}
+	inputLimits.Features, inputLimits.Coords = *maxFeatures, *maxCoords
+	return nil
+}
+
+// readInput returns the contents of r, failing without reading all of it
+// if it's larger than -max-input-size.
+func readInput(r io.Reader) ([]byte, error) {
+	return readLimited(r, "file")
+}
+
+// readDecompressed is like readInput but for the output of a decompressor
+// (e.g., for an entry in a zip file), which is limited as well so that a
+// small compressed file can't expand to exhaust memory.
+func readDecompressed(r io.Reader) ([]byte, error) {
+	return readLimited(r, "decompressed data")
+}
+
+func readLimited(r io.Reader, what string) ([]byte, error) {
+	if inputLimits.Size == 0 {
+		return io.ReadAll(r)
+	}
+	b, err := io.ReadAll(io.LimitReader(r, inputLimits.Size+1))
+	if err == nil && int64(len(b)) > inputLimits.Size {
+		err = errInputTooLarge(what)
+	}
+	return b, err
+}
+
+// readInputFile is like fs.ReadFile but fails if the file is larger than
+// -max-input-size.
+func readInputFile(fsys fs.FS, fn string) ([]byte, error) {
+	f, err := fsys.Open(fn)
+	if rfs, ok := fsys.(fs.ReadFileFS); ok && errors.Is(err, errors.ErrUnsupported) {
+		// Some file systems (e.g., vnasFS) only support ReadFile; they're
+		// responsible for not reading too much themselves.
+		b, err := rfs.ReadFile(fn)
+		if err == nil && inputLimits.Size > 0 && int64(len(b)) > inputLimits.Size {
+			return nil, errInputTooLarge("file")
+		}
+		return b, err
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if fi, err := f.Stat(); err == nil && inputLimits.Size > 0 && fi.Size() > inputLimits.Size {
+		return nil, errInputTooLarge("file")
+	}
+	return readInput(f)
+}
+
+// readLocalInputFile is like os.ReadFile but fails if the file is larger
+// than -max-input-size.
+func readLocalInputFile(fn string) ([]byte, error) {
+	if err := checkInputSize(fn); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readInput(f)
+}
+
+// checkInputSize returns an error if the named file is larger than
+// -max-input-size; it's used for files that are read by other code.
+func checkInputSize(fn string) error {
+	if fi, err := os.Stat(fn); err == nil && inputLimits.Size > 0 && fi.Size() > inputLimits.Size {
+		return errInputTooLarge("file")
+	}
+	return nil
+}
+
+func errInputTooLarge(what string) error {
+	return fmt.Errorf("%s is larger than -max-input-size (%s)", what, *maxInput)
+}
+
+// checkFeatureCount returns an error if a GeoJSON file has more than
+// -max-features features.
+func checkFeatureCount(n int) error {
+	if inputLimits.Features > 0 && n > inputLimits.Features {
+		return fmt.Errorf("%d features is more than -max-features (%d)", n, inputLimits.Features)
+	}
+	return nil
+}
+
+// checkCoordinateCount returns an error if a LineString or polygon ring
+// has more than -max-coords coordinates.
+func checkCoordinateCount(n int) error {
+	if inputLimits.Coords > 0 && n > inputLimits.Coords {
+		return fmt.Errorf("%w: %d is more than -max-coords (%d)", errTooManyCoordinates, n, inputLimits.Coords)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+// limits_test.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// readFileFS only supports ReadFile, as vnasFS does.
+type readFileFS map[string]string
+
+func (r readFileFS) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: errors.ErrUnsupported}
+}
+
+func (r readFileFS) ReadFile(name string) ([]byte, error) {
+	if s, ok := r[name]; ok {
+		return []byte(s), nil
+	}
+	return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+}
+
+func TestReadInputFile(t *testing.T) {
+	defer func(size int64) { inputLimits.Size = size }(inputLimits.Size)
+
+	small, large := "0123456789", strings.Repeat("x", 100)
+	for name, fsys := range map[string]fs.FS{
+		"MapFS":      fstest.MapFS{"small": {Data: []byte(small)}, "large": {Data: []byte(large)}},
+		"readFileFS": readFileFS{"small": small, "large": large},
+	} {
+		inputLimits.Size = 50
+		if b, err := readInputFile(fsys, "small"); err != nil || string(b) != small {
+			t.Errorf("%s: small: got %q, %v", name, b, err)
+		}
+		if _, err := readInputFile(fsys, "large"); err == nil {
+			t.Errorf("%s: large: expected an error", name)
+		}
+		if _, err := readInputFile(fsys, "missing"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s: missing: got %v, want fs.ErrNotExist", name, err)
+		}
+
+		inputLimits.Size = 0
+		if b, err := readInputFile(fsys, "large"); err != nil || string(b) != large {
+			t.Errorf("%s: large without a limit: got %q, %v", name, b, err)
+		}
+	}
+}
+
+func TestReadInputFileVNAS(t *testing.T) {
+	defer func(size int64) { inputLimits.Size = size }(inputLimits.Size)
+	inputLimits.Size = 50
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/artccs/ZNY":
+			w.Write([]byte(`{"id": "ZNY"}`))
+		case "/Files/VideoMaps/ZNY/big.geojson":
+			w.Write([]byte(strings.Repeat(" ", 100)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	fsys := newVNASFS(srv.URL)
+
+	if b, err := readInputFile(fsys, "ARTCCs/ZNY.json"); err != nil || string(b) != `{"id": "ZNY"}` {
+		t.Errorf("ARTCCs/ZNY.json: got %q, %v", b, err)
+	}
+	if _, err := readInputFile(fsys, "VideoMaps/ZNY/big.geojson"); err == nil {
+		t.Errorf("VideoMaps/ZNY/big.geojson: expected an error")
+	}
+	if _, err := readInputFile(fsys, "VideoMaps/ZNY/missing.geojson"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("VideoMaps/ZNY/missing.geojson: got %v, want fs.ErrNotExist", err)
+	}
+}
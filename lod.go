@@ -0,0 +1,86 @@
+// lod.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// Levels of detail
+//
+// With -lod, each map is also simplified with each of the given
+// tolerances, and the results are stored in its LODs so that vice can
+// draw a coarser version when zoomed out. The full-detail lines are still
+// in Lines, so vice releases that don't know about LODs are unaffected
+// (other than by the larger file). The JSON manifest lists each map's
+// levels.
+
+// STARSMapLOD is a simplified version of a map's lines: they're all
+// within Tolerance meters of the full-detail lines.
+type STARSMapLOD struct {
+	Tolerance float32
+	Lines     [][]Point2LL
+}
+
+// lodTolerances are the tolerances given by -lod, from finest to
+// coarsest.
+var lodTolerances []tolerance
+
+// parseLODs parses the argument to -lod, a comma-separated list of
+// tolerances (e.g., "25m,100m,500m").
+func parseLODs(s string) ([]tolerance, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var lods []tolerance
+	for _, f := range strings.Split(s, ",") {
+		t, err := parseTolerance(strings.TrimSpace(f))
+		if err != nil {
+			return nil, err
+		}
+		lods = append(lods, t)
+	}
+	slices.SortFunc(lods, func(a, b tolerance) int { return cmp.Compare(a.meters(), b.meters()) })
+	return slices.Compact(lods), nil
+}
+
+// meters returns the tolerance in meters, taking a degree to be 60 nm,
+// as it is along a meridian.
+func (t tolerance) meters() float32 {
+	if t.Degrees > 0 {
+		return t.Degrees * 60 * 1852
+	}
+	return t.Meters
+}
+
+// addLODs returns the maps with their levels of detail for -lod.
+func addLODs(maps []STARSMap) []STARSMap {
+	if len(lodTolerances) == 0 {
+		return maps
+	}
+
+	maps = slices.Clone(maps)
+	var vertices []int
+	for _, t := range lodTolerances {
+		n := 0
+		for i := range maps {
+			lines := simplifyLines(maps[i].Lines, t)
+			maps[i].LODs = append(slices.Clip(maps[i].LODs), STARSMapLOD{Tolerance: t.meters(), Lines: lines})
+			n += countVertices(lines)
+		}
+		vertices = append(vertices, n)
+	}
+
+	var levels []string
+	for i, t := range lodTolerances {
+		levels = append(levels, fmt.Sprintf("%s: %d", t, vertices[i]))
+	}
+	fmt.Printf("Added levels of detail (vertices): %s\n", strings.Join(levels, ", "))
+	return maps
+}
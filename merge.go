@@ -0,0 +1,89 @@
+// merge.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// merge
+
+// mergeARTCCs converts the STARS video maps of each of the given ARTCCs
+// (or facilities within them) and writes them all using the given output
+// name, for scenarios that cover more than one ARTCC. Since vice finds
+// maps by name, it is an error for two different maps to have the same
+// name; maps that are identical are only included once. Maps that share
+// a STARS id are reported but otherwise allowed.
+func mergeARTCCs(outbase string, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "crctovice: no ARTCCs specified\n")
+		os.Exit(1)
+	}
+	if *facilityId != "" || *eram || *asdex || *towerCab {
+		fmt.Fprintf(os.Stderr, "crctovice: -facility, -eram, -asdex, and -tower can't be used with merge\n")
+		os.Exit(1)
+	}
+
+	type origin struct {
+		facility string
+		sum      string
+		name     string
+	}
+	byName := make(map[string]origin)
+	byId := make(map[int]origin)
+	var collisions []string
+
+	// Each ARTCC is read from the same source, and readARTCCMaps may
+	// change both it and -facility, so they are reset each time.
+	fsys := srcFS
+	var maps []STARSMap
+	for _, arg := range args {
+		srcFS = fsys
+		*facilityId = ""
+		_, _, am, _ := readARTCCMaps(arg)
+
+		// Keep each ARTCC's maps after the previous ones'.
+		order := 0
+		for _, m := range maps {
+			order = max(order, m.Order+1)
+		}
+
+		for _, m := range am {
+			o := origin{facility: strings.ToUpper(arg), sum: mapChecksum(m), name: m.Name}
+			if prev, ok := byName[m.Name]; ok {
+				if prev.sum != o.sum {
+					collisions = append(collisions, fmt.Sprintf("%s: map in both %s and %s", m.Name, prev.facility, o.facility))
+				} else {
+					fmt.Printf("\r%s: identical map in %s and %s; including it once\n", m.Name, prev.facility, o.facility)
+				}
+				continue
+			}
+			byName[m.Name] = o
+
+			if prev, ok := byId[m.Id]; ok && m.Id != 0 {
+				fmt.Printf("\r%s: warning: STARS id %d is also used by %q from %s\n", m.Name, m.Id,
+					prev.name, prev.facility)
+			} else {
+				byId[m.Id] = o
+			}
+
+			m.Order += order
+			maps = append(maps, m)
+		}
+	}
+
+	if len(collisions) > 0 {
+		sort.Strings(collisions)
+		fmt.Fprintf(os.Stderr, "crctovice: map names must be unique:\n\t%s\n", strings.Join(collisions, "\n\t"))
+		os.Exit(1)
+	}
+	fmt.Printf("\rMerged %d maps from %s\n", len(maps), strings.Join(args, ", "))
+
+	writeOutput(maps, outputPath(outbase))
+}
@@ -0,0 +1,51 @@
+// migrate.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// migrate
+
+// migrateFiles rewrites the maps in the given video map files, which may
+// have been written by older versions of crc2vice or use older vice
+// STARSMap layouts, in the current format (or the one given with
+// -target-vice-version), along with a new manifest. This way, the maps
+// can be upgraded without their original sources. The files for
+// ZNY-videomaps.gob are written using the base name ZNY, so they replace
+// the originals; -backup keeps copies of those.
+func migrateFiles(args []string) {
+	for _, fn := range args {
+		maps, err := readVideoMapGOB(fn)
+		errorExit(fn, err)
+		clear(sources)
+		findings = nil
+		recordSourceFile(fn)
+
+		version := 0
+		ordered := false
+		for _, m := range maps {
+			version = max(version, m.FormatVersion)
+			ordered = ordered || m.Order != 0
+		}
+		fmt.Printf("Read %d maps from %s (format version %d)\n", len(maps), fn, max(version, 1))
+
+		if !ordered {
+			// Files from before maps had an Order are already in order.
+			for i := range maps {
+				maps[i].Order = i
+			}
+		}
+
+		out := outputPath(videoMapStem(filepath.Base(fn)))
+		if !*install {
+			out = filepath.Join(filepath.Dir(fn), out)
+		}
+		writeOutput(maps, out)
+	}
+}
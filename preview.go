@@ -0,0 +1,377 @@
+// preview.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// Previews
+
+// writePreviews writes preview images of the maps to the directory
+// fn-previews, if they were requested.
+func writePreviews(maps []STARSMap, fn string) {
+	if !*svgPreviews && !*pngPreviews && !*thumbnails {
+		return
+	}
+
+	dir := fn + "-previews"
+	errorExit(dir, os.MkdirAll(dir, 0o755))
+	used := map[string]bool{"index.html": true}
+	var files []string
+	for _, m := range maps {
+		name := uniqueFilename(used, sanitizeFilename(m.Name), "")
+		file := ""
+		if *pngPreviews {
+			file = name + ".png"
+			writePNG(m, filepath.Join(dir, file))
+		}
+		if *svgPreviews {
+			file = name + ".svg"
+			writeSVG(m, filepath.Join(dir, file))
+		}
+		files = append(files, file)
+	}
+	if *thumbnails {
+		writeThumbnailIndex(maps, files, filepath.Join(dir, "index.html"))
+	}
+}
+
+// previewProjection maps from longitude-latitude to image coordinates
+// using an equirectangular projection with longitudes scaled by the
+// cosine of the center latitude, which is good enough over the area of a
+// video map. The map's extent is fit to the image, leaving a margin.
+type previewProjection struct {
+	minx, maxy    float64
+	cosLat, scale float64
+	margin        float64
+	width, height int
+}
+
+func newPreviewProjection(m STARSMap, size int) previewProjection {
+	lo, hi, ok := mapBounds(m)
+	if !ok {
+		lo, hi = Point2LL{}, Point2LL{}
+	}
+	minLon, minLat := float64(lo[0]), float64(lo[1])
+	maxLon, maxLat := float64(hi[0]), float64(hi[1])
+
+	p := previewProjection{
+		cosLat: math.Cos((minLat + maxLat) / 2 * math.Pi / 180),
+		margin: float64(size) / 32,
+	}
+	dx, dy := (maxLon-minLon)*p.cosLat, maxLat-minLat
+	p.minx, p.maxy = minLon*p.cosLat, maxLat
+	p.scale = (float64(size) - 2*p.margin) / math.Max(math.Max(dx, dy), 1e-6)
+	p.width = int(math.Ceil(dx*p.scale + 2*p.margin))
+	p.height = int(math.Ceil(dy*p.scale + 2*p.margin))
+	return p
+}
+
+// project returns the image coordinates of the point; y increases
+// downward.
+func (p previewProjection) project(pt Point2LL) (float64, float64) {
+	x := (float64(pt[0])*p.cosLat-p.minx)*p.scale + p.margin
+	y := (p.maxy-float64(pt[1]))*p.scale + p.margin
+	return x, y
+}
+
+// writeSVG writes an SVG rendering of the map to the named file.
+func writeSVG(m STARSMap, fn string) {
+	fmt.Printf("Writing %s... ", fn)
+	errorExit("creating file", writeFileAtomic(fn, renderSVG(m, *previewSize), 0o644))
+}
+
+// renderSVG returns an SVG rendering of the map with the given size
+// along its longer side. Lines are black for category A maps and blue for
+// category B. Points that round to the same position as the previous one
+// are skipped.
+func renderSVG(m STARSMap, size int) []byte {
+	proj := newPreviewProjection(m, size)
+
+	var b bytes.Buffer
+	esc := func(s string) string {
+		var e bytes.Buffer
+		xml.EscapeText(&e, []byte(s))
+		return e.String()
+	}
+	color := "#000000"
+	if m.Group != 0 {
+		color = "#0060c0"
+	}
+
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">
+<title>%s</title>
+<rect width="100%%" height="100%%" fill="#ffffff"/>
+<g fill="none" stroke="%s" stroke-width="1" stroke-linejoin="round" stroke-linecap="round">
+`, proj.width, proj.height, proj.width, proj.height, esc(m.Name), color)
+	for _, l := range m.Lines {
+		b.WriteString(`<polyline points="`)
+		var prev string
+		for i, p := range l {
+			x, y := proj.project(p)
+			if pt := fmt.Sprintf("%.1f,%.1f", x, y); pt != prev {
+				if i > 0 {
+					b.WriteByte(' ')
+				}
+				b.WriteString(pt)
+				prev = pt
+			}
+		}
+		b.WriteString("\"/>\n")
+	}
+	b.WriteString("</g>\n")
+
+	if len(m.Labels) > 0 {
+		fmt.Fprintf(&b, "<g fill=\"%s\" font-family=\"monospace\" font-size=\"12\" text-anchor=\"middle\">\n", color)
+		for _, l := range m.Labels {
+			x, y := proj.project(l.P)
+			fmt.Fprintf(&b, "<text x=\"%.1f\" y=\"%.1f\">%s</text>\n", x, y, esc(l.Text))
+		}
+		b.WriteString("</g>\n")
+	}
+	b.WriteString("</svg>\n")
+	return b.Bytes()
+}
+
+// writePNG writes a PNG rendering of the map to the named file, drawn in
+// the style of a STARS scope: gray lines on a black background, with
+// category B maps dimmer than category A.
+func writePNG(m STARSMap, fn string) {
+	fmt.Printf("Writing %s... ", fn)
+	proj := newPreviewProjection(m, *previewSize)
+
+	img := image.NewRGBA(image.Rect(0, 0, proj.width, proj.height))
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 0xff // opaque black
+	}
+	c := color.RGBA{R: 210, G: 210, B: 210, A: 255}
+	if m.Group != 0 {
+		c = color.RGBA{R: 130, G: 130, B: 130, A: 255}
+	}
+
+	for _, l := range m.Lines {
+		for i := 1; i < len(l); i++ {
+			x0, y0 := proj.project(l[i-1])
+			x1, y1 := proj.project(l[i])
+			drawLine(img, x0, y0, x1, y1, c)
+		}
+	}
+	scale := max(1, *previewSize/1024)
+	for _, l := range m.Labels {
+		x, y := proj.project(l.P)
+		drawText(img, l.Text, int(x), int(y), scale, c)
+	}
+
+	var b bytes.Buffer
+	errorExit(fn, png.Encode(&b, img))
+	errorExit("creating file", writeFileAtomic(fn, b.Bytes(), 0o644))
+}
+
+// blend mixes c into the pixel at (x, y) with the given coverage.
+func blend(img *image.RGBA, x, y int, c color.RGBA, coverage float64) {
+	if !(image.Point{x, y}.In(img.Rect)) {
+		return
+	}
+	i := img.PixOffset(x, y)
+	for j, v := range []uint8{c.R, c.G, c.B} {
+		d := float64(img.Pix[i+j])
+		img.Pix[i+j] = uint8(math.Round(d + (float64(v)-d)*coverage))
+	}
+}
+
+// drawLine draws an antialiased line using Wu's algorithm.
+func drawLine(img *image.RGBA, x0, y0, x1, y1 float64, c color.RGBA) {
+	steep := math.Abs(y1-y0) > math.Abs(x1-x0)
+	if steep {
+		x0, y0, x1, y1 = y0, x0, y1, x1
+	}
+	if x0 > x1 {
+		x0, y0, x1, y1 = x1, y1, x0, y0
+	}
+	plot := func(x, y int, coverage float64) {
+		if steep {
+			x, y = y, x
+		}
+		blend(img, x, y, c, coverage)
+	}
+
+	gradient := 1.0
+	if dx := x1 - x0; dx > 0 {
+		gradient = (y1 - y0) / dx
+	}
+	xs, xe := int(math.Round(x0)), int(math.Round(x1))
+	y := y0 + gradient*(float64(xs)-x0)
+	for x := xs; x <= xe; x++ {
+		fy := math.Floor(y)
+		plot(x, int(fy), 1-(y-fy))
+		plot(x, int(fy)+1, y-fy)
+		y += gradient
+	}
+}
+
+// drawText draws the string centered at (x, y) using a 5x7 pixel font,
+// enlarged by the given scale. Characters that aren't in the font are
+// left blank.
+func drawText(img *image.RGBA, s string, x, y, scale int, c color.RGBA) {
+	s = strings.ToUpper(s)
+	const advance = 6 // glyph width plus spacing
+	x -= (len(s)*advance - 1) * scale / 2
+	y -= 7 * scale / 2
+	for _, r := range s {
+		glyph := previewFont[r]
+		for row, bits := range glyph {
+			for col := 0; col < 5; col++ {
+				if bits&(0x10>>col) == 0 {
+					continue
+				}
+				for sy := 0; sy < scale; sy++ {
+					for sx := 0; sx < scale; sx++ {
+						blend(img, x+col*scale+sx, y+row*scale+sy, c, 1)
+					}
+				}
+			}
+		}
+		x += advance * scale
+	}
+}
+
+// previewFont gives the rows of each character's glyph, top to bottom,
+// with the leftmost pixel in bit 4.
+var previewFont = map[rune][7]byte{
+	'0': {0x0e, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0e},
+	'1': {0x04, 0x0c, 0x04, 0x04, 0x04, 0x04, 0x0e},
+	'2': {0x0e, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1f},
+	'3': {0x1f, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0e},
+	'4': {0x02, 0x06, 0x0a, 0x12, 0x1f, 0x02, 0x02},
+	'5': {0x1f, 0x10, 0x1e, 0x01, 0x01, 0x11, 0x0e},
+	'6': {0x06, 0x08, 0x10, 0x1e, 0x11, 0x11, 0x0e},
+	'7': {0x1f, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8': {0x0e, 0x11, 0x11, 0x0e, 0x11, 0x11, 0x0e},
+	'9': {0x0e, 0x11, 0x11, 0x0f, 0x01, 0x02, 0x0c},
+	'A': {0x0e, 0x11, 0x11, 0x11, 0x1f, 0x11, 0x11},
+	'B': {0x1e, 0x11, 0x11, 0x1e, 0x11, 0x11, 0x1e},
+	'C': {0x0e, 0x11, 0x10, 0x10, 0x10, 0x11, 0x0e},
+	'D': {0x1c, 0x12, 0x11, 0x11, 0x11, 0x12, 0x1c},
+	'E': {0x1f, 0x10, 0x10, 0x1e, 0x10, 0x10, 0x1f},
+	'F': {0x1f, 0x10, 0x10, 0x1e, 0x10, 0x10, 0x10},
+	'G': {0x0e, 0x11, 0x10, 0x17, 0x11, 0x11, 0x0f},
+	'H': {0x11, 0x11, 0x11, 0x1f, 0x11, 0x11, 0x11},
+	'I': {0x0e, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0e},
+	'J': {0x07, 0x02, 0x02, 0x02, 0x02, 0x12, 0x0c},
+	'K': {0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11},
+	'L': {0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x1f},
+	'M': {0x11, 0x1b, 0x15, 0x15, 0x11, 0x11, 0x11},
+	'N': {0x11, 0x11, 0x19, 0x15, 0x13, 0x11, 0x11},
+	'O': {0x0e, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0e},
+	'P': {0x1e, 0x11, 0x11, 0x1e, 0x10, 0x10, 0x10},
+	'Q': {0x0e, 0x11, 0x11, 0x11, 0x15, 0x12, 0x0d},
+	'R': {0x1e, 0x11, 0x11, 0x1e, 0x14, 0x12, 0x11},
+	'S': {0x0f, 0x10, 0x10, 0x0e, 0x01, 0x01, 0x1e},
+	'T': {0x1f, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04},
+	'U': {0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0e},
+	'V': {0x11, 0x11, 0x11, 0x11, 0x11, 0x0a, 0x04},
+	'W': {0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0a},
+	'X': {0x11, 0x11, 0x0a, 0x04, 0x0a, 0x11, 0x11},
+	'Y': {0x11, 0x11, 0x11, 0x0a, 0x04, 0x04, 0x04},
+	'Z': {0x1f, 0x01, 0x02, 0x04, 0x08, 0x10, 0x1f},
+	'-': {0x00, 0x00, 0x00, 0x1f, 0x00, 0x00, 0x00},
+	'+': {0x00, 0x04, 0x04, 0x1f, 0x04, 0x04, 0x00},
+	'.': {0x00, 0x00, 0x00, 0x00, 0x00, 0x0c, 0x0c},
+	'/': {0x00, 0x01, 0x02, 0x04, 0x08, 0x10, 0x00},
+	':': {0x00, 0x0c, 0x0c, 0x00, 0x0c, 0x0c, 0x00},
+	'(': {0x02, 0x04, 0x08, 0x08, 0x08, 0x04, 0x02},
+	')': {0x08, 0x04, 0x02, 0x02, 0x02, 0x04, 0x08},
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Thumbnail index
+
+// thumbnailSize is the size in pixels of the thumbnails' longer side.
+const thumbnailSize = 200
+
+// writeThumbnailIndex writes a web page with a grid of small renderings
+// of the maps, each labeled with its name, label, category, and id, for
+// reviewing many maps at a glance. The thumbnails are included in the
+// page as SVG; files gives the full-size preview of each map, if any,
+// which its thumbnail links to.
+func writeThumbnailIndex(maps []STARSMap, files []string, fn string) {
+	fmt.Printf("Writing %s... ", fn)
+
+	type thumbnail struct {
+		Name, Label, Category string
+		Id                    int
+		File                  string
+		Empty                 bool
+		SVG                   template.HTML
+	}
+	var thumbs []thumbnail
+	for i, m := range maps {
+		// renderSVG escapes the text in the SVG, so it's safe to include
+		// as is.
+		svg := string(renderSVG(m, thumbnailSize))
+		thumbs = append(thumbs, thumbnail{
+			Name:     m.Name,
+			Label:    m.Label,
+			Category: groupCategory(m.Group),
+			Id:       m.Id,
+			File:     files[i],
+			Empty:    len(m.Lines) == 0 && len(m.Labels) == 0,
+			SVG:      template.HTML(svg),
+		})
+	}
+
+	var b bytes.Buffer
+	err := thumbnailIndexTemplate.Execute(&b, struct {
+		Title      string
+		Thumbnails []thumbnail
+	}{
+		Title:      strings.TrimSuffix(filepath.Base(filepath.Dir(fn)), "-previews"),
+		Thumbnails: thumbs,
+	})
+	errorExit(fn, err)
+	errorExit("creating file", writeFileAtomic(fn, b.Bytes(), 0o644))
+}
+
+var thumbnailIndexTemplate = template.Must(template.New("thumbnails").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} video maps</title>
+<style>
+body { font-family: sans-serif; margin: 1em; }
+.grid { display: grid; grid-template-columns: repeat(auto-fill, minmax(220px, 1fr)); gap: 1em; }
+.map { border: 1px solid #ccc; padding: 8px; text-align: center; }
+.map svg { width: 200px; height: 200px; }
+.map .name { font-weight: bold; margin-top: 4px; overflow-wrap: anywhere; }
+.map .details { color: #666; font-size: 85%; }
+.empty { color: #c00; }
+</style>
+</head>
+<body>
+<h1>{{.Title}} video maps</h1>
+<p>{{len .Thumbnails}} maps</p>
+<div class="grid">
+{{range .Thumbnails}}<div class="map">
+{{if .File}}<a href="{{.File}}">{{.SVG}}</a>{{else}}{{.SVG}}{{end}}
+<div class="name">{{.Name}}</div>
+<div class="details">{{.Label}} &middot; category {{.Category}} &middot; id {{.Id}}</div>
+{{if .Empty}}<div class="empty">empty</div>{{end}}
+</div>
+{{end}}</div>
+</body>
+</html>
+`))
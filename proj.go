@@ -0,0 +1,177 @@
+// proj.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The GeoJSON spec requires WGS84 longitude-latitude coordinates, but
+// files written by older tools may carry a "crs" member that specifies a
+// projected coordinate system. We support the few that show up in
+// practice: web mercator and UTM (WGS84 or NAD83 datums, which are close
+// enough for our purposes). Others, notably the US state plane zones,
+// aren't supported; such files must be converted with other tools first.
+
+const supportedCRSs = "EPSG:4326, 4269, 4152, 3857, 32601-32660, 32701-32760, and 26901-26923 and OGC:CRS84"
+
+func errUnsupportedCRS(name string) error {
+	return fmt.Errorf("%s: unsupported CRS (only %s are supported); convert the file to "+
+		"longitude-latitude (EPSG:4326) first", name, supportedCRSs)
+}
+
+type GeoJSONCRS struct {
+	Type       string `json:"type"`
+	Properties struct {
+		Name string `json:"name"`
+	} `json:"properties"`
+}
+
+func namedCRS(name string) *GeoJSONCRS {
+	crs := &GeoJSONCRS{Type: "name"}
+	crs.Properties.Name = name
+	return crs
+}
+
+// looksProjected reports whether the given coordinates are clearly not
+// longitude-latitude values (as are the typical magnitudes of UTM or
+// state plane coordinates).
+func looksProjected(lines [][]Point2LL) bool {
+	for _, l := range lines {
+		for _, p := range l {
+			if math.Abs(float64(p[0])) > 360 || math.Abs(float64(p[1])) > 360 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// crsUnprojector returns a function that converts coordinates in the
+// named CRS to WGS84 longitude-latitude. It returns a nil function for
+// geographic coordinate systems that don't need to be reprojected.
+func crsUnprojector(name string) (func(Point2LL) Point2LL, error) {
+	// Handle "EPSG:26918", "urn:ogc:def:crs:EPSG::26918",
+	// "urn:ogc:def:crs:OGC:1.3:CRS84", etc.
+	n := strings.TrimPrefix(strings.ToUpper(name), "URN:OGC:DEF:CRS:")
+	f := strings.Split(n, ":")
+	authority, code := f[0], f[len(f)-1]
+
+	if authority == "OGC" {
+		if code == "CRS84" || code == "CRS83" {
+			return nil, nil
+		}
+		return nil, errUnsupportedCRS(name)
+	}
+	if authority != "EPSG" {
+		return nil, errUnsupportedCRS(name)
+	}
+
+	c, err := strconv.Atoi(code)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid EPSG code", name)
+	}
+	switch {
+	case c == 4326 || c == 4269 || c == 4152:
+		// WGS84 / NAD83 geographic
+		return nil, nil
+	case c == 3857 || c == 3785 || c == 900913 || c == 102100:
+		return webMercatorToLL, nil
+	case c > 32600 && c <= 32660:
+		return func(p Point2LL) Point2LL { return utmToLL(p, c-32600, false) }, nil
+	case c > 32700 && c <= 32760:
+		return func(p Point2LL) Point2LL { return utmToLL(p, c-32700, true) }, nil
+	case c >= 26901 && c <= 26923:
+		return func(p Point2LL) Point2LL { return utmToLL(p, c-26900, false) }, nil
+	default:
+		return nil, errUnsupportedCRS(name)
+	}
+}
+
+const wgs84A = 6378137.0 // semi-major axis, meters
+const wgs84F = 1 / 298.257223563
+
+func webMercatorToLL(p Point2LL) Point2LL {
+	x, y := float64(p[0]), float64(p[1])
+	lon := x / wgs84A
+	lat := 2*math.Atan(math.Exp(y/wgs84A)) - math.Pi/2
+	return Point2LL{float32(lon * 180 / math.Pi), float32(lat * 180 / math.Pi)}
+}
+
+// utmToLL converts the given UTM easting and northing to longitude and
+// latitude, following Snyder, "Map Projections: A Working Manual", p. 61.
+func utmToLL(p Point2LL, zone int, south bool) Point2LL {
+	const k0 = 0.9996
+	e2 := wgs84F * (2 - wgs84F)
+	ep2 := e2 / (1 - e2)
+
+	x, y := float64(p[0])-500000, float64(p[1])
+	if south {
+		y -= 10000000
+	}
+
+	m := y / k0
+	mu := m / (wgs84A * (1 - e2/4 - 3*e2*e2/64 - 5*e2*e2*e2/256))
+	e1 := (1 - math.Sqrt(1-e2)) / (1 + math.Sqrt(1-e2))
+	phi1 := mu + (3*e1/2-27*math.Pow(e1, 3)/32)*math.Sin(2*mu) +
+		(21*e1*e1/16-55*math.Pow(e1, 4)/32)*math.Sin(4*mu) +
+		(151*math.Pow(e1, 3)/96)*math.Sin(6*mu) +
+		(1097*math.Pow(e1, 4)/512)*math.Sin(8*mu)
+
+	sin1, cos1, tan1 := math.Sin(phi1), math.Cos(phi1), math.Tan(phi1)
+	n1 := wgs84A / math.Sqrt(1-e2*sin1*sin1)
+	t1 := tan1 * tan1
+	c1 := ep2 * cos1 * cos1
+	r1 := wgs84A * (1 - e2) / math.Pow(1-e2*sin1*sin1, 1.5)
+	d := x / (n1 * k0)
+
+	lat := phi1 - (n1*tan1/r1)*(d*d/2-
+		(5+3*t1+10*c1-4*c1*c1-9*ep2)*math.Pow(d, 4)/24+
+		(61+90*t1+298*c1+45*t1*t1-252*ep2-3*c1*c1)*math.Pow(d, 6)/720)
+	lon := (d - (1+2*t1+c1)*math.Pow(d, 3)/6 +
+		(5-2*c1+28*t1-3*c1*c1+8*ep2+24*t1*t1)*math.Pow(d, 5)/120) / cos1
+	lon0 := float64((zone-1)*6-180+3) * math.Pi / 180
+
+	return Point2LL{float32((lon + lon0) * 180 / math.Pi), float32(lat * 180 / math.Pi)}
+}
+
+// reproject converts the given lines in place to WGS84
+// longitude-latitude, if needed. The CRS is taken from the GeoJSON
+// file's "crs" member if present; otherwise, if the coordinates are
+// clearly projected, the provided default CRS is used. An error is
+// returned if the coordinates can't be converted, in which case they
+// should not be used.
+func reproject(lines [][]Point2LL, crs *GeoJSONCRS, defaultCRS string) error {
+	name := defaultCRS
+	if crs != nil {
+		if crs.Type != "name" {
+			return fmt.Errorf("%q: unsupported \"crs\" type", crs.Type)
+		}
+		name = crs.Properties.Name
+	} else if !looksProjected(lines) {
+		return nil
+	} else if name == "" {
+		return fmt.Errorf("coordinates appear to be projected but no CRS was specified")
+	}
+
+	xform, err := crsUnprojector(name)
+	if err != nil {
+		return err
+	}
+	if xform != nil {
+		for _, l := range lines {
+			for i := range l {
+				l[i] = xform(l[i])
+			}
+		}
+	}
+	if looksProjected(lines) {
+		return fmt.Errorf("%s: coordinates are still invalid after reprojection", name)
+	}
+	return nil
+}
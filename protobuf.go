@@ -0,0 +1,121 @@
+// protobuf.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// Protocol buffers
+//
+// The maps are encoded following the schema in videomaps.proto so that
+// they can be read from languages other than Go. The encoding is simple
+// enough that it's done by hand here.
+
+const (
+	protoVarint = 0
+	protoBytes  = 2
+
+	// protoFormatVersion is incremented when videomaps.proto changes
+	// incompatibly.
+	protoFormatVersion = 1
+)
+
+func protoAppendTag(b []byte, field, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(field<<3|wireType))
+}
+
+// protoAppendUint appends a varint field, which is omitted if it's zero
+// as is done in proto3.
+func protoAppendUint(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	return binary.AppendUvarint(protoAppendTag(b, field, protoVarint), v)
+}
+
+func protoAppendSint(b []byte, field int, v int64) []byte {
+	return protoAppendUint(b, field, protoZigZag(v))
+}
+
+func protoAppendBytes(b []byte, field int, v []byte) []byte {
+	b = protoAppendTag(b, field, protoBytes)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func protoAppendString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	return protoAppendBytes(b, field, []byte(s))
+}
+
+func protoZigZag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+// protoCoord converts a coordinate to 1e-7 degree units.
+func protoCoord(v float32) int64 {
+	return int64(math.Round(float64(v) * 1e7))
+}
+
+// protoEncodeMaps returns the maps encoded as a VideoMaps message.
+func protoEncodeMaps(maps []STARSMap) []byte {
+	b := protoAppendUint(nil, 1, protoFormatVersion)
+	for _, m := range maps {
+		b = protoAppendBytes(b, 2, protoEncodeMap(m))
+	}
+	return b
+}
+
+func protoEncodeMap(m STARSMap) []byte {
+	b := protoAppendString(nil, 1, m.Name)
+	b = protoAppendString(b, 2, m.Label)
+	if m.Group != 0 {
+		b = protoAppendUint(b, 3, 1) // category B
+	}
+	b = protoAppendUint(b, 4, uint64(int64(m.Id))) // int32 fields sign-extend
+	for _, l := range m.Lines {
+		var coords []byte
+		var px, py int64
+		for _, p := range l {
+			x, y := protoCoord(p[0]), protoCoord(p[1])
+			coords = binary.AppendUvarint(coords, protoZigZag(x-px))
+			coords = binary.AppendUvarint(coords, protoZigZag(y-py))
+			px, py = x, y
+		}
+		var line []byte
+		if len(coords) > 0 {
+			line = protoAppendBytes(nil, 1, coords)
+		}
+		b = protoAppendBytes(b, 5, line)
+	}
+	for _, l := range m.Labels {
+		lb := protoAppendSint(nil, 1, protoCoord(l.P[0]))
+		lb = protoAppendSint(lb, 2, protoCoord(l.P[1]))
+		lb = protoAppendString(lb, 3, l.Text)
+		b = protoAppendBytes(b, 6, lb)
+	}
+	if m.AlwaysVisible {
+		b = protoAppendUint(b, 7, 1)
+	}
+	for _, g := range m.MapGroups {
+		gb := protoAppendString(nil, 1, g.Id)
+		gb = protoAppendUint(gb, 2, uint64(int64(g.Position)))
+		b = protoAppendBytes(b, 8, gb)
+	}
+	return b
+}
+
+// writeProtobuf writes the maps to the named file as a VideoMaps protocol
+// buffer message.
+func writeProtobuf(maps []STARSMap, fn string) {
+	fmt.Printf("Writing %s... ", fn)
+	errorExit("creating file", writeFileAtomic(fn, protoEncodeMaps(maps), 0o644))
+}
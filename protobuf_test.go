@@ -0,0 +1,64 @@
+// protobuf_test.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"encoding/hex"
+	"math"
+	"testing"
+)
+
+// The examples from the protocol buffers encoding guide.
+func TestProtobufEncodingGuide(t *testing.T) {
+	for _, test := range []struct {
+		b   []byte
+		hex string
+	}{
+		{protoAppendUint(nil, 1, 150), "089601"},
+		{protoAppendString(nil, 2, "testing"), "120774657374696e67"},
+		{protoAppendBytes(nil, 3, protoAppendUint(nil, 1, 150)), "1a03089601"},
+		{protoAppendUint(nil, 1, 0), ""},
+	} {
+		if got := hex.EncodeToString(test.b); got != test.hex {
+			t.Errorf("got %s, want %s", got, test.hex)
+		}
+	}
+
+	for _, test := range []struct {
+		v  int64
+		zz uint64
+	}{
+		{0, 0}, {-1, 1}, {1, 2}, {-2, 3}, {0x7fffffff, 0xfffffffe}, {-0x80000000, 0xffffffff},
+		{math.MaxInt64, math.MaxUint64 - 1}, {math.MinInt64, math.MaxUint64},
+	} {
+		if got := protoZigZag(test.v); got != test.zz {
+			t.Errorf("zigzag(%d): got %d, want %d", test.v, got, test.zz)
+		}
+	}
+}
+
+// The expected encoding of the maps was computed independently following
+// videomaps.proto.
+func TestProtobufMaps(t *testing.T) {
+	maps := []STARSMap{
+		{
+			Name:          "A",
+			Label:         "B",
+			Group:         1,
+			Id:            -1,
+			Lines:         [][]Point2LL{{{1, 2}, {1.5, 2}, {1.5, 1.9999999}}, {}},
+			Labels:        []STARSMapLabel{{P: Point2LL{-1, 0.5}, Text: "X"}},
+			AlwaysVisible: true,
+			MapGroups:     []STARSMapGroup{{Id: "G", Position: 2}},
+		},
+		{Name: "C"},
+	}
+	const want = "080112400a0141120142180120ffffffffffffffffff012a110a0f80dac40980b4891380ade2040000012a00" +
+		"320d08ffd9c4091080ade2041a0158380142050a0147100212030a0143"
+
+	if got := hex.EncodeToString(protoEncodeMaps(maps)); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,109 @@
+// provenance.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// Provenance
+
+// provenance records where a conversion's output came from so that video
+// map files found in the wild can be traced back to their inputs. It's
+// included in the JSON manifest.
+type provenance struct {
+	Tool    string             `json:"tool"`    // crc2vice and its version
+	Created string             `json:"created"` // RFC 3339
+	Sources []provenanceSource `json:"sources"`
+}
+
+type provenanceSource struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// sources holds the files read so far, indexed by path.
+var sources = make(map[string]string)
+
+// recordSource notes that the given file, with the given contents, was
+// used for the conversion.
+func recordSource(path string, b []byte) {
+	sum := sha256.Sum256(b)
+	sources[path] = hex.EncodeToString(sum[:])
+}
+
+// recordSourceFile is like recordSource but reads the file itself; it's
+// used for files that importers read on their own.
+func recordSourceFile(fn string) {
+	if b, err := os.ReadFile(fn); err == nil {
+		recordSource(fn, b)
+	}
+}
+
+// currentProvenance returns the provenance of the output so far.
+func currentProvenance() provenance {
+	p := provenance{
+		Tool:    "crc2vice " + toolVersion(),
+		Created: creationTime(),
+		Sources: []provenanceSource{},
+	}
+	for path, sum := range sources {
+		p.Sources = append(p.Sources, provenanceSource{Path: path, SHA256: sum})
+	}
+	sort.Slice(p.Sources, func(i, j int) bool { return p.Sources[i].Path < p.Sources[j].Path })
+	return p
+}
+
+// creationTime returns the time to record for output files, in RFC 3339
+// format. It's taken from SOURCE_DATE_EPOCH, if it's set, so that builds
+// can be reproducible.
+func creationTime() string {
+	t := time.Now()
+	if sde, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		t = time.Unix(sde, 0)
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// toolVersion returns the version of crc2vice from its build information:
+// the module version if it was installed with "go install", and otherwise
+// the VCS revision it was built from, if available.
+func toolVersion() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if v := bi.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+
+	var rev string
+	var modified bool
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			rev = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if rev == "" {
+		return "devel"
+	}
+	if len(rev) > 12 {
+		rev = rev[:12]
+	}
+	if modified {
+		rev += "-dirty"
+	}
+	return "devel-" + rev
+}
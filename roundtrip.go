@@ -0,0 +1,137 @@
+// roundtrip.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// Round-trip verification
+//
+// With -verify-output, the video map and manifest files are read back
+// after they're written and compared to what was intended, so that
+// encoding bugs and partial writes are caught at conversion time rather
+// than when vice fails to load the files. Only GOB files can be decoded;
+// for the other encodings, just their contents are checked.
+
+// verifyVideoMapFile checks that the named file has the given SHA-256 hash
+// and holds the given maps.
+func verifyVideoMapFile(fn, sum string, want []STARSMap) error {
+	b, err := readVerified(fn, sum)
+	if err != nil || *encoding != "gob" {
+		return err
+	}
+	if len(b) >= 4 && binary.LittleEndian.Uint32(b) == zstdMagic {
+		if b, err = zstdDecompress(b); err != nil {
+			return err
+		}
+	}
+
+	var maps []STARSMap
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&maps); err != nil {
+		return err
+	}
+	maps = expandSharedLines(maps)
+	if len(maps) != len(want) {
+		return fmt.Errorf("read %d maps but wrote %d", len(maps), len(want))
+	}
+	for i := range maps {
+		if maps[i].Name != want[i].Name {
+			return fmt.Errorf("read map %q but wrote %q", maps[i].Name, want[i].Name)
+		}
+		if mapChecksum(maps[i]) != mapChecksum(want[i]) {
+			return fmt.Errorf("%s: map differs from what was written", maps[i].Name)
+		}
+	}
+	return nil
+}
+
+// verifyGOBManifest checks that the named GOB manifest has the given
+// SHA-256 hash and holds the given map checksums (or just the names,
+// for vice versions without checksums).
+func verifyGOBManifest(fn, sum string, sums map[string]string) error {
+	b, err := readVerified(fn, sum)
+	if err != nil || *encoding != "gob" {
+		return err
+	}
+
+	var names map[string]interface{}
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&names); err != nil {
+		return err
+	}
+	if len(names) != len(sums) {
+		return fmt.Errorf("read %d maps but wrote %d", len(names), len(sums))
+	}
+	for name, sum := range sums {
+		v, ok := names[name]
+		if !ok {
+			return fmt.Errorf("%s: map is missing", name)
+		}
+		if formatVersion >= 2 && v != sum {
+			return fmt.Errorf("%s: checksum differs from what was written", name)
+		}
+	}
+	return nil
+}
+
+// verifyJSONManifest checks that the named JSON manifest lists the given
+// files and map checksums.
+func verifyJSONManifest(fn string, files []jsonManifestFile, sums map[string]string) error {
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return err
+	}
+	var m jsonManifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+
+	got := m.Shards
+	if m.VideoMaps != nil {
+		got = []jsonManifestFile{*m.VideoMaps}
+	}
+	if len(got) != len(files) {
+		return fmt.Errorf("read %d video map files but wrote %d", len(got), len(files))
+	}
+	for i := range got {
+		if got[i] != files[i] {
+			return fmt.Errorf("%s: file differs from what was written", files[i].File)
+		}
+	}
+
+	if len(m.Maps) != len(sums) {
+		return fmt.Errorf("read %d maps but wrote %d", len(m.Maps), len(sums))
+	}
+	for name, sum := range sums {
+		if mm, ok := m.Maps[name]; !ok {
+			return fmt.Errorf("%s: map is missing", name)
+		} else if mm.SHA256 != sum {
+			return fmt.Errorf("%s: checksum differs from what was written", name)
+		}
+	}
+	return nil
+}
+
+// readVerified returns the contents of the named file after checking
+// that they have the given SHA-256 hash, which catches partial writes.
+func readVerified(fn, sum string) ([]byte, error) {
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	if s := sha256.Sum256(b); hex.EncodeToString(s[:]) != sum {
+		return nil, errors.New("contents differ from what was written")
+	}
+	return b, nil
+}
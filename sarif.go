@@ -0,0 +1,166 @@
+// sarif.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// Findings for CI
+//
+// So that repositories of facility data can check their maps in pull
+// requests, findings can be written as a SARIF log with -sarif, which
+// GitHub code scanning (among others) reads, or printed as GitHub Actions
+// workflow commands with -github-annotations. Either way, they are
+// attributed to the GeoJSON file (and feature, if possible) that they
+// come from.
+
+// writeFindings writes the SARIF log, if -sarif was given.
+func writeFindings() {
+	if *sarifOut == "" {
+		return
+	}
+
+	type message struct {
+		Text string `json:"text"`
+	}
+	type artifactLocation struct {
+		URI string `json:"uri"`
+	}
+	type region struct {
+		StartLine int `json:"startLine"`
+	}
+	type physicalLocation struct {
+		ArtifactLocation artifactLocation `json:"artifactLocation"`
+		Region           *region          `json:"region,omitempty"`
+	}
+	type location struct {
+		PhysicalLocation physicalLocation `json:"physicalLocation"`
+	}
+	type result struct {
+		RuleId    string     `json:"ruleId"`
+		Level     string     `json:"level"`
+		Message   message    `json:"message"`
+		Locations []location `json:"locations,omitempty"`
+	}
+	type rule struct {
+		Id string `json:"id"`
+	}
+	type driver struct {
+		Name           string `json:"name"`
+		Version        string `json:"version"`
+		InformationURI string `json:"informationUri"`
+		Rules          []rule `json:"rules"`
+	}
+	type run struct {
+		Tool struct {
+			Driver driver `json:"driver"`
+		} `json:"tool"`
+		Results []result `json:"results"`
+	}
+
+	var r run
+	r.Tool.Driver = driver{Name: "crc2vice", Version: toolVersion(), InformationURI: "https://github.com/mmp/crc2vice"}
+	r.Results = []result{}
+	rules := make(map[string]bool)
+	for _, f := range findings {
+		res := result{RuleId: f.Rule, Level: f.Severity, Message: message{Text: f.context() + f.Message}}
+		if fn := findingPath(f); fn != "" {
+			loc := location{PhysicalLocation: physicalLocation{ArtifactLocation: artifactLocation{URI: fn}}}
+			if f.Line > 0 {
+				loc.PhysicalLocation.Region = &region{StartLine: f.Line}
+			}
+			res.Locations = []location{loc}
+		}
+		r.Results = append(r.Results, res)
+		rules[f.Rule] = true
+	}
+	for id := range rules {
+		r.Tool.Driver.Rules = append(r.Tool.Driver.Rules, rule{Id: id})
+	}
+	sort.Slice(r.Tool.Driver.Rules, func(i, j int) bool { return r.Tool.Driver.Rules[i].Id < r.Tool.Driver.Rules[j].Id })
+
+	log := struct {
+		Schema  string `json:"$schema"`
+		Version string `json:"version"`
+		Runs    []run  `json:"runs"`
+	}{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []run{r},
+	}
+
+	fmt.Printf("Writing %s... ", *sarifOut)
+	b, err := json.MarshalIndent(log, "", "  ")
+	errorExit("JSON error", err)
+	errorExit("creating file", writeFileAtomic(*sarifOut, append(b, '\n'), 0o644))
+	fmt.Printf("Done.\n")
+}
+
+// printAnnotation prints the finding as a GitHub Actions workflow command
+// so that it's shown with the file it's in.
+func printAnnotation(f finding) {
+	var props []string
+	if fn := findingPath(f); fn != "" {
+		props = append(props, "file="+escapeAnnotation(fn, true))
+		if f.Line > 0 {
+			props = append(props, fmt.Sprintf("line=%d", f.Line))
+		}
+	}
+	props = append(props, "title="+escapeAnnotation("crc2vice: "+f.Rule, true))
+	fmt.Printf("::%s %s::%s\n", f.Severity, strings.Join(props, ","), escapeAnnotation(f.context()+f.Message, false))
+}
+
+// escapeAnnotation escapes the characters that are special in workflow
+// commands; properties have more of them than messages.
+func escapeAnnotation(s string, property bool) string {
+	s = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+	if property {
+		s = strings.NewReplacer(":", "%3A", ",", "%2C").Replace(s)
+	}
+	return s
+}
+
+// context returns the map and feature that the finding is about, for
+// messages that are attributed to its file.
+func (f finding) context() string {
+	var c string
+	if f.Map != "" {
+		c = f.Map + ": "
+	}
+	if f.Feature >= 0 {
+		c += fmt.Sprintf("feature %d", f.Feature)
+		if f.Vertex >= 0 {
+			c += fmt.Sprintf(", vertex %d", f.Vertex)
+		}
+		c += ": "
+	}
+	return c
+}
+
+// findingPath returns the path of the file that the finding is about,
+// relative to the current directory when the sources are local, or "" if
+// it's not about a particular file.
+func findingPath(f finding) string {
+	fn := f.File
+	if fn == "" {
+		fn = mapFiles[f.Map]
+	}
+	if fn == "" || isURL(fn) {
+		return fn
+	}
+	if *gitRepo == "" && *gitHub == "" && !*remote {
+		if fi, err := os.Stat(*source); err == nil && fi.IsDir() {
+			fn = filepath.Join(*source, fn)
+		}
+	}
+	return filepath.ToSlash(fn)
+}
@@ -0,0 +1,155 @@
+// scenario.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// Scenario cross-check
+
+// scenarioMapKeys are the members of vice scenario files that list video
+// map names: the maps available in "stars_config", those in each
+// controller's DCB, and those shown by default.
+var scenarioMapKeys = []string{"stars_maps", "video_maps", "default_maps"}
+
+// scenarioMapRef is a video map name in a scenario file and where it is.
+type scenarioMapRef struct {
+	Name, Path string
+}
+
+// checkScenario reports the video maps that the given vice scenario file
+// refers to that aren't in the video map file, which vice reports as
+// "map not found" when the scenario is loaded, and lists the maps that
+// the scenario doesn't use. If no video map file is given, the scenario's
+// "video_map_file" is used from vice's resources directory. It exits with
+// an error if any maps are missing.
+func checkScenario(fn, videoMapFile string) {
+	b, err := os.ReadFile(fn)
+	errorExit(fn, err)
+	var scenario interface{}
+	errorExit(fn, UnmarshalJSON(standardizeJSON(b), &scenario))
+
+	var refs []scenarioMapRef
+	var files []string
+	var walk func(v interface{}, path string)
+	walk = func(v interface{}, path string) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				e, p := v[k], path+"."+k
+				if s, ok := e.(string); ok && k == "video_map_file" {
+					files = append(files, s)
+				} else if a, ok := e.([]interface{}); ok && slices.Contains(scenarioMapKeys, k) {
+					for i, n := range a {
+						if name, ok := n.(string); ok && name != "" { // "" is an empty DCB button
+							refs = append(refs, scenarioMapRef{Name: name, Path: fmt.Sprintf("%s[%d]", p, i)})
+						}
+					}
+				} else {
+					walk(e, p)
+				}
+			}
+		case []interface{}:
+			for i, e := range v {
+				walk(e, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	}
+	walk(scenario, "$")
+
+	if videoMapFile == "" {
+		if len(files) == 0 {
+			fmt.Fprintf(os.Stderr, "%s: no \"video_map_file\" in scenario; specify the video map file\n", fn)
+			os.Exit(1)
+		}
+		dir, err := viceVideoMapDirectory()
+		errorExit("unable to find vice's video map directory", err)
+		// The scenario's path is relative to vice's resources directory.
+		videoMapFile = filepath.Join(filepath.Dir(dir), filepath.FromSlash(files[0]))
+	}
+	names, err := readMapNames(videoMapFile)
+	errorExit(videoMapFile, err)
+	fmt.Printf("Read %d maps from %s\n", len(names), videoMapFile)
+
+	have := make(map[string]bool)
+	lower := make(map[string]string)
+	for _, name := range names {
+		have[name] = true
+		lower[strings.ToLower(name)] = name
+	}
+
+	used := make(map[string]bool)
+	for _, r := range refs {
+		used[r.Name] = true
+		if have[r.Name] {
+			continue
+		}
+		msg := fmt.Sprintf("%s: no map named %q in %s", r.Path, r.Name, filepath.Base(videoMapFile))
+		if match, ok := lower[strings.ToLower(r.Name)]; ok {
+			msg += fmt.Sprintf("; did you mean %q?", match)
+		}
+		report(finding{Severity: "error", Rule: "unknown-map", File: fn, Feature: -1, Vertex: -1, Message: msg})
+	}
+
+	var unused []string
+	for _, name := range names {
+		if !used[name] {
+			unused = append(unused, name)
+		}
+	}
+	sort.Strings(unused)
+	if len(unused) > 0 {
+		fmt.Printf("%d of %d maps aren't used by the scenario:\n\t%s\n", len(unused), len(names), strings.Join(unused, "\n\t"))
+	}
+
+	finishRun()
+	if len(findings) > 0 {
+		os.Exit(1)
+	}
+	fmt.Printf("All %d maps used by %s were found.\n", len(used), fn)
+}
+
+// readMapNames returns the names of the maps in the given video map file
+// or GOB manifest.
+func readMapNames(fn string) ([]string, error) {
+	maps, err := readVideoMapGOB(fn)
+	if err == nil {
+		return MapSlice(maps, func(m STARSMap) string { return m.Name }), nil
+	}
+
+	// See if it's a manifest.
+	b, rerr := os.ReadFile(fn)
+	if rerr != nil {
+		return nil, rerr
+	}
+	if len(b) >= 4 && binary.LittleEndian.Uint32(b) == zstdMagic {
+		return nil, err
+	}
+	var manifest map[string]interface{}
+	if gob.NewDecoder(bytes.NewReader(b)).Decode(&manifest) != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range manifest {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,164 @@
+// schema.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// ARTCC definition schema
+//
+// The ARTCC definition is checked against artcc.schema.json before it's
+// converted, since members that are missing, have the wrong type, or are
+// misspelled otherwise either cause unhelpful JSON errors or silently
+// become zero values. Only the parts of JSON Schema that it uses are
+// supported: type, enum, properties, required, additionalProperties,
+// items, and references to $defs.
+
+//go:embed artcc.schema.json
+var artccSchemaJSON []byte
+
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Defs                 map[string]*jsonSchema `json:"$defs"`
+	Type                 json.RawMessage        `json:"type"` // a string or an array of them
+	Enum                 []interface{}          `json:"enum"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+}
+
+// types returns the types that the schema allows, or nil if any is.
+func (s *jsonSchema) types() []string {
+	var t []string
+	if err := json.Unmarshal(s.Type, &t); err == nil {
+		return t
+	}
+	var one string
+	if err := json.Unmarshal(s.Type, &one); err == nil {
+		return []string{one}
+	}
+	return nil
+}
+
+// jsonType returns the JSON Schema type of a value decoded with
+// UseNumber.
+func jsonType(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// checkARTCCSchema reports the ways in which the given ARTCC definition
+// (as JSON) doesn't match the schema. Values with the wrong type and
+// missing members are errors; unknown members are warnings.
+func checkARTCCSchema(fn string, b []byte) {
+	var root jsonSchema
+	errorExit("artcc.schema.json", json.Unmarshal(artccSchemaJSON, &root))
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return // reported when the definition is decoded
+	}
+
+	var check func(s *jsonSchema, v interface{}, path string)
+	check = func(s *jsonSchema, v interface{}, path string) {
+		problem := func(severity, msg string, args ...interface{}) {
+			report(finding{Severity: severity, Rule: "artcc-schema", File: fn, Feature: -1, Vertex: -1,
+				Message: path + ": " + fmt.Sprintf(msg, args...)})
+		}
+
+		for s.Ref != "" {
+			s = root.Defs[strings.TrimPrefix(s.Ref, "#/$defs/")]
+		}
+
+		t := jsonType(v)
+		if types := s.types(); len(types) > 0 && !slices.Contains(types, t) &&
+			!(t == "integer" && slices.Contains(types, "number")) {
+			problem("error", "expected %s, found %s", strings.Join(types, " or "), t)
+			return
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, v) {
+			problem("error", "%s isn't one of %s", jsonString(v), strings.Join(MapSlice(s.Enum, jsonString), ", "))
+		}
+
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for _, r := range s.Required {
+				if _, ok := v[r]; !ok {
+					problem("error", "%q is missing", r)
+				}
+			}
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if ps, ok := s.Properties[k]; ok {
+					check(ps, v[k], path+"."+k)
+				} else if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					msg := fmt.Sprintf("unknown member %q", k)
+					for p := range s.Properties {
+						if strings.EqualFold(p, k) {
+							msg += fmt.Sprintf("; did you mean %q?", p)
+						}
+					}
+					problem("warning", "%s", msg)
+				}
+			}
+		case []interface{}:
+			if s.Items != nil {
+				for i, e := range v {
+					check(s.Items, e, elementPath(path, i, e))
+				}
+			}
+		}
+	}
+	check(&root, v, "$")
+}
+
+func jsonString(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// elementPath returns the path to the i-th element of an array, with the
+// element's name or id, if it has one, so that it can be found easily.
+func elementPath(path string, i int, e interface{}) string {
+	p := fmt.Sprintf("%s[%d]", path, i)
+	if o, ok := e.(map[string]interface{}); ok {
+		if name, ok := o["name"].(string); ok {
+			p += fmt.Sprintf(" (%q)", name)
+		} else if id, ok := o["id"].(string); ok {
+			p += fmt.Sprintf(" (%q)", id)
+		}
+	}
+	return p
+}
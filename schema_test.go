@@ -0,0 +1,31 @@
+// schema_test.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import "testing"
+
+func TestARTCCSchemaBrightnessCategory(t *testing.T) {
+	defer func(f []finding) { findings = f }(findings)
+
+	for _, test := range []struct {
+		category string
+		errors   int
+	}{
+		{`"A"`, 0}, {`"B"`, 0}, {`null`, 0}, {`"C"`, 1}, {`1`, 1},
+	} {
+		findings = nil
+		checkARTCCSchema("ZNY.json", []byte(`{"facility": {"id": "ZNY"}, "videoMaps": [{"id": "1", "starsBrightnessCategory": `+
+			test.category+`}]}`))
+		errors := 0
+		for _, f := range findings {
+			if f.Severity == "error" {
+				errors++
+			}
+		}
+		if errors != test.errors {
+			t.Errorf("%s: got %d errors, want %d (%v)", test.category, errors, test.errors, findings)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+// sct2.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// VRC .sct2 and EuroScope .sct sector files
+
+// sectorFile holds the drawable contents of a sector file, along with
+// the named points that may be used in place of coordinates.
+type sectorFile struct {
+	fn     string
+	points map[string]Point2LL // VORs, NDBs, airports, and fixes
+	maps   []STARSMap
+	index  map[string]int // map name -> index into maps
+	region []Point2LL     // [REGIONS] polygon currently being parsed
+}
+
+func importSCT2(fn string) ([]STARSMap, error) {
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	sf := parseSectorFile(fn, b, true)
+	return sf.maps, nil
+}
+
+// parseSectorFile parses the given sector file. The [GEO], [ARTCC],
+// [ARTCC HIGH], [ARTCC LOW], airway, and [REGIONS] sections each become a
+// single map while each SID and STAR diagram gets its own map. Region
+// polygons are converted to their outlines. If drawables is false, only
+// the named points are parsed.
+func parseSectorFile(fn string, b []byte, drawables bool) *sectorFile {
+	sf := &sectorFile{
+		fn:     fn,
+		points: make(map[string]Point2LL),
+		index:  make(map[string]int),
+	}
+	prefix := strings.TrimSuffix(filepath.Base(fn), filepath.Ext(fn))
+	b = decodeText(b)
+
+	// Named points may be used before the section that defines them, so
+	// make two passes over the file.
+	passes := 1
+	if drawables {
+		passes = 2
+	}
+	for pass := 0; pass < passes; pass++ {
+		section := ""
+		diagram := "" // name of the current SID/STAR diagram
+		scanner := bufio.NewScanner(bytes.NewReader(b))
+		for lineno := 1; scanner.Scan(); lineno++ {
+			line := scanner.Text()
+			if c := strings.Index(line, ";"); c != -1 {
+				line = line[:c]
+			}
+			if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#define") {
+				continue
+			}
+			if strings.HasPrefix(line, "[") {
+				sf.flushRegion(prefix)
+				section = strings.ToUpper(strings.Trim(strings.TrimSpace(line), "[]"))
+				diagram = ""
+				continue
+			}
+
+			fields := strings.Fields(line)
+			if pass == 0 {
+				switch section {
+				case "VOR", "NDB", "AIRPORT":
+					// ID freq lat lon ...
+					if len(fields) >= 4 {
+						if p, ok := parseSectorLatLon(fields[2], fields[3]); ok {
+							sf.points[strings.ToUpper(fields[0])] = p
+						}
+					}
+				case "FIXES":
+					if len(fields) >= 3 {
+						if p, ok := parseSectorLatLon(fields[1], fields[2]); ok {
+							sf.points[strings.ToUpper(fields[0])] = p
+						}
+					}
+				}
+				continue
+			}
+
+			var name string
+			switch section {
+			case "GEO", "ARTCC", "ARTCC HIGH", "ARTCC LOW", "LOW AIRWAY", "HIGH AIRWAY":
+				name = prefix + " " + section
+			case "SID", "STAR":
+				// The diagram name is in the first 26 columns; lines
+				// that continue a diagram leave them blank.
+				if n := strings.TrimSpace(line[:min(26, len(line))]); n != "" && !strings.HasPrefix(line, " ") {
+					diagram = n
+				}
+				if diagram == "" {
+					continue
+				}
+				name = prefix + " " + section + " " + diagram
+				fields = strings.Fields(line[min(26, len(line)):])
+			case "REGIONS":
+				// Each polygon starts with a line giving its color and
+				// first vertex; subsequent lines give one vertex each.
+				// EuroScope also allows "REGIONNAME" lines.
+				if strings.EqualFold(fields[0], "REGIONNAME") {
+					sf.flushRegion(prefix)
+					continue
+				}
+				if len(fields) >= 3 {
+					sf.flushRegion(prefix)
+					fields = fields[len(fields)-2:]
+				}
+				if len(fields) != 2 {
+					fmt.Printf("\r%s:%d: warning: unable to parse region vertex\n", fn, lineno)
+				} else if p, ok := sf.lookupLatLon(fields[0], fields[1]); !ok {
+					fmt.Printf("\r%s:%d: warning: unable to parse region vertex\n", fn, lineno)
+				} else {
+					sf.region = append(sf.region, p)
+				}
+				continue
+			default:
+				continue
+			}
+
+			p0, p1, ok := sf.parseSegment(fields)
+			if !ok {
+				fmt.Printf("\r%s:%d: warning: unable to parse line segment\n", fn, lineno)
+				continue
+			}
+			sf.addSegment(name, section, p0, p1)
+		}
+		sf.flushRegion(prefix)
+	}
+
+	return sf
+}
+
+// flushRegion adds the outline of the current region polygon, if any, to
+// the regions map.
+func (sf *sectorFile) flushRegion(prefix string) {
+	if len(sf.region) > 1 {
+		sf.addLine(prefix+" REGIONS", "REGIONS", append(sf.region, sf.region[0]))
+	}
+	sf.region = nil
+}
+
+// parseSegment finds the segment's endpoints at the end of the given
+// fields; they may be preceded by a name and followed by a color.
+func (sf *sectorFile) parseSegment(fields []string) (Point2LL, Point2LL, bool) {
+	for _, skip := range []int{0, 1} { // possible trailing color
+		n := len(fields) - skip
+		if n < 4 {
+			break
+		}
+		p0, ok0 := sf.lookupLatLon(fields[n-4], fields[n-3])
+		p1, ok1 := sf.lookupLatLon(fields[n-2], fields[n-1])
+		if ok0 && ok1 {
+			return p0, p1, true
+		}
+	}
+	return Point2LL{}, Point2LL{}, false
+}
+
+// lookupLatLon handles both coordinates and named points; for the
+// latter, the name is repeated for the latitude and longitude.
+func (sf *sectorFile) lookupLatLon(lat, lon string) (Point2LL, bool) {
+	if p, ok := parseSectorLatLon(lat, lon); ok {
+		return p, true
+	}
+	if strings.EqualFold(lat, lon) {
+		p, ok := sf.points[strings.ToUpper(lat)]
+		return p, ok
+	}
+	return Point2LL{}, false
+}
+
+// addSegment adds the segment to the named map, extending the map's
+// last line if the segment continues it.
+func (sf *sectorFile) addSegment(name, section string, p0, p1 Point2LL) {
+	idx, ok := sf.index[name]
+	if !ok {
+		idx = len(sf.maps)
+		sf.index[name] = idx
+		sf.maps = append(sf.maps, STARSMap{
+			Group: 1,
+			Label: strings.ToUpper(section),
+			Name:  name,
+			Order: idx,
+		})
+	}
+
+	sf.maps[idx].Lines = appendSegment(sf.maps[idx].Lines, p0, p1)
+}
+
+// addLine adds a complete line to the named map.
+func (sf *sectorFile) addLine(name, section string, line []Point2LL) {
+	sf.addSegment(name, section, line[0], line[1])
+	m := &sf.maps[sf.index[name]]
+	m.Lines[len(m.Lines)-1] = append(m.Lines[len(m.Lines)-1], line[2:]...)
+}
+
+// parseSectorLatLon parses sector file coordinates of the form
+// N040.38.23.000 W073.46.44.000.
+func parseSectorLatLon(lat, lon string) (Point2LL, bool) {
+	la, ok0 := parseSectorDMS(lat, 'N', 'S')
+	lo, ok1 := parseSectorDMS(lon, 'E', 'W')
+	return Point2LL{lo, la}, ok0 && ok1
+}
+
+func parseSectorDMS(s string, pos, neg byte) (float32, bool) {
+	if len(s) < 2 {
+		return 0, false
+	}
+	sign := float64(1)
+	switch s[0] &^ 0x20 { // upper case
+	case pos:
+	case neg:
+		sign = -1
+	default:
+		return 0, false
+	}
+
+	f := strings.SplitN(s[1:], ".", 4)
+	if len(f) < 3 {
+		return 0, false
+	}
+	deg, err0 := strconv.Atoi(f[0])
+	mins, err1 := strconv.Atoi(f[1])
+	secstr := f[2]
+	if len(f) == 4 {
+		secstr += "." + f[3]
+	}
+	sec, err2 := strconv.ParseFloat(secstr, 64)
+	if err0 != nil || err1 != nil || err2 != nil {
+		return 0, false
+	}
+	return float32(sign * (float64(deg) + float64(mins)/60 + sec/3600)), true
+}
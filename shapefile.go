@@ -0,0 +1,192 @@
+// shapefile.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// ESRI shapefiles
+
+// importShapefile converts the polylines and polygons in a .shp file. If
+// -shp-name-field is given, the attributes in the corresponding .dbf file
+// are used to group the shapes into maps named by that field's value;
+// otherwise a single map is made for the entire file.
+func importShapefile(fn string) ([]STARSMap, error) {
+	shp, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	var dbf []byte
+	if *shpNameField != "" {
+		dbfn := strings.TrimSuffix(fn, filepath.Ext(fn)) + ".dbf"
+		if dbf, err = os.ReadFile(dbfn); err != nil {
+			return nil, err
+		}
+	}
+
+	return parseShapefile(fn, shp, dbf)
+}
+
+func parseShapefile(fn string, shp, dbf []byte) ([]STARSMap, error) {
+	shapes, err := readShapes(shp)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	var names []string
+	if dbf != nil {
+		records, err := readDBF(dbf)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fn, err)
+		}
+		if len(records) != len(shapes) {
+			return nil, fmt.Errorf("%s: %d shapes but %d attribute records", fn, len(shapes), len(records))
+		}
+		for _, r := range records {
+			v, ok := r[strings.ToUpper(*shpNameField)]
+			if !ok {
+				return nil, fmt.Errorf("%s: no %q attribute in .dbf file", fn, *shpNameField)
+			}
+			names = append(names, v)
+		}
+	}
+
+	stem := strings.TrimSuffix(filepath.Base(fn), filepath.Ext(fn))
+	var maps []STARSMap
+	index := make(map[string]int)
+	for i, lines := range shapes {
+		if err := reproject(lines, nil, *defaultCRS); err != nil {
+			return nil, fmt.Errorf("%s: %w", fn, err)
+		}
+
+		name := stem
+		if names != nil {
+			name = names[i]
+		}
+		idx, ok := index[name]
+		if !ok {
+			idx = len(maps)
+			index[name] = idx
+			maps = append(maps, STARSMap{
+				Group: 0,
+				Label: strings.ToUpper(name),
+				Name:  name,
+			})
+		}
+		maps[idx].Lines = append(maps[idx].Lines, lines...)
+	}
+
+	return maps, nil
+}
+
+// readShapes returns the parts of each record in the .shp file; polygon
+// rings are returned as closed lines. Null shapes are returned as empty
+// and points are ignored.
+func readShapes(b []byte) ([][][]Point2LL, error) {
+	if len(b) < 100 || binary.BigEndian.Uint32(b[0:4]) != 9994 {
+		return nil, fmt.Errorf("not a shapefile")
+	}
+
+	var shapes [][][]Point2LL
+	for off := 100; off+8 <= len(b); {
+		// Record headers are big endian and lengths are in 16-bit words.
+		length := 2 * int(binary.BigEndian.Uint32(b[off+4:]))
+		content := b[off+8:]
+		if length > len(content) || length < 4 {
+			return nil, fmt.Errorf("truncated record at offset %d", off)
+		}
+		content = content[:length]
+		off += 8 + length
+
+		var parts [][]Point2LL
+		switch shapeType := binary.LittleEndian.Uint32(content); shapeType {
+		case 3, 5, 13, 15, 23, 25: // PolyLine, Polygon, and their Z and M variants
+			if len(content) < 44 {
+				return nil, fmt.Errorf("truncated shape")
+			}
+			numParts := int(binary.LittleEndian.Uint32(content[36:]))
+			numPoints := int(binary.LittleEndian.Uint32(content[40:]))
+			pts := 44 + 4*numParts
+			if numParts < 0 || numPoints < 0 || pts+16*numPoints > len(content) {
+				return nil, fmt.Errorf("truncated shape")
+			}
+
+			point := func(i int) Point2LL {
+				x := math.Float64frombits(binary.LittleEndian.Uint64(content[pts+16*i:]))
+				y := math.Float64frombits(binary.LittleEndian.Uint64(content[pts+16*i+8:]))
+				return Point2LL{float32(x), float32(y)}
+			}
+			for p := 0; p < numParts; p++ {
+				start := int(binary.LittleEndian.Uint32(content[44+4*p:]))
+				end := numPoints
+				if p+1 < numParts {
+					end = int(binary.LittleEndian.Uint32(content[44+4*(p+1):]))
+				}
+				if start < 0 || start > end || end > numPoints {
+					return nil, fmt.Errorf("invalid shape part")
+				}
+				var part []Point2LL
+				for i := start; i < end; i++ {
+					part = append(part, point(i))
+				}
+				if len(part) > 1 {
+					parts = append(parts, part)
+				}
+			}
+		}
+		shapes = append(shapes, parts)
+	}
+	return shapes, nil
+}
+
+// readDBF returns the records in a dBase file; the keys of each record's
+// map are the upper case field names.
+func readDBF(b []byte) ([]map[string]string, error) {
+	if len(b) < 32 {
+		return nil, fmt.Errorf("truncated .dbf file")
+	}
+	numRecords := int(binary.LittleEndian.Uint32(b[4:]))
+	headerLength := int(binary.LittleEndian.Uint16(b[8:]))
+	recordLength := int(binary.LittleEndian.Uint16(b[10:]))
+
+	type field struct {
+		name   string
+		length int
+	}
+	var fields []field
+	for off := 32; off+32 <= len(b) && b[off] != 0x0d; off += 32 {
+		name, _, _ := strings.Cut(string(b[off:off+11]), "\x00")
+		fields = append(fields, field{name: strings.ToUpper(name), length: int(b[off+16])})
+	}
+
+	var records []map[string]string
+	for i := 0; i < numRecords; i++ {
+		off := headerLength + i*recordLength
+		if off+recordLength > len(b) {
+			return nil, fmt.Errorf("truncated .dbf file")
+		}
+		// The first byte is the deletion flag; deleted records still
+		// have a corresponding shape, so we keep them.
+		r := make(map[string]string)
+		pos := off + 1
+		for _, f := range fields {
+			if pos+f.length > off+recordLength {
+				return nil, fmt.Errorf("invalid .dbf record")
+			}
+			r[f.name] = strings.TrimSpace(string(b[pos : pos+f.length]))
+			pos += f.length
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
@@ -0,0 +1,82 @@
+// shard.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// Sharding
+
+// maxOutputSize is the largest video map file to write, in bytes, as
+// given by -max-size; zero means there's no limit.
+var maxOutputSize int64
+
+// parseSize parses a size in bytes with an optional K, M, or G suffix
+// (powers of 1024, optionally followed by "B"). The empty string gives
+// zero.
+func parseSize(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	t := strings.TrimSuffix(strings.ToUpper(s), "B")
+	scale := int64(1)
+	for i, suffix := range []string{"K", "M", "G"} {
+		if strings.HasSuffix(t, suffix) {
+			t = strings.TrimSuffix(t, suffix)
+			scale = int64(1) << (10 * (i + 1))
+			break
+		}
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(t), 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%q: invalid size", s)
+	}
+	return n * scale, nil
+}
+
+// encodedSize returns the size of the given value once it has been
+// encoded (and compressed, with -zstd).
+func encodedSize(v any) int64 {
+	b, err := encodeValue(v)
+	errorExit("encoding error", err)
+	if *zstdGOB {
+		b = zstdCompress(b)
+	}
+	return int64(len(b))
+}
+
+// shardMaps splits the maps into groups that can each be written to a
+// file of at most maxOutputSize bytes, keeping them in order. If they all
+// fit in one file, a single group is returned. Sizes are estimated from
+// the maps' individual encodings, which include some overhead that's only
+// needed once per file, so the shards may be somewhat smaller than the
+// limit.
+func shardMaps(maps []STARSMap) [][]STARSMap {
+	if maxOutputSize == 0 || len(maps) == 0 || encodedSize(maps) <= maxOutputSize {
+		return [][]STARSMap{maps}
+	}
+
+	const slop = 16 // for the container's header
+	var shards [][]STARSMap
+	var cur []STARSMap
+	size := int64(slop)
+	for _, m := range maps {
+		msize := encodedSize(m)
+		if msize+slop > maxOutputSize {
+			fmt.Printf("\r%s: warning: map is %d bytes, more than the -max-size limit by itself\n", m.Name, msize)
+		}
+		if len(cur) > 0 && size+msize > maxOutputSize {
+			shards = append(shards, cur)
+			cur, size = nil, slop
+		}
+		cur = append(cur, m)
+		size += msize
+	}
+	return append(shards, cur)
+}
@@ -0,0 +1,152 @@
+// sign.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// Signing
+//
+// With -sign, each video map, manifest, and index file is signed using an
+// Ed25519 private key and the base64-encoded signature is written to a
+// file with ".sig" appended to its name, so that facilities that
+// distribute maps can guarantee that they haven't been modified. Keys are
+// stored as PEM-encoded PKCS #8 (private) and PKIX (public) files, as
+// also used by OpenSSL.
+
+// signingKey is the private key given with -sign, if any.
+var signingKey ed25519.PrivateKey
+
+// signFile writes the signature of the given file contents to fn.sig, if
+// a signing key was given.
+func signFile(fn string, b []byte) {
+	if signingKey == nil || fn == "-" {
+		return
+	}
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(signingKey, b))
+	errorExit("creating file", writeFileAtomic(fn+".sig", []byte(sig+"\n"), 0o644))
+}
+
+// generateKeys writes a new key pair to name.key and name.pub. Existing
+// keys aren't overwritten, since maps signed with them couldn't be
+// verified afterward.
+func generateKeys(name string) {
+	for _, fn := range []string{name + ".key", name + ".pub"} {
+		if _, err := os.Stat(fn); err == nil {
+			fmt.Fprintf(os.Stderr, "%s: file already exists\n", fn)
+			os.Exit(1)
+		}
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	errorExit("generating key", err)
+
+	b, err := x509.MarshalPKCS8PrivateKey(priv)
+	errorExit("encoding key", err)
+	fmt.Printf("Writing %s... ", name+".key")
+	errorExit("creating file", os.WriteFile(name+".key", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b}), 0o600))
+
+	b, err = x509.MarshalPKIXPublicKey(pub)
+	errorExit("encoding key", err)
+	fmt.Printf("Writing %s... ", name+".pub")
+	errorExit("creating file", os.WriteFile(name+".pub", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: b}), 0o644))
+	fmt.Printf("Done.\nKeep %s private and give %s to those who will verify your maps.\n", name+".key", name+".pub")
+}
+
+func readPrivateKey(fn string) (ed25519.PrivateKey, error) {
+	b, err := readPEM(fn, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(b)
+	if err != nil {
+		return nil, err
+	}
+	if k, ok := key.(ed25519.PrivateKey); ok {
+		return k, nil
+	}
+	return nil, errors.New("not an Ed25519 private key")
+}
+
+func readPublicKey(fn string) (ed25519.PublicKey, error) {
+	b, err := readPEM(fn, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(b)
+	if err != nil {
+		return nil, err
+	}
+	if k, ok := key.(ed25519.PublicKey); ok {
+		return k, nil
+	}
+	return nil, errors.New("not an Ed25519 public key")
+}
+
+// readPEM returns the contents of the PEM block of the given type in the
+// file.
+func readPEM(fn, blockType string) ([]byte, error) {
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var block *pem.Block
+		if block, b = pem.Decode(b); block == nil {
+			return nil, fmt.Errorf("no %s found", strings.ToLower(blockType))
+		} else if block.Type == blockType {
+			return block.Bytes, nil
+		}
+	}
+}
+
+// verifyFiles checks the signatures of the given files using the public
+// key in keyfn, exiting with an error if any are missing or invalid.
+func verifyFiles(keyfn string, files []string) {
+	pub, err := readPublicKey(keyfn)
+	errorExit(keyfn, err)
+
+	failed := false
+	for _, fn := range files {
+		if err := verifyFile(pub, fn); err != nil {
+			fmt.Printf("%s: %v\n", fn, err)
+			failed = true
+		} else {
+			fmt.Printf("%s: OK\n", fn)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func verifyFile(pub ed25519.PublicKey, fn string) error {
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return err
+	}
+	s, err := os.ReadFile(fn + ".sig")
+	if err != nil {
+		return fmt.Errorf("unable to read signature: %w", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(s)))
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn+".sig", err)
+	}
+	if !ed25519.Verify(pub, b, sig) {
+		return errors.New("signature is NOT valid")
+	}
+	return nil
+}
@@ -0,0 +1,402 @@
+// simplify.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// Line simplification
+//
+// Detailed maps like coastlines and airport surfaces often have far more
+// vertices than can be seen on a STARS display, which makes the video map
+// files large and slow to load. With -simplify-tolerance, lines are
+// simplified using the Douglas-Peucker algorithm, which removes vertices
+// that are within the tolerance of the simplified line; the endpoints of
+// each line are always kept.
+
+// tolerance is a distance for simplification, in either meters or
+// degrees, only one of which is nonzero.
+type tolerance struct {
+	Meters, Degrees float32
+}
+
+// simplifyTolerance is given by -simplify-tolerance; it's zero if lines
+// aren't simplified.
+var simplifyTolerance tolerance
+
+// parseTolerance parses a distance with a unit: "m" for meters or "deg"
+// for degrees (e.g., "10m" or "0.0001deg"). The empty string gives zero.
+func parseTolerance(s string) (tolerance, error) {
+	if s == "" {
+		return tolerance{}, nil
+	}
+	var t tolerance
+	unit := &t.Meters
+	num := strings.TrimSuffix(s, "m")
+	if d, ok := strings.CutSuffix(s, "deg"); ok {
+		num, unit = d, &t.Degrees
+	} else if num == s {
+		return tolerance{}, fmt.Errorf("%q: tolerance must be given in meters (\"m\") or degrees (\"deg\")", s)
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(num), 32)
+	if err != nil || v <= 0 || math.IsInf(v, 0) {
+		return tolerance{}, fmt.Errorf("%q: invalid tolerance", s)
+	}
+	*unit = float32(v)
+	return t, nil
+}
+
+func (t tolerance) String() string {
+	if t.Degrees > 0 {
+		return fmt.Sprintf("%gdeg", t.Degrees)
+	}
+	return fmt.Sprintf("%gm", t.Meters)
+}
+
+// distance returns the distance from p to the segment from a to b in the
+// tolerance's units.
+func (t tolerance) distance(p, a, b Point2LL) float32 {
+	if t.Degrees == 0 {
+		return 1852 * nmSegmentDistance(p, a, b)
+	}
+
+	ax, ay := a[0]-p[0], a[1]-p[1]
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	s := float32(0)
+	if l2 := dx*dx + dy*dy; l2 > 0 {
+		s = min(1, max(0, -(ax*dx+ay*dy)/l2))
+	}
+	x, y := ax+s*dx, ay+s*dy
+	return float32(math.Sqrt(float64(x*x + y*y)))
+}
+
+// cell returns the cell of a grid with cells the size of the tolerance
+// that p is in, so that points near one another can be found quickly:
+// points within the tolerance of p are in its cell or the neighboring
+// ones.
+func (t tolerance) cell(p Point2LL) [2]int {
+	x, y := float64(p[0]), float64(p[1])
+	if t.Degrees == 0 { // to meters
+		x, y = x*1852*60*math.Cos(radians(p[1])), y*1852*60
+	}
+	tol := float64(max(t.Meters, t.Degrees))
+	return [2]int{int(math.Floor(x / tol)), int(math.Floor(y / tol))}
+}
+
+// neighborCells returns the cell and the ones around it.
+func neighborCells(c [2]int) [][2]int {
+	n := make([][2]int, 0, 9)
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			n = append(n, [2]int{c[0] + dx, c[1] + dy})
+		}
+	}
+	return n
+}
+
+// near reports whether the points are within the tolerance of each
+// other.
+func (t tolerance) near(a, b Point2LL) bool {
+	if t.Degrees == 0 {
+		return 1852*nmdistance2ll(a, b) <= t.Meters
+	}
+	return math.Hypot(float64(a[0]-b[0]), float64(a[1]-b[1])) <= float64(t.Degrees)
+}
+
+// simplifyLine returns the line simplified to within the tolerance. The
+// line isn't modified.
+func simplifyLine(line []Point2LL, t tolerance) []Point2LL {
+	if len(line) <= 2 {
+		return line
+	}
+
+	keep := make([]bool, len(line))
+	keep[0], keep[len(line)-1] = true, true
+	// An explicit stack is used rather than recursion since lines may have
+	// up to -max-coords vertices.
+	type span struct{ lo, hi int }
+	stack := []span{{0, len(line) - 1}}
+	tol := max(t.Meters, t.Degrees)
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		far, d := -1, tol
+		for i := s.lo + 1; i < s.hi; i++ {
+			if di := t.distance(line[i], line[s.lo], line[s.hi]); di > d {
+				far, d = i, di
+			}
+		}
+		if far != -1 {
+			keep[far] = true
+			stack = append(stack, span{s.lo, far}, span{far, s.hi})
+		}
+	}
+
+	var simp []Point2LL
+	for i, p := range line {
+		if keep[i] {
+			simp = append(simp, p)
+		}
+	}
+	return simp
+}
+
+// simplifyLines returns the lines simplified to within the tolerance.
+func simplifyLines(lines [][]Point2LL, t tolerance) [][]Point2LL {
+	return MapSlice(lines, func(l []Point2LL) []Point2LL { return simplifyLine(l, t) })
+}
+
+func countVertices(lines [][]Point2LL) int {
+	n := 0
+	for _, l := range lines {
+		n += len(l)
+	}
+	return n
+}
+
+// fitVertexBudget returns the given map's lines simplified with the
+// smallest tolerance (to within a few percent) that leaves them with at
+// most budget vertices. Since each line keeps its endpoints, that may not
+// be possible, in which case the map is reported and its lines are
+// simplified as much as they can be.
+func fitVertexBudget(name string, lines [][]Point2LL, budget int) [][]Point2LL {
+	// Find a tolerance that's large enough, doubling it until it is, and
+	// then bisect between it and the last one that wasn't.
+	lo, hi := float32(0), float32(1)
+	simp := simplifyLines(lines, tolerance{Meters: hi})
+	for countVertices(simp) > budget {
+		if hi > 2e7 { // around half of the earth's circumference
+			fmt.Printf("\r%s: warning: unable to simplify lines to fit in %d vertices; %d is the fewest possible\n",
+				name, budget, countVertices(simp))
+			return simp
+		}
+		lo, hi = hi, 2*hi
+		simp = simplifyLines(lines, tolerance{Meters: hi})
+	}
+	for hi-lo > 0.02*hi {
+		mid := (lo + hi) / 2
+		if s := simplifyLines(lines, tolerance{Meters: mid}); countVertices(s) > budget {
+			lo = mid
+		} else {
+			hi, simp = mid, s
+		}
+	}
+
+	fmt.Printf("\r%s: simplified lines to within %s to fit in %d vertices: %d vertices to %d\n",
+		name, tolerance{Meters: hi}, budget, countVertices(lines), countVertices(simp))
+	return simp
+}
+
+// simplifyMaps returns the maps with their lines simplified according to
+// -simplify-tolerance and -max-vertices-per-map. Maps that have more than
+// -max-vertices-per-map vertices after simplification with the tolerance
+// (if there is one) are simplified further, starting from their original
+// lines.
+func simplifyMaps(maps []STARSMap) []STARSMap {
+	if simplifyTolerance == (tolerance{}) && *maxVertices == 0 {
+		return maps
+	}
+
+	maps = slices.Clone(maps)
+	var before, after int
+	for i := range maps {
+		lines := maps[i].Lines
+		if simplifyTolerance != (tolerance{}) {
+			lines = simplifyLines(lines, simplifyTolerance)
+		}
+		if *maxVertices > 0 && countVertices(lines) > *maxVertices {
+			lines = fitVertexBudget(maps[i].Name, maps[i].Lines, *maxVertices)
+		}
+		before += countVertices(maps[i].Lines)
+		after += countVertices(lines)
+		maps[i].Lines = lines
+	}
+
+	if before > 0 && (after < before || simplifyTolerance != (tolerance{})) {
+		within := ""
+		if simplifyTolerance != (tolerance{}) {
+			within = " to within " + simplifyTolerance.String()
+		}
+		fmt.Printf("Simplified lines%s: %d vertices to %d (%.0f%% fewer)\n", within,
+			before, after, 100*float64(before-after)/float64(before))
+	}
+	return maps
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Quantization
+//
+// GIS exports often have coordinates with far more precision than is
+// meaningful; with -precision, coordinates are rounded to a grid with the
+// given spacing, which removes that noise, and consecutive vertices that
+// then coincide are collapsed.
+
+// precision is given by -precision; it's zero if coordinates aren't
+// rounded.
+var precision tolerance
+
+// quantizeMaps returns the maps with their coordinates rounded according
+// to -precision. Lines that collapse to a single point are removed.
+func quantizeMaps(maps []STARSMap) []STARSMap {
+	if precision == (tolerance{}) {
+		return maps
+	}
+
+	// A meter is taken to be the distance along a meridian, which makes
+	// the grid somewhat finer in longitude away from the equator.
+	step := precision.Degrees
+	if step == 0 {
+		step = precision.Meters / (1852 * 60)
+	}
+	round := func(p Point2LL) Point2LL {
+		return Point2LL{float32(math.Round(float64(p[0]/step))) * step, float32(math.Round(float64(p[1]/step))) * step}
+	}
+
+	maps = slices.Clone(maps)
+	var before, after int
+	for i := range maps {
+		var lines [][]Point2LL
+		for _, l := range maps[i].Lines {
+			q := make([]Point2LL, 0, len(l))
+			for _, p := range l {
+				if p = round(p); len(q) == 0 || p != q[len(q)-1] {
+					q = append(q, p)
+				}
+			}
+			before += len(l)
+			if len(q) >= 2 {
+				lines = append(lines, q)
+				after += len(q)
+			}
+		}
+		maps[i].Lines = lines
+
+		labels := slices.Clone(maps[i].Labels)
+		for j := range labels {
+			labels[j].P = round(labels[j].P)
+		}
+		maps[i].Labels = labels
+	}
+
+	if before > 0 {
+		fmt.Printf("Rounded coordinates to %s: %d vertices to %d (%.0f%% fewer)\n", precision,
+			before, after, 100*float64(before-after)/float64(before))
+	}
+	return maps
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Degenerate geometry
+
+// dropDegenerate returns the maps without consecutive duplicate vertices,
+// which give zero-length segments, or lines with fewer than two distinct
+// vertices; they're common in GIS exports and don't draw anything. What
+// was removed is reported for each map.
+func dropDegenerate(maps []STARSMap) []STARSMap {
+	maps = slices.Clone(maps)
+	for i := range maps {
+		var lines [][]Point2LL
+		var dups, dropped int
+		for _, l := range maps[i].Lines {
+			if len(l) >= 2 && !hasConsecutiveDuplicates(l) {
+				lines = append(lines, l) // the common case; don't copy it
+				continue
+			}
+			c := slices.Compact(slices.Clone(l))
+			dups += len(l) - len(c)
+			if len(c) < 2 {
+				dropped++
+			} else {
+				lines = append(lines, c)
+			}
+		}
+
+		if dups > 0 || dropped > 0 {
+			fmt.Printf("\r%s: removed %d duplicate vertices and %d lines with fewer than two vertices\n",
+				maps[i].Name, dups, dropped)
+			maps[i].Lines = lines
+		}
+	}
+	return maps
+}
+
+func hasConsecutiveDuplicates(l []Point2LL) bool {
+	for i := 1; i < len(l); i++ {
+		if l[i] == l[i-1] {
+			return true
+		}
+	}
+	return false
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Densification
+//
+// Lines are drawn straight in vice's projection, while long boundary
+// segments are meant to follow great circles; with -densify, vertices
+// are added along the great circle so that the segments are no longer
+// than the given distance and the lines don't visibly diverge.
+
+// densifyDistance is given by -densify, in nautical miles; it's zero if
+// lines aren't densified.
+var densifyDistance float32
+
+// densifyLine returns the line with vertices added along the great
+// circles between its vertices so that no segment is longer than d
+// nautical miles.
+func densifyLine(l []Point2LL, d float32) []Point2LL {
+	if len(l) < 2 {
+		return l
+	}
+
+	unit := func(p Point2LL) [3]float64 {
+		lat, lon := radians(p[1]), radians(p[0])
+		return [3]float64{math.Cos(lat) * math.Cos(lon), math.Cos(lat) * math.Sin(lon), math.Sin(lat)}
+	}
+
+	dense := []Point2LL{l[0]}
+	for i := 1; i < len(l); i++ {
+		a, b := l[i-1], l[i]
+		if n := int(math.Ceil(float64(nmdistance2ll(a, b) / d))); n > 1 {
+			ua, ub := unit(a), unit(b)
+			omega := math.Acos(min(1, ua[0]*ub[0]+ua[1]*ub[1]+ua[2]*ub[2]))
+			for j := 1; j < n; j++ {
+				// Spherical linear interpolation between the endpoints.
+				t := float64(j) / float64(n)
+				sa, sb := math.Sin((1-t)*omega)/math.Sin(omega), math.Sin(t*omega)/math.Sin(omega)
+				x, y, z := sa*ua[0]+sb*ub[0], sa*ua[1]+sb*ub[1], sa*ua[2]+sb*ub[2]
+				dense = append(dense, Point2LL{float32(math.Atan2(y, x) * 180 / math.Pi),
+					float32(math.Atan2(z, math.Hypot(x, y)) * 180 / math.Pi)})
+			}
+		}
+		dense = append(dense, b)
+	}
+	return dense
+}
+
+// densifyMaps returns the maps with their lines densified according to
+// -densify.
+func densifyMaps(maps []STARSMap) []STARSMap {
+	if densifyDistance == 0 {
+		return maps
+	}
+
+	maps = slices.Clone(maps)
+	var before, after int
+	for i := range maps {
+		before += countVertices(maps[i].Lines)
+		maps[i].Lines = MapSlice(maps[i].Lines, func(l []Point2LL) []Point2LL { return densifyLine(l, densifyDistance) })
+		after += countVertices(maps[i].Lines)
+	}
+	fmt.Printf("Densified lines along great circles to every %gnm: %d vertices to %d\n", densifyDistance, before, after)
+	return maps
+}
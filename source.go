@@ -0,0 +1,288 @@
+// source.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// srcFS is the file system that the ARTCC definition and video maps are
+// read from; it is rooted at the directory that holds the ARTCCs/ and
+// VideoMaps/ folders.
+var srcFS fs.FS = os.DirFS(".")
+
+// crcDirectory returns the directory where the installed CRC client keeps
+// the ARTCC definitions and video maps it has downloaded.
+func crcDirectory() (string, error) {
+	if dir := os.Getenv("LOCALAPPDATA"); dir != "" {
+		return filepath.Join(dir, "CRC"), nil
+	}
+	// os.UserCacheDir returns %LocalAppData% on Windows; this also gives
+	// a plausible location for CRC running under wine elsewhere.
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "CRC"), nil
+}
+
+// openSource returns a file system for the given source, which may be
+// either a directory or a .zip archive such as the ones distributed by
+// vNAS.
+func openSource(src string, base string) (fs.FS, error) {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return os.DirFS(src), nil
+	}
+	if !strings.EqualFold(filepath.Ext(src), ".zip") {
+		return nil, fmt.Errorf("%s: expected a directory or a .zip file", src)
+	}
+
+	// Note: the archive stays open for the duration of the run.
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		return nil, err
+	}
+	return zipRoot(&zr.Reader, base)
+}
+
+// zipRoot returns the directory in the archive that holds the ARTCCs/
+// folder; archives may have it at the top level or inside another
+// directory. The folder with the ARTCC definition for base is preferred
+// if there are multiple ones; if there isn't one, base may be a facility
+// within one of the ARTCCs.
+func zipRoot(zr *zip.Reader, base string) (fs.FS, error) {
+	sub := func(root string) (fs.FS, error) {
+		if root == "" {
+			return zr, nil
+		}
+		return fs.Sub(zr, root)
+	}
+
+	var first *string
+	for _, f := range zr.File {
+		dir, file := path.Split(f.Name)
+		if file == "" || (dir != "ARTCCs/" && !strings.HasSuffix(dir, "/ARTCCs/")) {
+			continue
+		}
+		root := strings.TrimSuffix(strings.TrimSuffix(dir, "ARTCCs/"), "/")
+		if strings.TrimSuffix(file, path.Ext(file)) == base {
+			return sub(root)
+		} else if first == nil {
+			first = &root
+		}
+	}
+	if first == nil {
+		return nil, fmt.Errorf("no ARTCCs/ folder found in archive")
+	}
+	return sub(*first)
+}
+
+// readVideoMapFile returns the name and contents of the GeoJSON file for
+// the given video map. It is expected in VideoMaps/<base>/ in the source
+// but is also searched for elsewhere under VideoMaps/ and then in the
+// -map-dir directories, including subdirectories, since facilities
+// sometimes organize maps into folders by airport. CRC runs on Windows,
+// where filenames are case insensitive, so if there's no file with
+// exactly the expected name, one whose name only differs in case is used,
+// with a warning. Finally, if there's no file named after the map's id,
+// one named after its source file or short name is used, also with a
+// warning, since renamed files are common.
+func readVideoMapFile(base string, m VideoMapSpec) (string, []byte, error) {
+	type root struct {
+		fsys        fs.FS
+		dir, search string // expected location, directory to search
+		display     string // prefix for filenames in messages
+	}
+	roots := []root{{fsys: srcFS, dir: path.Join("VideoMaps", base), search: "VideoMaps"}}
+	for _, d := range mapDirs {
+		roots = append(roots, root{fsys: os.DirFS(d), dir: ".", search: ".", display: d})
+	}
+
+	names := []struct{ name, field string }{
+		{m.Id, ""},
+		{m.sourceStem(), "sourceFileName"},
+		{m.ShortName, "shortName"},
+	}
+
+	for _, n := range names {
+		if n.name == "" || n.name == "." {
+			continue
+		}
+		for _, r := range roots {
+			if p := findVideoMap(r.fsys, r.dir, r.search, n.name+".geojson"); p != "" {
+				b, err := readInputFile(r.fsys, p)
+				if r.display != "" {
+					p = filepath.Join(r.display, filepath.FromSlash(p))
+				}
+				if n.field != "" {
+					fmt.Printf("\r%s: warning: no GeoJSON file for id %q; using %s, found using its %s\n",
+						m.Name, m.Id, p, n.field)
+				}
+				return p, b, err
+			}
+		}
+	}
+
+	// Read the expected file to get the appropriate error.
+	fn := path.Join("VideoMaps", base, m.Id) + ".geojson"
+	b, err := readInputFile(srcFS, fn)
+	return fn, b, err
+}
+
+// sourceStem returns the name of the map's original file, which is given
+// as a Windows path, without its directory or extension.
+func (m VideoMapSpec) sourceStem() string {
+	stem := path.Base(strings.ReplaceAll(m.SourceFileName, "\\", "/"))
+	return strings.TrimSuffix(stem, path.Ext(stem))
+}
+
+// checkUnreferencedFiles reports the GeoJSON files in VideoMaps/<base>/
+// that none of the ARTCC's video maps would use; they're usually maps that
+// were renamed in CRC and so are missing from the output. A file is
+// considered to be used if it's named after a map's id, source file, or
+// short name, as readVideoMapFile tries, ignoring case.
+func checkUnreferencedFiles(artcc ARTCC, base string) {
+	used := make(map[string]bool)
+	for _, m := range artcc.VideoMaps {
+		for _, name := range []string{m.Id, m.sourceStem(), m.ShortName} {
+			used[strings.ToLower(name)] = true
+		}
+	}
+
+	dir := path.Join("VideoMaps", base)
+	// Not all sources can be listed (e.g., the vNAS API), so errors are
+	// ignored.
+	_ = fs.WalkDir(srcFS, dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.EqualFold(path.Ext(p), ".geojson") {
+			return nil
+		}
+		if stem := strings.TrimSuffix(d.Name(), path.Ext(d.Name())); !used[strings.ToLower(stem)] {
+			report(finding{Severity: "warning", Rule: "unreferenced-file", File: p, Feature: -1, Vertex: -1,
+				Message: "not used by any video map; if the map was renamed in CRC, it's missing from the output"})
+		}
+		return nil
+	})
+}
+
+// findVideoMap returns the path of the file with the given name in dir or,
+// failing that, anywhere under search, or "" if there isn't one.
+func findVideoMap(fsys fs.FS, dir, search, name string) string {
+	fn := path.Join(dir, name)
+	if _, err := fs.Stat(fsys, fn); !errors.Is(err, fs.ErrNotExist) {
+		// Either it exists or there's some other error (e.g., the file
+		// system doesn't support Stat) that will be reported when it's
+		// read.
+		return fn
+	}
+	if match := findFold(fsys, fn); match != "" {
+		fmt.Printf("\r%s: warning: using %s, which differs in case\n", fn, match)
+		return match
+	}
+
+	if _, err := fs.Stat(fsys, search); err != nil {
+		if search = findFold(fsys, search); search == "" {
+			return ""
+		}
+	}
+	matches := fileIndex(fsys, search)[strings.ToLower(name)]
+	if len(matches) > 1 {
+		fmt.Printf("\r%s: warning: found in multiple folders (%s); using the first\n", name,
+			strings.Join(matches, ", "))
+	}
+	if len(matches) > 0 {
+		return matches[0]
+	}
+	return ""
+}
+
+type dirKey struct {
+	fsys fs.FS
+	dir  string
+}
+
+var (
+	// dirEntries and fileIndices cache directory listings for findFold and
+	// the files under directories for fileIndex.
+	dirEntries  = make(map[dirKey][]fs.DirEntry)
+	fileIndices = make(map[dirKey]map[string][]string)
+)
+
+// cacheKey returns the key for caching information about the directory.
+// Using an fs.FS whose dynamic type isn't comparable (e.g., fstest.MapFS)
+// as a map key would panic, so false is returned for those and they
+// aren't cached.
+func cacheKey(fsys fs.FS, dir string) (dirKey, bool) {
+	if !reflect.ValueOf(fsys).Comparable() {
+		return dirKey{}, false
+	}
+	return dirKey{fsys, dir}, true
+}
+
+// fileIndex returns the paths of all of the files under dir, indexed by
+// their lower-cased filenames.
+func fileIndex(fsys fs.FS, dir string) map[string][]string {
+	key, cache := cacheKey(fsys, dir)
+	if idx, ok := fileIndices[key]; ok && cache {
+		return idx
+	}
+	idx := make(map[string][]string)
+	fs.WalkDir(fsys, dir, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			name := strings.ToLower(d.Name())
+			idx[name] = append(idx[name], p)
+		}
+		return nil
+	})
+	if cache {
+		fileIndices[key] = idx
+	}
+	return idx
+}
+
+// findFold returns the path of the file in fsys that matches the given
+// path case-insensitively, or "" if there isn't one.
+func findFold(fsys fs.FS, p string) string {
+	dir := "."
+	for _, elem := range strings.Split(p, "/") {
+		key, cache := cacheKey(fsys, dir)
+		entries, ok := dirEntries[key]
+		if !ok || !cache {
+			var err error
+			if entries, err = fs.ReadDir(fsys, dir); err != nil {
+				return ""
+			}
+			if cache {
+				dirEntries[key] = entries
+			}
+		}
+
+		match := ""
+		for _, e := range entries {
+			if e.Name() == elem {
+				match = elem
+				break
+			} else if match == "" && strings.EqualFold(e.Name(), elem) {
+				match = e.Name()
+			}
+		}
+		if match == "" {
+			return ""
+		}
+		dir = path.Join(dir, match)
+	}
+	return dir
+}
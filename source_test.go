@@ -0,0 +1,52 @@
+// source_test.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+// fstest.MapFS isn't comparable, so it can't be used as a map key; it
+// should still be searched without panicking.
+func TestFindVideoMapMapFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"VideoMaps/ZNY/a.geojson":       {},
+		"VideoMaps/ZNY/Sub/B.geojson":   {},
+		"VideoMaps/ZNY/Other/C.geojson": {},
+	}
+
+	for _, test := range []struct {
+		name, want string
+	}{
+		{"a.geojson", "VideoMaps/ZNY/a.geojson"},
+		{"A.GEOJSON", "VideoMaps/ZNY/a.geojson"},
+		{"b.geojson", "VideoMaps/ZNY/Sub/B.geojson"},
+		{"c.geojson", "VideoMaps/ZNY/Other/C.geojson"},
+		{"d.geojson", ""},
+	} {
+		if got := findVideoMap(fsys, "VideoMaps/ZNY", "VideoMaps/ZNY", test.name); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+// Searching the ARTCC definitions for a facility's shouldn't record them
+// all as sources of the output.
+func TestFindParentARTCCSources(t *testing.T) {
+	defer func(s map[string]string) { sources = s }(sources)
+	sources = make(map[string]string)
+
+	fsys := fstest.MapFS{
+		"ARTCCs/ZBW.json": {Data: []byte(`{"facility": {"id": "ZBW", "childFacilities": [{"id": "A90"}]}}`)},
+		"ARTCCs/ZNY.json": {Data: []byte(`{"facility": {"id": "ZNY", "childFacilities": [{"id": "N90"}]}}`)},
+	}
+	if got := findParentARTCC(fsys, "N90"); got != "ZNY" {
+		t.Errorf("got %q, want ZNY", got)
+	}
+	if len(sources) != 0 {
+		t.Errorf("got sources %v, want none", sources)
+	}
+}
@@ -0,0 +1,336 @@
+// sqlite.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// Read-only SQLite
+
+// sqliteDB is a minimal reader for SQLite database files; it's just
+// enough to read the tables in GeoPackage files without taking on a full
+// SQLite dependency. See https://www.sqlite.org/fileformat.html.
+type sqliteDB struct {
+	b        []byte
+	pageSize int
+	usable   int // page size minus reserved space
+}
+
+type sqliteTable struct {
+	root       int
+	columns    []string
+	rowidAlias int // index of the INTEGER PRIMARY KEY column, or -1
+}
+
+func openSQLite(b []byte) (*sqliteDB, error) {
+	if len(b) < 100 || string(b[:16]) != "SQLite format 3\x00" {
+		return nil, fmt.Errorf("not a SQLite database")
+	}
+	ps := int(binary.BigEndian.Uint16(b[16:]))
+	if ps == 1 {
+		ps = 65536
+	}
+	if ps < 512 {
+		return nil, fmt.Errorf("invalid SQLite page size %d", ps)
+	}
+	return &sqliteDB{b: b, pageSize: ps, usable: ps - int(b[20])}, nil
+}
+
+// page returns the page with the given (1-based) number.
+func (db *sqliteDB) page(n int) ([]byte, error) {
+	off := (n - 1) * db.pageSize
+	if n < 1 || off+db.pageSize > len(db.b) {
+		return nil, fmt.Errorf("invalid SQLite page %d", n)
+	}
+	return db.b[off : off+db.pageSize], nil
+}
+
+// Table returns information about the named table from the schema.
+func (db *sqliteDB) Table(name string) (sqliteTable, error) {
+	var t sqliteTable
+	found := false
+	err := db.scan(1, 0, func(rowid int64, v []any) error {
+		if len(v) < 5 || found {
+			return nil
+		}
+		if typ, _ := v[0].(string); typ != "table" {
+			return nil
+		}
+		if n, _ := v[1].(string); !strings.EqualFold(n, name) {
+			return nil
+		}
+		root, _ := v[3].(int64)
+		sql, _ := v[4].(string)
+		t = sqliteTable{root: int(root)}
+		t.columns, t.rowidAlias = sqliteColumns(sql)
+		found = true
+		return nil
+	})
+	if err != nil {
+		return t, err
+	} else if !found {
+		return t, fmt.Errorf("%s: table not found", name)
+	}
+	return t, nil
+}
+
+// Scan calls the provided callback for each row of the table. Values are
+// nil, int64, float64, string, or []byte; rows are padded with nils
+// as needed to match the number of columns.
+func (db *sqliteDB) Scan(t sqliteTable, cb func(row []any) error) error {
+	return db.scan(t.root, 0, func(rowid int64, v []any) error {
+		for len(v) < len(t.columns) {
+			v = append(v, nil)
+		}
+		if t.rowidAlias != -1 {
+			v[t.rowidAlias] = rowid
+		}
+		return cb(v)
+	})
+}
+
+func (db *sqliteDB) scan(pgno int, depth int, cb func(rowid int64, v []any) error) error {
+	if depth > 64 {
+		return fmt.Errorf("SQLite b-tree is too deep")
+	}
+	p, err := db.page(pgno)
+	if err != nil {
+		return err
+	}
+	hdr := 0
+	if pgno == 1 {
+		hdr = 100 // skip the database header
+	}
+
+	ncells := int(binary.BigEndian.Uint16(p[hdr+3:]))
+	switch p[hdr] {
+	case 0x05: // interior table page
+		ptrs := hdr + 12
+		if ptrs+2*ncells > len(p) {
+			return fmt.Errorf("invalid SQLite page %d", pgno)
+		}
+		for i := 0; i < ncells; i++ {
+			off := int(binary.BigEndian.Uint16(p[ptrs+2*i:]))
+			if off+4 > len(p) {
+				return fmt.Errorf("invalid SQLite page %d", pgno)
+			}
+			if err := db.scan(int(binary.BigEndian.Uint32(p[off:])), depth+1, cb); err != nil {
+				return err
+			}
+		}
+		return db.scan(int(binary.BigEndian.Uint32(p[hdr+8:])), depth+1, cb)
+
+	case 0x0d: // leaf table page
+		ptrs := hdr + 8
+		if ptrs+2*ncells > len(p) {
+			return fmt.Errorf("invalid SQLite page %d", pgno)
+		}
+		for i := 0; i < ncells; i++ {
+			off := int(binary.BigEndian.Uint16(p[ptrs+2*i:]))
+			if off >= len(p) {
+				return fmt.Errorf("invalid SQLite page %d", pgno)
+			}
+			size, n := sqliteVarint(p[off:])
+			off += n
+			rowid, n := sqliteVarint(p[off:])
+			off += n
+
+			payload, err := db.payload(p, off, int(size))
+			if err != nil {
+				return err
+			}
+			v, err := sqliteRecord(payload)
+			if err != nil {
+				return err
+			}
+			if err := cb(int64(rowid), v); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unexpected SQLite page type %d", p[hdr])
+	}
+}
+
+// payload returns a cell's payload, following overflow pages as needed.
+func (db *sqliteDB) payload(p []byte, off int, size int) ([]byte, error) {
+	u := db.usable
+	x := u - 35
+	local := size
+	if size > x {
+		m := (u-12)*32/255 - 23
+		local = m + (size-m)%(u-4)
+		if local > x {
+			local = m
+		}
+	}
+	if off+local > len(p) || size < 0 {
+		return nil, fmt.Errorf("invalid SQLite cell")
+	}
+	if local == size {
+		return p[off : off+size], nil
+	}
+	if off+local+4 > len(p) {
+		return nil, fmt.Errorf("invalid SQLite cell")
+	}
+
+	out := append([]byte(nil), p[off:off+local]...)
+	next := int(binary.BigEndian.Uint32(p[off+local:]))
+	for len(out) < size && next != 0 {
+		op, err := db.page(next)
+		if err != nil {
+			return nil, err
+		}
+		next = int(binary.BigEndian.Uint32(op))
+		n := min(u-4, size-len(out))
+		out = append(out, op[4:4+n]...)
+	}
+	if len(out) < size {
+		return nil, fmt.Errorf("truncated SQLite overflow chain")
+	}
+	return out, nil
+}
+
+func sqliteVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 8 && i < len(b); i++ {
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	if len(b) < 9 {
+		return v, len(b)
+	}
+	return v<<8 | uint64(b[8]), 9
+}
+
+// sqliteRecord decodes the values in a record.
+func sqliteRecord(b []byte) ([]any, error) {
+	hsize, pos := sqliteVarint(b)
+	if int(hsize) > len(b) {
+		return nil, fmt.Errorf("invalid SQLite record")
+	}
+	var types []uint64
+	for pos < int(hsize) {
+		t, n := sqliteVarint(b[pos:])
+		types = append(types, t)
+		pos += n
+	}
+
+	var values []any
+	pos = int(hsize)
+	for _, t := range types {
+		var size int
+		switch {
+		case t <= 4:
+			size = int(t)
+		case t == 5:
+			size = 6
+		case t == 6 || t == 7:
+			size = 8
+		case t >= 12:
+			size = int(t-12) / 2
+		}
+		if pos+size > len(b) {
+			return nil, fmt.Errorf("invalid SQLite record")
+		}
+		v := b[pos : pos+size]
+		pos += size
+
+		switch {
+		case t == 0:
+			values = append(values, nil)
+		case t <= 6:
+			// big-endian two's complement
+			x := int64(int8(v[0]))
+			for _, c := range v[1:] {
+				x = x<<8 | int64(c)
+			}
+			values = append(values, x)
+		case t == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(v)))
+		case t == 8 || t == 9:
+			values = append(values, int64(t-8))
+		case t >= 12 && t%2 == 0:
+			values = append(values, v)
+		case t >= 13:
+			values = append(values, string(v))
+		default:
+			return nil, fmt.Errorf("invalid SQLite serial type %d", t)
+		}
+	}
+	return values, nil
+}
+
+// sqliteColumns returns the column names from a CREATE TABLE statement
+// as well as the index of the column that is an alias for the rowid, if
+// any.
+func sqliteColumns(sql string) ([]string, int) {
+	start, end := strings.Index(sql, "("), strings.LastIndex(sql, ")")
+	if start == -1 || end < start {
+		return nil, -1
+	}
+
+	// Split the definitions at top-level commas.
+	var defs []string
+	depth, last := 0, start+1
+	var quote rune
+	for i, c := range sql[start+1 : end] {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`' || c == '[':
+			quote = c
+			if c == '[' {
+				quote = ']'
+			}
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			defs = append(defs, sql[last:start+1+i])
+			last = start + 2 + i
+		}
+	}
+	defs = append(defs, sql[last:end])
+
+	var columns []string
+	rowidAlias := -1
+	for _, def := range defs {
+		f := strings.Fields(def)
+		if len(f) == 0 {
+			continue
+		}
+		switch strings.ToUpper(f[0]) {
+		case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN":
+			continue
+		}
+		if len(f) > 1 && strings.EqualFold(f[1], "INTEGER") && strings.Contains(strings.ToUpper(def), "PRIMARY KEY") {
+			rowidAlias = len(columns)
+		}
+		columns = append(columns, strings.Trim(f[0], "\"'`[]"))
+	}
+	return columns, rowidAlias
+}
+
+func (t sqliteTable) Column(name string) int {
+	for i, c := range t.columns {
+		if strings.EqualFold(c, name) {
+			return i
+		}
+	}
+	return -1
+}
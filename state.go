@@ -0,0 +1,68 @@
+// state.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// Changes since the last run
+//
+// With -state, the checksum of each map is recorded in the given file
+// after each conversion so that the next one can report which maps were
+// added, removed, or changed, which facility engineers can pass along to
+// their controllers after CRC data updates.
+
+// runState is the contents of the -state file.
+type runState struct {
+	Updated string            `json:"updated"` // RFC 3339
+	AIRAC   string            `json:"airac,omitempty"`
+	Maps    map[string]string `json:"maps"` // map name to checksum; see mapChecksum
+}
+
+// reportChanges prints how the given maps differ from the ones recorded
+// in the -state file, if there is one, and then records them there.
+func reportChanges(maps []STARSMap) {
+	if *stateFile == "" {
+		return
+	}
+
+	cur := runState{Updated: creationTime(), Maps: make(map[string]string)}
+	if airac != nil {
+		cur.AIRAC = airac.String()
+	}
+	for _, m := range versionedMaps(maps) {
+		cur.Maps[m.Name] = mapChecksum(m)
+	}
+
+	var prev runState
+	b, err := os.ReadFile(*stateFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		fmt.Printf("%s: no previous state; recording %d maps\n", *stateFile, len(cur.Maps))
+	} else {
+		errorExit(*stateFile, err)
+		errorExit(*stateFile, json.Unmarshal(b, &prev))
+
+		since := "the last run (" + prev.Updated
+		if prev.AIRAC != "" && prev.AIRAC != cur.AIRAC {
+			since += ", AIRAC " + prev.AIRAC
+		}
+		since += ")"
+		if diff := diffChecksums(prev.Maps, cur.Maps); len(diff) == 0 {
+			fmt.Printf("No changes to the maps since %s\n", since)
+		} else {
+			fmt.Printf("Changes to the maps since %s:\n%s\n", since, strings.Join(diff, "\n"))
+		}
+	}
+
+	writeJSON(cur, *stateFile)
+	fmt.Printf("Done.\n")
+}
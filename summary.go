@@ -0,0 +1,43 @@
+// summary.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// CSV summary
+
+// writeSummaryCSV writes a CSV file with a row for each map giving its
+// name, label, category, STARS id, number of features (lines and labels),
+// number of line vertices, and bounding box, for keeping track of a
+// facility's maps in a spreadsheet.
+func writeSummaryCSV(maps []STARSMap, fn string) {
+	fmt.Printf("Writing %s... ", fn)
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	w.Write([]string{"name", "short_name", "group", "stars_id", "features", "vertices",
+		"min_longitude", "min_latitude", "max_longitude", "max_latitude"})
+
+	coord := func(v float32) string { return strconv.FormatFloat(float64(v), 'f', -1, 32) }
+	for _, m := range maps {
+		group := groupCategory(m.Group)
+		row := []string{m.Name, m.Label, group, strconv.Itoa(m.Id),
+			strconv.Itoa(len(m.Lines) + len(m.Labels)), strconv.Itoa(countVertices(m.Lines))}
+		if lo, hi, ok := mapBounds(m); ok {
+			row = append(row, coord(lo[0]), coord(lo[1]), coord(hi[0]), coord(hi[1]))
+		} else {
+			row = append(row, "", "", "", "")
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	errorExit(fn, w.Error())
+	errorExit("creating file", writeFileAtomic(fn, b.Bytes(), 0o644))
+}
@@ -0,0 +1,179 @@
+# Writes the FlatGeobuf files for the tests in flatgeobuf_test.go,
+# following the FlatGeobuf schemas (header.fbs and feature.fbs) and the
+# reference implementation's packed R-tree layout.
+#
+#   python3 gen.py
+
+import os
+import struct
+
+# FlatGeobuf geometry types
+Unknown, Point, LineString, Polygon, MultiPoint, MultiLineString, MultiPolygon = range(7)
+
+class Builder:
+    """Lays out flatbuffers front to back: each table's vtable precedes
+    it and the objects it refers to follow it."""
+
+    def __init__(self):
+        self.b = bytearray()
+
+    def align(self, n, extra=0):
+        while (len(self.b) + extra) % n:
+            self.b.append(0)
+
+    def finish(self, root):
+        self.b += b'\0\0\0\0'
+        self.patch(0, self.write(root))
+        return bytes(self.b)
+
+    def patch(self, at, target):
+        struct.pack_into('<I', self.b, at, target - at)
+
+    def write(self, obj):
+        kind, v = obj
+        if kind == 'table':
+            return self.table(v)
+        if kind == 'string':
+            self.align(4)
+            pos = len(self.b)
+            self.b += struct.pack('<I', len(v)) + v.encode() + b'\0'
+            return pos
+        if kind == 'tables':
+            self.align(4)
+            pos = len(self.b)
+            self.b += struct.pack('<I', len(v))
+            slots = []
+            for _ in v:
+                slots.append(len(self.b))
+                self.b += b'\0\0\0\0'
+            for slot, t in zip(slots, v):
+                self.patch(slot, self.table(t))
+            return pos
+        # A vector of scalars, e.g. ('<d', [1.0, 2.0]); the elements are
+        # aligned to their size.
+        size = struct.calcsize(kind)
+        self.align(max(size, 4), 4)
+        pos = len(self.b)
+        self.b += struct.pack('<I', len(v)) + b''.join(struct.pack(kind, x) for x in v)
+        return pos
+
+    def table(self, fields):
+        """fields maps field indices to ('<B', value) scalars or objects
+        for write."""
+        n = max(fields) + 1 if fields else 0
+        layout, size = {}, 4
+        for i in sorted(fields):
+            kind = fields[i][0]
+            fsize = struct.calcsize(kind) if kind.startswith('<') and not isinstance(fields[i][1], list) else 4
+            while size % fsize:
+                size += 1
+            layout[i] = size
+            size += fsize
+
+        self.align(2)
+        vt = len(self.b)
+        self.b += struct.pack('<HH', 4 + 2 * n, size)
+        for i in range(n):
+            self.b += struct.pack('<H', layout.get(i, 0))
+        self.align(8, 4)  # so that 8-byte fields are aligned
+        pos = len(self.b)
+        self.b += struct.pack('<i', pos - vt) + bytes(size - 4)
+
+        refs = []
+        for i, (kind, v) in fields.items():
+            if kind.startswith('<') and not isinstance(v, list):
+                struct.pack_into(kind, self.b, pos + layout[i], v)
+            else:
+                refs.append((pos + layout[i], (kind, v)))
+        for at, obj in refs:
+            self.patch(at, self.write(obj))
+        return pos
+
+def sized(fields):
+    b = Builder().finish(('table', fields))
+    return struct.pack('<I', len(b)) + b
+
+def geometry(xy, ends=None, type=None, parts=None):
+    g = {}
+    if ends is not None:
+        g[0] = ('<I', ends)
+    if xy:
+        g[1] = ('<d', [c for p in xy for c in p])
+    if type is not None:
+        g[6] = ('<B', type)
+    if parts is not None:
+        g[7] = ('tables', parts)
+    return g
+
+def envelope(xy):
+    xs, ys = [p[0] for p in xy], [p[1] for p in xy]
+    return (min(xs), min(ys), max(xs), max(ys))
+
+def rtree(envelopes, offsets, node_size):
+    """Returns the packed R-tree for the features, whose size is given
+    by the reference implementation's PackedRTree::size."""
+    n = len(envelopes)
+    levels = [list(zip(envelopes, offsets))]
+    num_nodes = n
+    while True:  # do ... while (n != 1)
+        n = (n + node_size - 1) // node_size
+        num_nodes += n
+        prev = levels[-1]
+        level = []
+        for i in range(0, len(prev), node_size):
+            group = prev[i:i + node_size]
+            e = (min(g[0][0] for g in group), min(g[0][1] for g in group),
+                 max(g[0][2] for g in group), max(g[0][3] for g in group))
+            level.append((e, i))
+        levels.append(level)
+        if n == 1:
+            break
+    nodes = [node for level in reversed(levels) for node in level]
+    assert len(nodes) == num_nodes
+    return b''.join(struct.pack('<4dQ', *e, off) for e, off in nodes)
+
+def write(fn, name, geometry_type, features, node_size=16, crs=None, envelopes=None):
+    header = {7: ('tables', [{0: ('string', 'name'), 1: ('<B', 11)}]),
+              2: ('<B', geometry_type), 8: ('<Q', len(features)), 9: ('<H', node_size)}
+    if name:
+        header[0] = ('string', name)
+    if crs:
+        header[10] = ('table', {0: ('string', 'EPSG'), 1: ('<i', crs)})
+
+    feats = [sized({0: ('table', g), 1: ('<B', [0, 0, 1, 0, 0, 0, ord('x')])}) for g in features]
+    out = b'fgb\x03fgb\x01' + sized(header)
+    if node_size > 0:
+        offsets, off = [], 0
+        for f in feats:
+            offsets.append(off)
+            off += len(f)
+        out += rtree(envelopes, offsets, node_size)
+    out += b''.join(feats)
+    with open(os.path.join(os.path.dirname(os.path.abspath(__file__)), fn), 'wb') as f:
+        f.write(out)
+
+# A single indexed feature, for which the index has a leaf and a root.
+ring = [(-74, 40), (-73, 40), (-73, 41), (-74, 40)]
+write('one.fgb', 'boundary', Polygon, [geometry(ring)], envelopes=[envelope(ring)])
+
+# Enough features for a two-level index with the default node size.
+lines = [[(-74 + i / 100, 40), (-74 + i / 100, 40.5), (-73.9 + i / 100, 40.5)] for i in range(20)]
+write('lines.fgb', 'lines', LineString, [geometry(l) for l in lines],
+      envelopes=[envelope(l) for l in lines])
+
+# A small node size, for a three-level index.
+write('lines4.fgb', 'lines', LineString, [geometry(l) for l in lines], node_size=4,
+      envelopes=[envelope(l) for l in lines])
+
+# Without an index or a dataset name; the features give their own types.
+hole = [(-73.8, 40.2), (-73.2, 40.2), (-73.2, 40.8), (-73.8, 40.2)]
+write('mixed.fgb', '', Unknown, [
+    geometry(ring + hole, ends=[4, 8], type=Polygon),
+    geometry([(-72, 39), (-71, 39), (-71, 38), (-70, 38)], ends=[2, 4], type=MultiLineString),
+    geometry(None, type=MultiPolygon, parts=[geometry(ring), geometry(hole)]),
+    geometry([(-75, 42)], type=Point),
+], node_size=0)
+
+# UTM zone 18N, on the central meridian at the equator and at 40N.
+utm = [(500000, 0), (500000, 4427757.22)]
+write('utm.fgb', 'utm', LineString, [geometry(utm)], crs=32618, envelopes=[envelope(utm)])
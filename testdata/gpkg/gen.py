@@ -0,0 +1,115 @@
+# Writes layers.gpkg, a GeoPackage for the tests in gpkg_test.go, using
+# Python's sqlite3 module so that the file is written by SQLite itself.
+#
+#   python3 gen.py
+
+import os
+import sqlite3
+import struct
+
+# The header flags are, from the low bit: the byte order, the envelope
+# type (3 bits), whether the geometry is empty (0x10), and whether it's an
+# extended geometry (0x20).
+def gpkg_blob(wkb, srs=4326, envelope=None, big_endian=False, empty=False):
+    flags = 0 if big_endian else 1
+    env = b''
+    if envelope is not None:
+        flags |= 1 << 1  # [minx, maxx, miny, maxy]
+        env = struct.pack('>4d' if big_endian else '<4d', *envelope)
+    if empty:
+        flags |= 0x10
+    return b'GP' + bytes([0, flags]) + struct.pack('>i' if big_endian else '<i', srs) + env + wkb
+
+def wkb_points(bo, pts, dims=2):
+    out = struct.pack(bo + 'I', len(pts))
+    for p in pts:
+        out += struct.pack(bo + 'd' * dims, *(tuple(p) + (0.0,) * (dims - len(p))))
+    return out
+
+def wkb(kind, body, big_endian=False):
+    bo = '>' if big_endian else '<'
+    return bytes([0 if big_endian else 1]) + struct.pack(bo + 'I', kind) + body
+
+def linestring(pts, big_endian=False, kind=2, dims=2):
+    return wkb(kind, wkb_points('>' if big_endian else '<', pts, dims), big_endian)
+
+def polygon(rings, big_endian=False, kind=3, dims=2):
+    bo = '>' if big_endian else '<'
+    body = struct.pack(bo + 'I', len(rings))
+    for r in rings:
+        body += wkb_points(bo, r, dims)
+    return wkb(kind, body, big_endian)
+
+def multi(kind, geoms, big_endian=False):
+    bo = '>' if big_endian else '<'
+    return wkb(kind, struct.pack(bo + 'I', len(geoms)) + b''.join(geoms), big_endian)
+
+def grid_line(i):
+    return [(-74 + i / 1000, 40 + i / 2000), (-73.5 + i / 1000, 40.25 + i / 2000)]
+
+def long_line():
+    return [(-75 + i / 10000, 41 + (i % 7) / 100) for i in range(3000)]
+
+fn = os.path.join(os.path.dirname(os.path.abspath(__file__)), 'layers.gpkg')
+if os.path.exists(fn):
+    os.remove(fn)
+db = sqlite3.connect(fn)
+db.execute('PRAGMA page_size = 1024')
+db.execute('PRAGMA application_id = 0x47504b47')
+db.execute('''CREATE TABLE gpkg_spatial_ref_sys (srs_name TEXT NOT NULL, srs_id INTEGER PRIMARY KEY,
+    organization TEXT NOT NULL, organization_coordsys_id INTEGER NOT NULL, definition TEXT NOT NULL,
+    description TEXT)''')
+db.executemany('INSERT INTO gpkg_spatial_ref_sys VALUES (?, ?, ?, ?, ?, ?)', [
+    ('WGS 84', 4326, 'EPSG', 4326, 'undefined', None),
+    ('WGS 84 / UTM zone 18N', 32618, 'EPSG', 32618, 'undefined', None),
+])
+db.execute('''CREATE TABLE gpkg_contents (table_name TEXT NOT NULL PRIMARY KEY, data_type TEXT NOT NULL,
+    identifier TEXT UNIQUE, description TEXT DEFAULT '', srs_id INTEGER)''')
+db.execute('''CREATE TABLE gpkg_geometry_columns (table_name TEXT NOT NULL, column_name TEXT NOT NULL,
+    geometry_type_name TEXT NOT NULL, srs_id INTEGER NOT NULL, z TINYINT NOT NULL, m TINYINT NOT NULL,
+    CONSTRAINT pk_geom_cols PRIMARY KEY (table_name, column_name))''')
+
+def layer(name, column, srs, rows):
+    db.execute(f'CREATE TABLE {name} (fid INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, {column} BLOB)')
+    db.execute('INSERT INTO gpkg_contents (table_name, data_type, identifier, srs_id) VALUES (?, ?, ?, ?)',
+               (name, 'features', name, srs))
+    db.execute('INSERT INTO gpkg_geometry_columns VALUES (?, ?, ?, ?, 0, 0)', (name, column, 'GEOMETRY', srs))
+    db.executemany(f'INSERT INTO {name} (name, {column}) VALUES (?, ?)', rows)
+
+# Little-endian LineStrings with envelopes, along with a point, a NULL
+# geometry, and an empty one, which don't give any lines.
+layer('runways', 'geom', 4326, [
+    ('4L/22R', gpkg_blob(linestring([(-73.7900, 40.6225), (-73.7662, 40.6455)]),
+                         envelope=(-73.79, -73.7662, 40.6225, 40.6455))),
+    ('4R/22L', gpkg_blob(linestring([(-73.7794, 40.6279), (-73.7583, 40.6513)]))),
+    ('tower', gpkg_blob(wkb(1, struct.pack('<dd', -73.7781, 40.6413)))),
+    ('none', None),
+    ('empty', gpkg_blob(linestring([]), empty=True)),
+    ('empty point', gpkg_blob(wkb(1, struct.pack('<dd', float('nan'), float('nan'))), empty=True)),
+    ('31L/13R', gpkg_blob(linestring([(-73.7714, 40.6426), (-73.7536, 40.6561), (-73.7500, 40.6590)]))),
+])
+
+# Big-endian and 3D and measured geometries in ISO and EWKB forms.
+layer('airspace', 'shape', 4326, [
+    ('ring', gpkg_blob(polygon([[(-74, 40), (-73, 40), (-73, 41), (-74, 40)],
+                                [(-73.8, 40.2), (-73.2, 40.2), (-73.2, 40.8), (-73.8, 40.2)]], big_endian=True),
+                       big_endian=True)),
+    ('iso z', gpkg_blob(multi(1005, [linestring([(-72, 39, 100), (-71, 39, 200)], kind=1002, dims=3),
+                                     linestring([(-71, 39, 200), (-71, 38, 300)], kind=1002, dims=3)]))),
+    ('ewkb zm', gpkg_blob(multi(6, [polygon([[(-70, 35, 1, 2), (-69, 35, 1, 2), (-69, 36, 1, 2)]],
+                                             kind=0xc0000003, dims=4, big_endian=True)]))),
+])
+
+# Enough rows to need interior b-tree pages, and a line whose geometry
+# needs overflow pages.
+layer('grid', 'geom', 4326, [(f'line {i}', gpkg_blob(linestring(grid_line(i)))) for i in range(2000)] +
+      [('long', gpkg_blob(linestring(long_line())))])
+
+# UTM zone 18N, which is reprojected.
+layer('utm', 'geom', 32618, [
+    ('meridian', gpkg_blob(linestring([(500000, 0), (500000, 4427757.22)]), srs=32618)),
+])
+
+db.commit()
+db.execute('VACUUM')
+db.close()
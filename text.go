@@ -0,0 +1,62 @@
+// text.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// decodeText returns the given text file contents as UTF-8 without a
+// byte order mark. Files saved by Windows editors may start with a UTF-8
+// BOM or be UTF-16; UTF-16 is detected either from its BOM or, for files
+// without one, from the zero bytes of an initial ASCII character.
+func decodeText(b []byte) []byte {
+	var order binary.ByteOrder
+	switch {
+	case bytes.HasPrefix(b, []byte{0xef, 0xbb, 0xbf}):
+		return b[3:]
+	case bytes.HasPrefix(b, []byte{0xff, 0xfe}):
+		order, b = binary.LittleEndian, b[2:]
+	case bytes.HasPrefix(b, []byte{0xfe, 0xff}):
+		order, b = binary.BigEndian, b[2:]
+	case len(b) >= 2 && b[0] != 0 && b[1] == 0:
+		order = binary.LittleEndian
+	case len(b) >= 2 && b[0] == 0 && b[1] != 0:
+		order = binary.BigEndian
+	default:
+		return b
+	}
+
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = order.Uint16(b[2*i:])
+	}
+	var out []byte
+	for _, r := range utf16.Decode(u) {
+		out = utf8.AppendRune(out, r)
+	}
+	return out
+}
+
+// newXMLDecoder returns a decoder for the given XML, which is first
+// converted with decodeText. Since that leaves UTF-16 documents as UTF-8,
+// their encoding declarations are then ignored.
+func newXMLDecoder(b []byte) *xml.Decoder {
+	d := xml.NewDecoder(bytes.NewReader(decodeText(b)))
+	d.CharsetReader = func(charset string, r io.Reader) (io.Reader, error) {
+		if strings.HasPrefix(strings.ToLower(charset), "utf-16") {
+			return r, nil
+		}
+		return nil, fmt.Errorf("%s: unsupported character encoding", charset)
+	}
+	return d
+}
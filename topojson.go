@@ -0,0 +1,314 @@
+// topojson.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// TopoJSON
+
+type TopoJSON struct {
+	Type      string                      `json:"type"`
+	Transform *TopoJSONTransform          `json:"transform,omitempty"`
+	Objects   map[string]TopoJSONGeometry `json:"objects"`
+	Arcs      [][][2]float64              `json:"arcs"`
+}
+
+type TopoJSONTransform struct {
+	Scale     [2]float64 `json:"scale"`
+	Translate [2]float64 `json:"translate"`
+}
+
+type TopoJSONGeometry struct {
+	Type       string                 `json:"type"`
+	Arcs       json.RawMessage        `json:"arcs,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+	Geometries []TopoJSONGeometry     `json:"geometries,omitempty"`
+}
+
+// isTopoJSON reports whether the JSON is a TopoJSON topology rather than
+// GeoJSON.
+func isTopoJSON(b []byte) bool {
+	var t struct {
+		Type string `json:"type"`
+	}
+	return json.Unmarshal(decodeText(b), &t) == nil && t.Type == "Topology"
+}
+
+func importTopoJSON(fn string) ([]STARSMap, error) {
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	return parseTopoJSON(b)
+}
+
+// parseTopoJSON returns a map for each of the topology's objects, with
+// the arcs of its lines and polygons stitched back together.
+func parseTopoJSON(b []byte) ([]STARSMap, error) {
+	var topo TopoJSON
+	if err := UnmarshalJSON(b, &topo); err != nil {
+		return nil, err
+	}
+	if topo.Type != "Topology" {
+		return nil, fmt.Errorf("%q: expected \"Topology\"", topo.Type)
+	}
+
+	// Decode the arcs: quantized topologies store delta-encoded integer
+	// positions that must be transformed.
+	arcs := make([][]Point2LL, len(topo.Arcs))
+	for i, arc := range topo.Arcs {
+		var x, y float64
+		for _, p := range arc {
+			if t := topo.Transform; t != nil {
+				x, y = x+p[0], y+p[1]
+				arcs[i] = append(arcs[i], Point2LL{float32(x*t.Scale[0] + t.Translate[0]),
+					float32(y*t.Scale[1] + t.Translate[1])})
+			} else {
+				arcs[i] = append(arcs[i], Point2LL{float32(p[0]), float32(p[1])})
+			}
+		}
+	}
+
+	var names []string
+	for name := range topo.Objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var maps []STARSMap
+	for _, name := range names {
+		lines, err := topoLines(topo.Objects[name], arcs)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		sm := STARSMap{
+			Group: 0,
+			Label: strings.ToUpper(name),
+			Name:  name,
+			Lines: lines,
+		}
+		// Use the label and category written by writeTopoJSON, if present.
+		props := topo.Objects[name].Properties
+		if label, ok := props["label"].(string); ok && label != "" {
+			sm.Label = label
+		}
+		if category, ok := props["category"].(string); ok {
+			sm.Group = categoryGroup(strings.ToUpper(category))
+		}
+		maps = append(maps, sm)
+	}
+	return maps, nil
+}
+
+// topoLines returns the lines and polygon rings of the given geometry.
+func topoLines(g TopoJSONGeometry, arcs [][]Point2LL) ([][]Point2LL, error) {
+	// stitch joins the given arcs into a single line; negative indices
+	// (one's complement) denote reversed arcs.
+	stitch := func(indices []int) ([]Point2LL, error) {
+		var line []Point2LL
+		for _, idx := range indices {
+			i := idx
+			if idx < 0 {
+				i = ^idx
+			}
+			if i >= len(arcs) {
+				return nil, fmt.Errorf("arc index %d out of range", idx)
+			}
+			arc := arcs[i]
+			if idx < 0 {
+				arc = append([]Point2LL(nil), arc...)
+				for a, b := 0, len(arc)-1; a < b; a, b = a+1, b-1 {
+					arc[a], arc[b] = arc[b], arc[a]
+				}
+			}
+			if len(line) > 0 && len(arc) > 0 {
+				arc = arc[1:] // shared with the end of the previous arc
+			}
+			line = append(line, arc...)
+		}
+		return line, nil
+	}
+
+	var lines [][]Point2LL
+	addLines := func(indices ...[]int) error {
+		for _, idx := range indices {
+			l, err := stitch(idx)
+			if err != nil {
+				return err
+			}
+			if len(l) > 1 {
+				lines = append(lines, l)
+			}
+		}
+		return nil
+	}
+
+	var err error
+	switch g.Type {
+	case "LineString":
+		var a []int
+		if err = json.Unmarshal(g.Arcs, &a); err == nil {
+			err = addLines(a)
+		}
+	case "MultiLineString", "Polygon":
+		var a [][]int
+		if err = json.Unmarshal(g.Arcs, &a); err == nil {
+			err = addLines(a...)
+		}
+	case "MultiPolygon":
+		var a [][][]int
+		if err = json.Unmarshal(g.Arcs, &a); err == nil {
+			for _, poly := range a {
+				if err = addLines(poly...); err != nil {
+					break
+				}
+			}
+		}
+	case "GeometryCollection":
+		for _, child := range g.Geometries {
+			l, err := topoLines(child, arcs)
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, l...)
+		}
+	}
+	return lines, err
+}
+
+///////////////////////////////////////////////////////////////////////////
+// TopoJSON export
+
+type topoJSONOutput struct {
+	Type    string                        `json:"type"`
+	Objects map[string]topoJSONOutputGeom `json:"objects"`
+	Arcs    [][]Point2LL                  `json:"arcs"`
+}
+
+type topoJSONOutputGeom struct {
+	Type        string                 `json:"type"`
+	Arcs        [][]int                `json:"arcs,omitempty"`
+	Coordinates *Point2LL              `json:"coordinates,omitempty"`
+	Properties  map[string]interface{} `json:"properties,omitempty"`
+	Geometries  []topoJSONOutputGeom   `json:"geometries,omitempty"`
+}
+
+// writeTopoJSON writes the maps to a TopoJSON file with an object for
+// each map. Its lines are stored as a MultiLineString and its labels as
+// Points with a "text" property.
+func writeTopoJSON(maps []STARSMap, fn string) {
+	fmt.Printf("Writing %s... ", fn)
+	b, err := json.Marshal(buildTopology(maps))
+	errorExit("JSON error", err)
+	errorExit("creating file", writeFileAtomic(fn, b, 0o644))
+}
+
+// buildTopology returns a topology for the maps. Lines are split into
+// arcs at junctions--their endpoints and the points where they meet or
+// part ways with other lines--so that arcs that are shared, such as
+// common boundaries, are only stored once.
+func buildTopology(maps []STARSMap) topoJSONOutput {
+	// Find the junctions. A point in the middle of a line is a junction
+	// if it appears elsewhere with different neighbors.
+	type neighbors struct{ a, b Point2LL }
+	seen := make(map[Point2LL]neighbors)
+	junction := make(map[Point2LL]bool)
+	for _, m := range maps {
+		for _, l := range m.Lines {
+			for i, p := range l {
+				if i == 0 || i == len(l)-1 {
+					junction[p] = true
+					continue
+				}
+				n := neighbors{l[i-1], l[i+1]}
+				if n.b[0] < n.a[0] || (n.b[0] == n.a[0] && n.b[1] < n.a[1]) {
+					n.a, n.b = n.b, n.a
+				}
+				if prev, ok := seen[p]; !ok {
+					seen[p] = n
+				} else if prev != n {
+					junction[p] = true
+				}
+			}
+		}
+	}
+
+	topo := topoJSONOutput{Type: "Topology", Objects: make(map[string]topoJSONOutputGeom)}
+	arcIndex := make(map[string]int)
+	key := func(arc []Point2LL) string {
+		var b strings.Builder
+		for _, p := range arc {
+			fmt.Fprintf(&b, "%v,%v;", p[0], p[1])
+		}
+		return b.String()
+	}
+	addArc := func(arc []Point2LL) int {
+		k := key(arc)
+		if i, ok := arcIndex[k]; ok {
+			return i
+		}
+		rev := slices.Clone(arc)
+		slices.Reverse(rev)
+		if i, ok := arcIndex[key(rev)]; ok {
+			return ^i
+		}
+		arcIndex[k] = len(topo.Arcs)
+		topo.Arcs = append(topo.Arcs, arc)
+		return len(topo.Arcs) - 1
+	}
+
+	used := make(map[string]bool)
+	for _, m := range maps {
+		var lines [][]int
+		for _, l := range m.Lines {
+			if len(l) < 2 {
+				continue
+			}
+			var arcs []int
+			start := 0
+			for i := 1; i < len(l); i++ {
+				if i == len(l)-1 || junction[l[i]] {
+					arcs = append(arcs, addArc(l[start:i+1]))
+					start = i
+				}
+			}
+			lines = append(lines, arcs)
+		}
+
+		category := groupCategory(m.Group)
+		obj := topoJSONOutputGeom{
+			Type:       "GeometryCollection",
+			Properties: map[string]interface{}{"label": m.Label, "category": category, "id": m.Id},
+		}
+		if len(lines) > 0 {
+			obj.Geometries = append(obj.Geometries, topoJSONOutputGeom{Type: "MultiLineString", Arcs: lines})
+		}
+		for _, l := range m.Labels {
+			p := l.P
+			obj.Geometries = append(obj.Geometries, topoJSONOutputGeom{
+				Type:        "Point",
+				Coordinates: &p,
+				Properties:  map[string]interface{}{"text": []string{l.Text}},
+			})
+		}
+
+		// Object names must be unique.
+		name := m.Name
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s-%d", m.Name, i)
+		}
+		used[name] = true
+		topo.Objects[name] = obj
+	}
+	return topo
+}
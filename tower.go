@@ -0,0 +1,37 @@
+// tower.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// convertTowerCabMaps converts the video maps referenced by the tower cab
+// configurations of the ARTCC's facilities (or of the given facility and
+// its descendants). They are returned as STARSMaps so that vice can load
+// them the same way as the STARS maps.
+func convertTowerCabMaps(artcc ARTCC, base string, facilityId string, centers []Point2LL) []STARSMap {
+	specs := artcc.VideoMapSpecs()
+
+	var ids []string
+	artcc.Scope(facilityId).Walk(func(fac *CRCFacility) {
+		if tc := fac.TowerCabConfiguration; tc != nil && tc.VideoMapId != "" {
+			if _, ok := specs[tc.VideoMapId]; !ok {
+				fmt.Printf("\r%s: warning: tower cab video map %s not found\n", fac.Id, tc.VideoMapId)
+			} else if !slices.Contains(ids, tc.VideoMapId) {
+				ids = append(ids, tc.VideoMapId)
+			}
+		}
+	})
+
+	var maps []STARSMap
+	for order, m := range artcc.VideoMaps {
+		if slices.Contains(ids, m.Id) {
+			maps = append(maps, convertSTARSMap(m, order, base, centers))
+		}
+	}
+	return maps
+}
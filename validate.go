@@ -0,0 +1,550 @@
+// validate.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+	"unicode/utf8"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// Validation
+
+// finding is a problem found in the maps being converted. Findings are
+// reported as they are found; the validate command also summarizes them
+// at the end.
+type finding struct {
+	Severity string // "error" or "warning"
+	Rule     string // identifies the check, for -sarif
+	File     string
+	Line     int // of the feature in the file, if known
+	Map      string
+	Feature  int // index in the file's features, or -1
+	Vertex   int // index in the feature's line or ring, or -1
+	Message  string
+}
+
+// findings holds everything that has been reported.
+var findings []finding
+
+// mapFiles records the file that each map was read from, by name, so that
+// findings about maps can be attributed to their files.
+var mapFiles = make(map[string]string)
+
+func (f finding) location() string {
+	loc := f.File
+	if loc == "" {
+		loc = f.Map
+	}
+	if f.Feature >= 0 {
+		loc += fmt.Sprintf(": feature %d", f.Feature)
+		if f.Vertex >= 0 {
+			loc += fmt.Sprintf(", vertex %d", f.Vertex)
+		}
+	}
+	return loc
+}
+
+func report(f finding) {
+	findings = append(findings, f)
+	if *annotate {
+		printAnnotation(f)
+	} else {
+		fmt.Printf("\r%s: %s: %s\n", f.location(), f.Severity, f.Message)
+	}
+}
+
+// checkFeatures reports coordinates in the features of the given map's
+// file that can't be right: NaNs and infinities, which may come from
+// overflow or reprojection, latitudes and longitudes that are out of
+// range, and (0, 0), which is usually a missing point.
+func checkFeatures(fn string, m VideoMapSpec, features []GeoJSONFeature) {
+	check := func(f GeoJSONFeature, ring, vertex int, p Point2LL) {
+		lon, lat := float64(p[0]), float64(p[1])
+		var msg string
+		switch {
+		case math.IsNaN(lon) || math.IsNaN(lat):
+			msg = "coordinate is NaN"
+		case math.IsInf(lon, 0) || math.IsInf(lat, 0):
+			msg = "coordinate is infinite"
+		case lat < -90 || lat > 90:
+			msg = fmt.Sprintf("latitude %g is out of range", lat)
+		case lon < -180 || lon > 180:
+			msg = fmt.Sprintf("longitude %g is out of range", lon)
+		case lon == 0 && lat == 0:
+			msg = "coordinate is (0, 0)"
+		default:
+			return
+		}
+		if ring >= 0 {
+			msg = fmt.Sprintf("ring %d: %s", ring, msg)
+		}
+		report(finding{Severity: "error", Rule: "invalid-coordinate", File: fn, Line: f.line, Map: m.Name,
+			Feature: f.index, Vertex: vertex, Message: msg})
+	}
+
+	for _, f := range features {
+		if err := f.Geometry.err; errors.Is(err, errTooManyCoordinates) {
+			report(finding{Severity: "error", Rule: "input-limit", File: fn, Line: f.line, Map: m.Name,
+				Feature: f.index, Vertex: -1, Message: err.Error() + "; skipping it"})
+		}
+		for i, p := range f.Geometry.Coordinates {
+			check(f, -1, i, p)
+		}
+		for r, ring := range f.Geometry.Rings {
+			for i, p := range ring {
+				check(f, r, i, p)
+			}
+		}
+	}
+}
+
+// isDefaultsFeature reports whether the feature just gives CRC's default
+// line, text, or symbol style for the rest of the file.
+func isDefaultsFeature(f GeoJSONFeature) bool {
+	for _, p := range []string{"isLineDefaults", "isTextDefaults", "isSymbolDefaults"} {
+		if b, _ := f.Properties[p].(bool); b {
+			return true
+		}
+	}
+	return false
+}
+
+// skipReason returns why featureLines doesn't convert the feature to
+// STARS map lines, or "" if it does.
+func skipReason(f GeoJSONFeature) string {
+	g := f.Geometry
+	switch {
+	case errors.Is(g.err, errTooManyCoordinates):
+		return g.Type + " with too many coordinates"
+	case g.err != nil:
+		return g.Type + " with invalid coordinates"
+	case g.Type == "":
+		return "no geometry"
+	case g.Type != "LineString":
+		return g.Type
+	case len(g.Coordinates) == 0:
+		return "LineString without coordinates"
+	}
+	return ""
+}
+
+// featureCounts records how many of the features of a map's file were
+// converted and how many were skipped, by reason.
+type featureCounts struct {
+	Converted int            `json:"converted"`
+	Skipped   map[string]int `json:"skipped,omitempty"`
+}
+
+func (c featureCounts) skipped() int {
+	n := 0
+	for _, count := range c.Skipped {
+		n += count
+	}
+	return n
+}
+
+// reasons returns the reasons that features were skipped with their
+// counts, e.g. "2 Polygon".
+func (c featureCounts) reasons() string {
+	var r []string
+	for why, count := range c.Skipped {
+		r = append(r, fmt.Sprintf("%d %s", count, why))
+	}
+	slices.Sort(r)
+	return strings.Join(r, ", ")
+}
+
+// mapFeatureCounts holds the featureCounts of each map read from GeoJSON,
+// by name.
+var mapFeatureCounts = make(map[string]*featureCounts)
+
+// checkSkippedFeatures reports the features of the map's file that won't
+// be converted to lines for STARS, by reason, since otherwise maps may
+// silently end up missing some or all of what's in them. The counts are
+// also recorded for the summary at the end of the run and the JSON
+// manifest.
+func checkSkippedFeatures(fn string, m VideoMapSpec, features []GeoJSONFeature) {
+	c := &featureCounts{Skipped: make(map[string]int)}
+	for _, f := range features {
+		if isDefaultsFeature(f) {
+			continue
+		}
+		if why := skipReason(f); why != "" {
+			c.Skipped[why]++
+		} else {
+			c.Converted++
+		}
+	}
+	mapFeatureCounts[m.Name] = c
+
+	if n := c.skipped(); n > 0 {
+		report(finding{Severity: "warning", Rule: "skipped-features", File: fn, Map: m.Name, Feature: -1, Vertex: -1,
+			Message: fmt.Sprintf("%d of %d features not converted: %s", n, n+c.Converted, c.reasons())})
+	}
+}
+
+// printFeatureCounts prints how many features were converted and skipped
+// for each map that had any skipped, so that it's clear what most needs
+// fixing in the source data.
+func printFeatureCounts() {
+	var names []string
+	var converted, skipped int
+	for name, c := range mapFeatureCounts {
+		converted += c.Converted
+		skipped += c.skipped()
+		if c.skipped() > 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+
+	// Most skipped first.
+	sort.Slice(names, func(i, j int) bool {
+		ci, cj := mapFeatureCounts[names[i]], mapFeatureCounts[names[j]]
+		if ci.skipped() != cj.skipped() {
+			return ci.skipped() > cj.skipped()
+		}
+		return names[i] < names[j]
+	})
+
+	fmt.Printf("\nFeatures skipped:\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "  MAP\tCONVERTED\tSKIPPED\tREASONS\n")
+	for _, name := range names {
+		c := mapFeatureCounts[name]
+		fmt.Fprintf(w, "  %s\t%d\t%d\t%s\n", name, c.Converted, c.skipped(), c.reasons())
+	}
+	w.Flush()
+	fmt.Printf("%d features converted, %d skipped in %d of %d maps\n", converted, skipped, len(names), len(mapFeatureCounts))
+}
+
+// runFinished records whether finishRun has run, since it's called both
+// before exiting with an error and at the end of main.
+var runFinished bool
+
+// finishRun prints the summary of the features that were skipped and
+// writes the findings with -sarif.
+func finishRun() {
+	if runFinished {
+		return
+	}
+	runFinished = true
+	printFeatureCounts()
+	writeFindings()
+}
+
+// featureLineNumbers returns the line in the GeoJSON where each element
+// of its "features" array starts, so that findings can give it. It
+// returns nil if the file can't be parsed.
+func featureLineNumbers(b []byte) []int {
+	line := func(offset int64) int {
+		for offset < int64(len(b)) && strings.IndexByte(" \t\r\n,", b[offset]) >= 0 {
+			offset++
+		}
+		return 1 + bytes.Count(b[:offset], []byte("\n"))
+	}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	if t, err := d.Token(); err != nil || t != json.Delim('{') {
+		return nil
+	}
+	for d.More() {
+		key, err := d.Token()
+		if err != nil {
+			return nil
+		}
+		if key != "features" {
+			var skip json.RawMessage
+			if d.Decode(&skip) != nil {
+				return nil
+			}
+			continue
+		}
+
+		if t, err := d.Token(); err != nil || t != json.Delim('[') {
+			return nil
+		}
+		var lines []int
+		for d.More() {
+			lines = append(lines, line(d.InputOffset()))
+			var skip json.RawMessage
+			if d.Decode(&skip) != nil {
+				return nil
+			}
+		}
+		return lines
+	}
+	return nil
+}
+
+// prepareMaps returns the maps that should be written: clipped, with
+// their geometry cleaned up and processed according to the command-line
+// options, without empty maps if -skip-empty was given, and with their
+// labels fixed with -fix-labels, after checking them. With -strict, it
+// exits if there were any problems.
+func prepareMaps(maps []STARSMap) []STARSMap {
+	maps = clipMaps(maps)
+	maps = snapMaps(maps)
+	maps = dropDegenerate(maps)
+	maps = joinMapLines(maps)
+	maps = simplifyMaps(maps)
+	maps = densifyMaps(maps)
+	maps = quantizeMaps(maps)
+	maps = sortMapLines(maps)
+	maps = addLODs(maps)
+	// Skip empty maps only after all of the geometry passes, any of which
+	// may leave a map without lines.
+	if *skipEmpty {
+		maps = slices.DeleteFunc(slices.Clone(maps), func(m STARSMap) bool {
+			if isEmptyMap(m) {
+				fmt.Printf("\r%s: skipping map with no lines or labels\n", m.Name)
+				return true
+			}
+			return false
+		})
+	}
+	if *fixLabels {
+		maps = fixDCBLabels(maps)
+	}
+	checkMaps(maps)
+	checkStrict()
+	return maps
+}
+
+func isEmptyMap(m STARSMap) bool {
+	return len(m.Lines) == 0 && len(m.Labels) == 0
+}
+
+// starsLimits are the limits of STARS adaptations that the maps are
+// checked against. vice doesn't currently enforce them, but real systems
+// have hard limits and maps that exceed them can't have come from a real
+// facility.
+var starsLimits = struct {
+	MaxId              int // map numbers are 1-999 (ids of zero are unassigned)
+	MaxMapsPerCategory int // in each of the A and B brightness categories
+	MaxVectors         int // line segments in a single map
+}{
+	MaxId:              999,
+	MaxMapsPerCategory: 200,
+	MaxVectors:         32767,
+}
+
+// maxLabelLength is the number of characters that fit on a DCB map button.
+const maxLabelLength = 7
+
+// isLabelRune reports whether the STARS DCB font has the given character;
+// it only has upper-case letters, digits, and a little punctuation.
+func isLabelRune(r rune) bool {
+	return (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || strings.ContainsRune(" -/.#()&+", r)
+}
+
+// labelProblem returns a description of what's wrong with the given DCB
+// label, or "" if it's fine.
+func labelProblem(label string) string {
+	if strings.TrimSpace(label) == "" {
+		return "map has no DCB label"
+	}
+	if n := utf8.RuneCountInString(label); n > maxLabelLength {
+		return fmt.Sprintf("DCB label %q is %d characters; only %d fit on a DCB button", label, n, maxLabelLength)
+	}
+	if i := strings.IndexFunc(label, func(r rune) bool { return !isLabelRune(r) }); i >= 0 {
+		r, _ := utf8.DecodeRuneInString(label[i:])
+		return fmt.Sprintf("DCB label %q has %q, which STARS can't display", label, r)
+	}
+	return ""
+}
+
+// dcbLabel returns s made to fit on a DCB button: in upper case, without
+// characters that can't be displayed, and truncated.
+func dcbLabel(s string) string {
+	label := []rune(strings.Map(func(r rune) rune {
+		if r = unicode.ToUpper(r); isLabelRune(r) {
+			return r
+		}
+		return -1
+	}, s))
+	return strings.TrimSpace(string(label[:min(len(label), maxLabelLength)]))
+}
+
+// fixDCBLabels returns the maps with their labels made to fit on DCB
+// buttons: they're converted to upper case, characters that can't be
+// displayed are removed, and they're truncated. Maps left without a label
+// are given one from their name or id. If that makes a label the
+// same as another map's, the end of it is replaced with a number to keep
+// them distinct.
+func fixDCBLabels(maps []STARSMap) []STARSMap {
+	maps = slices.Clone(maps)
+
+	used := make(map[string]bool)
+	for _, m := range maps {
+		if labelProblem(m.Label) == "" {
+			used[m.Label] = true
+		}
+	}
+
+	for i, m := range maps {
+		if labelProblem(m.Label) == "" {
+			continue
+		}
+		// If the label has nothing that STARS can display, make one from
+		// the map's name or, failing that, its id.
+		label := []rune(dcbLabel(m.Label))
+		if len(label) == 0 {
+			label = []rune(dcbLabel(m.Name))
+		}
+		if len(label) == 0 {
+			label = []rune("MAP")
+			if m.Id != 0 {
+				label = []rune(dcbLabel("MAP" + strconv.Itoa(m.Id)))
+			}
+		}
+		fixed := string(label)
+		for n := 2; used[fixed]; n++ {
+			suffix := strconv.Itoa(n)
+			fixed = strings.TrimSpace(string(label[:min(len(label), maxLabelLength-len(suffix))])) + suffix
+		}
+		used[fixed] = true
+
+		fmt.Printf("\r%s: DCB label %q changed to %q\n", m.Name, m.Label, fixed)
+		maps[i].Label = fixed
+	}
+	return maps
+}
+
+// checkMaps reports problems with the maps: it's an error for maps to
+// have the same name, since vice finds maps by name and so only one of
+// them could be used, and maps with the same DCB label or STARS id are
+// likely to be confused. Maps without any lines or labels, usually
+// because none of their features could be converted, are reported as
+// well, since they're just dead DCB buttons, as are violations of the
+// starsLimits, labels that won't display correctly on the DCB, and maps
+// that are largely outside of the facility's boundary.
+func checkMaps(maps []STARSMap) {
+	names := make(map[string]int)
+	labels := make(map[string][]string)
+	ids := make(map[int][]string)
+	for _, m := range maps {
+		names[m.Name]++
+		if m.Label != "" {
+			labels[m.Label] = append(labels[m.Label], m.Name)
+		}
+		if m.Id != 0 {
+			ids[m.Id] = append(ids[m.Id], m.Name)
+		}
+	}
+
+	categories := make(map[int]int)
+	for _, m := range maps {
+		categories[m.Group]++
+	}
+	for _, group := range []int{0, 1} {
+		if n := categories[group]; n > starsLimits.MaxMapsPerCategory {
+			report(finding{Severity: "warning", Rule: "stars-limit", Map: "category " + groupCategory(group), Feature: -1, Vertex: -1,
+				Message: fmt.Sprintf("%d maps is more than the STARS limit of %d", n, starsLimits.MaxMapsPerCategory)})
+		}
+	}
+
+	// Report each problem once, at the first map that has it.
+	for _, m := range maps {
+		if isEmptyMap(m) {
+			report(finding{Severity: "warning", Rule: "empty-map", Map: m.Name, Feature: -1, Vertex: -1,
+				Message: "map has no lines or labels"})
+		}
+		if msg := labelProblem(m.Label); msg != "" {
+			report(finding{Severity: "warning", Rule: "dcb-label", Map: m.Name, Feature: -1, Vertex: -1, Message: msg})
+		}
+		checkBoundary(m)
+		if m.Id < 0 || m.Id > starsLimits.MaxId {
+			report(finding{Severity: "warning", Rule: "stars-limit", Map: m.Name, Feature: -1, Vertex: -1,
+				Message: fmt.Sprintf("STARS id %d is outside of the range 1-%d", m.Id, starsLimits.MaxId)})
+		}
+		vectors := 0
+		for _, l := range m.Lines {
+			vectors += max(0, len(l)-1)
+		}
+		if vectors > starsLimits.MaxVectors {
+			report(finding{Severity: "warning", Rule: "stars-limit", Map: m.Name, Feature: -1, Vertex: -1,
+				Message: fmt.Sprintf("%d vectors is more than the STARS limit of %d", vectors, starsLimits.MaxVectors)})
+		}
+		if n := names[m.Name]; n > 1 {
+			report(finding{Severity: "error", Rule: "duplicate-name", Map: m.Name, Feature: -1, Vertex: -1,
+				Message: fmt.Sprintf("%d maps have this name; vice will only use one of them", n)})
+			names[m.Name] = 0
+		}
+		if others := labels[m.Label]; len(others) > 1 {
+			report(finding{Severity: "warning", Rule: "duplicate-label", Map: m.Name, Feature: -1, Vertex: -1,
+				Message: fmt.Sprintf("DCB label %q is also used by %s", m.Label, quotedList(others[1:]))})
+			labels[m.Label] = nil
+		}
+		if others := ids[m.Id]; len(others) > 1 {
+			report(finding{Severity: "warning", Rule: "duplicate-id", Map: m.Name, Feature: -1, Vertex: -1,
+				Message: fmt.Sprintf("STARS id %d is also used by %s", m.Id, quotedList(others[1:]))})
+			ids[m.Id] = nil
+		}
+	}
+}
+
+func quotedList(s []string) string {
+	return strings.Join(MapSlice(s, strconv.Quote), ", ")
+}
+
+// checkStrict exits with an error if -strict was given and there were
+// any errors or duplicate names, labels, or ids in the maps. Other
+// warnings (e.g., about long DCB labels) are only reported.
+func checkStrict() {
+	if n := strictFindings(); *strict && n > 0 {
+		finishRun()
+		fmt.Fprintf(os.Stderr, "crctovice: %d problems found with -strict; not writing output\n", n)
+		os.Exit(1)
+	}
+}
+
+// strictFindings returns the number of findings that are fatal with
+// -strict.
+func strictFindings() int {
+	n := 0
+	for _, f := range findings {
+		if f.Severity == "error" || strings.HasPrefix(f.Rule, "duplicate-") {
+			n++
+		}
+	}
+	return n
+}
+
+// validateARTCC reads and checks the video maps of the given ARTCC (or
+// facility) without writing any output, exiting with an error if there
+// are any errors, or with -strict, any duplicates.
+func validateARTCC(base string) {
+	_, _, maps, _ := readARTCCMaps(base)
+	checkMaps(maps)
+
+	var errors, warnings int
+	for _, f := range findings {
+		if f.Severity == "error" {
+			errors++
+		} else {
+			warnings++
+		}
+	}
+	finishRun()
+	fmt.Printf("%d errors, %d warnings\n", errors, warnings)
+	if errors > 0 || (*strict && strictFindings() > 0) {
+		os.Exit(1)
+	}
+}
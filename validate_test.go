@@ -0,0 +1,77 @@
+// validate_test.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import "testing"
+
+func TestStrictFindings(t *testing.T) {
+	defer func(f []finding) { findings = f }(findings)
+
+	line := [][]Point2LL{{{-74, 40}, {-73, 40}}}
+	for _, test := range []struct {
+		name   string
+		maps   []STARSMap
+		strict int
+	}{
+		{"ok", []STARSMap{{Name: "a", Label: "A", Id: 1, Lines: line}}, 0},
+		// Only warnings: an empty map and an over-long DCB label.
+		{"warnings", []STARSMap{{Name: "a", Label: "A"}, {Name: "b", Label: "TOOLONGLABEL", Id: 2, Lines: line}}, 0},
+		{"duplicate label", []STARSMap{{Name: "a", Label: "A", Id: 1, Lines: line}, {Name: "b", Label: "A", Id: 2, Lines: line}}, 1},
+		{"duplicate id", []STARSMap{{Name: "a", Label: "A", Id: 1, Lines: line}, {Name: "b", Label: "B", Id: 1, Lines: line}}, 1},
+		{"duplicate name", []STARSMap{{Name: "a", Label: "A", Id: 1, Lines: line}, {Name: "a", Label: "B", Id: 2, Lines: line}}, 1},
+	} {
+		findings = nil
+		checkMaps(test.maps)
+		if n := strictFindings(); n != test.strict {
+			t.Errorf("%s: got %d strict findings, want %d (of %v)", test.name, n, test.strict, findings)
+		}
+		if test.name == "warnings" && len(findings) == 0 {
+			t.Errorf("%s: expected warnings", test.name)
+		}
+	}
+}
+
+func TestFixDCBLabels(t *testing.T) {
+	maps := fixDCBLabels([]STARSMap{
+		{Name: "a", Label: "OK"},
+		{Name: "b", Label: "lower"},
+		{Name: "c", Label: "TOOLONGLABEL"},
+		{Name: "d", Label: "TOOLONGLABEL"},
+		{Name: "Final", Label: "→→"},
+		{Name: "→", Label: "", Id: 12},
+		{Name: "→", Label: " "},
+	})
+	want := []string{"OK", "LOWER", "TOOLONG", "TOOLON2", "FINAL", "MAP12", "MAP"}
+	for i, m := range maps {
+		if m.Label != want[i] {
+			t.Errorf("%s: got label %q, want %q", m.Name, m.Label, want[i])
+		}
+		if msg := labelProblem(m.Label); msg != "" {
+			t.Errorf("%s: %s", m.Name, msg)
+		}
+	}
+
+	for _, label := range []string{"", " "} {
+		if labelProblem(label) == "" {
+			t.Errorf("%q: expected a problem", label)
+		}
+	}
+}
+
+// With -skip-empty, maps that the geometry passes (here, -precision)
+// leave empty should be skipped too.
+func TestPrepareMapsSkipEmpty(t *testing.T) {
+	defer func(f []finding, p tolerance, s bool) { findings, precision, *skipEmpty = f, p, s }(findings, precision, *skipEmpty)
+	findings, precision, *skipEmpty = nil, tolerance{Degrees: 0.01}, true
+
+	maps := prepareMaps([]STARSMap{
+		{Name: "a", Label: "A", Id: 1, Lines: [][]Point2LL{{{-74, 40}, {-73, 40}}}},
+		{Name: "tiny", Label: "TINY", Id: 2, Lines: [][]Point2LL{{{-74, 40}, {-74.0001, 40.0001}}}},
+		{Name: "empty", Label: "EMPTY", Id: 3},
+	})
+	if len(maps) != 1 || maps[0].Name != "a" {
+		t.Errorf("got %d maps, want only \"a\"", len(maps))
+	}
+}
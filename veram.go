@@ -0,0 +1,50 @@
+// veram.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// vERAM GeoMap sets
+
+type vERAMGeoMap struct {
+	Name       string              `xml:"Name,attr"`
+	LabelLine1 string              `xml:"LabelLine1,attr"`
+	LabelLine2 string              `xml:"LabelLine2,attr"`
+	Objects    []vERAMGeoMapObject `xml:"Objects>GeoMapObject"`
+}
+
+type vERAMGeoMapObject struct {
+	Description string          `xml:"Description,attr"`
+	TdmOnly     bool            `xml:"TdmOnly,attr"`
+	Elements    []vSTARSElement `xml:"Elements>Element"`
+}
+
+// STARSMaps returns a map for each of the GeoMap's objects.
+func (gm vERAMGeoMap) STARSMaps() []STARSMap {
+	var maps []STARSMap
+	for _, obj := range gm.Objects {
+		name := strings.TrimSpace(gm.Name + " " + obj.Description)
+		if obj.TdmOnly && !*includeTDM {
+			fmt.Printf("\r%s: skipping TDM-only map\n", name)
+			continue
+		}
+
+		label := obj.Description
+		if label == "" {
+			label = gm.LabelLine1
+		}
+		maps = append(maps, STARSMap{
+			Group: 1,
+			Label: label,
+			Name:  name,
+			Lines: vSTARSLines(obj.Elements),
+		})
+	}
+	return maps
+}
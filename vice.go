@@ -0,0 +1,76 @@
+// vice.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"path"
+	"sort"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// vice scenario configuration
+
+// viceSTARSConfig is the part of a vice scenario's "stars_config" that
+// refers to video maps.
+type viceSTARSConfig struct {
+	VideoMapFile      string                          `json:"video_map_file"`
+	STARSMaps         []string                        `json:"stars_maps"`
+	ControllerConfigs map[string]viceControllerConfig `json:"controller_configs,omitempty"`
+}
+
+type viceControllerConfig struct {
+	VideoMaps []string `json:"video_maps"`
+}
+
+// writeViceConfig writes a JSON fragment with the video map settings for
+// a vice scenario, ready to be pasted into its "stars_config": the video
+// map file (as installed in vice's resources/videomaps directory), the
+// names of all of the maps, and, for CRC map groups, the maps for each
+// of the group's TCPs in DCB button order, with "" for empty buttons.
+// Groups without TCPs are listed under their id.
+func writeViceConfig(maps []STARSMap, videoMapFile, fn string) {
+	cfg := viceSTARSConfig{
+		VideoMapFile:      path.Join("videomaps", videoMapFile),
+		STARSMaps:         []string{},
+		ControllerConfigs: make(map[string]viceControllerConfig),
+	}
+
+	type button struct {
+		position int
+		name     string
+	}
+	groups := make(map[string][]button)
+	groupTCPs := make(map[string][]string)
+	for _, m := range maps {
+		cfg.STARSMaps = append(cfg.STARSMaps, m.Name)
+		for _, g := range m.MapGroups {
+			groups[g.Id] = append(groups[g.Id], button{g.Position, m.Name})
+			groupTCPs[g.Id] = g.TCPs
+		}
+	}
+
+	for id, buttons := range groups {
+		sort.Slice(buttons, func(i, j int) bool { return buttons[i].position < buttons[j].position })
+		var cc viceControllerConfig
+		for _, b := range buttons {
+			for len(cc.VideoMaps) < b.position {
+				cc.VideoMaps = append(cc.VideoMaps, "")
+			}
+			cc.VideoMaps = append(cc.VideoMaps, b.name)
+		}
+
+		tcps := groupTCPs[id]
+		if len(tcps) == 0 {
+			tcps = []string{id}
+		}
+		for _, tcp := range tcps {
+			cfg.ControllerConfigs[tcp] = cc
+		}
+	}
+
+	writeJSON(struct {
+		STARSConfig viceSTARSConfig `json:"stars_config"`
+	}{cfg}, fn)
+}
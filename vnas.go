@@ -0,0 +1,62 @@
+// vnas.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// vnasFS is an fs.FS that fetches ARTCC definitions and video maps from
+// the vNAS data API as they are read, so that a local CRC installation
+// isn't necessary. It only supports fs.ReadFile.
+type vnasFS struct {
+	url    string
+	client *http.Client
+}
+
+func newVNASFS(url string) *vnasFS {
+	return &vnasFS{
+		url:    strings.TrimSuffix(url, "/"),
+		client: &http.Client{Timeout: 2 * time.Minute},
+	}
+}
+
+func (v *vnasFS) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: errors.ErrUnsupported}
+}
+
+// ReadFile maps the paths of files in a local CRC installation to the
+// corresponding API endpoints: ARTCCs/ZNY.json comes from
+// /api/artccs/ZNY and VideoMaps/ZNY/<id>.geojson comes from
+// /Files/VideoMaps/ZNY/<id>.geojson.
+func (v *vnasFS) ReadFile(name string) ([]byte, error) {
+	var url string
+	if dir, file := path.Split(name); dir == "ARTCCs/" && strings.HasSuffix(file, ".json") {
+		url = v.url + "/api/artccs/" + strings.TrimSuffix(file, ".json")
+	} else if strings.HasPrefix(name, "VideoMaps/") {
+		url = v.url + "/Files/" + name
+	} else {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+
+	resp, err := v.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &fs.PathError{Op: "read", Path: url, Err: fs.ErrNotExist}
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, &fs.PathError{Op: "read", Path: url, Err: fmt.Errorf("%s", resp.Status)}
+	}
+	return readInput(resp.Body)
+}
@@ -0,0 +1,174 @@
+// vstars.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// vSTARS facility files
+
+type vSTARSVideoMap struct {
+	ShortName    string          `xml:"ShortName,attr"`
+	LongName     string          `xml:"LongName,attr"`
+	STARSGroup   string          `xml:"STARSGroup,attr"`
+	STARSTDMOnly bool            `xml:"STARSTDMOnly,attr"`
+	STARSId      int             `xml:"STARSId,attr"`
+	Elements     []vSTARSElement `xml:"Elements>Element"`
+}
+
+// vSTARSElement is used for both vSTARS and vERAM map elements; only
+// lines are converted.
+type vSTARSElement struct {
+	Type     string  `xml:"http://www.w3.org/2001/XMLSchema-instance type,attr"`
+	StartLat float32 `xml:"StartLat,attr"`
+	StartLon float32 `xml:"StartLon,attr"`
+	EndLat   float32 `xml:"EndLat,attr"`
+	EndLon   float32 `xml:"EndLon,attr"`
+}
+
+// importXML converts the video maps in vSTARS facility files, vERAM
+// GeoMap sets, and AIXM files, any of which may be gzip-compressed.
+func importXML(fn string) ([]STARSMap, error) {
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(strings.ToLower(fn), ".gz") {
+		zr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		if b, err = readDecompressed(zr); err != nil {
+			return nil, err
+		}
+	}
+
+	if isAIXM(b) {
+		return parseAIXM(fn, b)
+	}
+	return parseMapXML(fn, b)
+}
+
+// parseMapXML converts all of the vSTARS VideoMap and vERAM GeoMap
+// elements in the XML, wherever they are found.
+func parseMapXML(fn string, b []byte) ([]STARSMap, error) {
+	var maps []STARSMap
+	d := newXMLDecoder(b)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if se.Name.Local == "GeoMap" {
+			var gm vERAMGeoMap
+			if err := d.DecodeElement(&gm, &se); err != nil {
+				return nil, err
+			}
+			for _, sm := range gm.STARSMaps() {
+				sm.Order = len(maps)
+				maps = append(maps, sm)
+			}
+			continue
+		} else if se.Name.Local != "VideoMap" {
+			continue
+		}
+
+		var vm vSTARSVideoMap
+		if err := d.DecodeElement(&vm, &se); err != nil {
+			return nil, err
+		}
+		if vm.STARSTDMOnly && !*includeTDM {
+			fmt.Printf("\r%s: skipping TDM-only map\n", vm.LongName)
+			continue
+		}
+
+		sm := STARSMap{
+			Group: categoryGroup(vm.STARSGroup),
+			Label: vm.ShortName,
+			Name:  vm.LongName,
+			Id:    vm.STARSId,
+			Order: len(maps),
+		}
+		sm.Lines = vSTARSLines(vm.Elements)
+		maps = append(maps, sm)
+	}
+
+	if len(maps) == 0 {
+		return nil, fmt.Errorf("no video maps found")
+	}
+	return maps, nil
+}
+
+func vSTARSLines(elements []vSTARSElement) [][]Point2LL {
+	var lines [][]Point2LL
+	for _, e := range elements {
+		if e.Type == "Line" {
+			lines = appendSegment(lines, Point2LL{e.StartLon, e.StartLat}, Point2LL{e.EndLon, e.EndLat})
+		}
+	}
+	return lines
+}
+
+///////////////////////////////////////////////////////////////////////////
+// vSTARS export
+
+// writeVSTARS writes the maps as a vSTARS VideoMaps XML file, which can be
+// imported into a vSTARS facility. vSTARS video maps are made of line
+// segments, so each line is written as a series of Line elements; map
+// labels can't be represented and are skipped.
+func writeVSTARS(maps []STARSMap, fn string) {
+	fmt.Printf("Writing %s... ", fn)
+
+	var b bytes.Buffer
+	esc := func(s string) string {
+		var e bytes.Buffer
+		xml.EscapeText(&e, []byte(s))
+		return e.String()
+	}
+	coord := func(v float32) string {
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	}
+
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>
+<VideoMaps xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+`)
+	for _, m := range maps {
+		if len(m.Labels) > 0 {
+			fmt.Printf("\r%s: warning: vSTARS video maps don't support text; skipping %d labels\n", m.Name, len(m.Labels))
+		}
+
+		group := groupCategory(m.Group)
+		fmt.Fprintf(&b, "  <VideoMap ShortName=\"%s\" LongName=\"%s\" STARSGroup=\"%s\" STARSTDMOnly=\"false\" VisibleInList=\"true\" STARSId=\"%d\">\n",
+			esc(m.Label), esc(m.Name), group, m.Id)
+		b.WriteString("    <Elements>\n")
+		for _, l := range m.Lines {
+			for i := 1; i < len(l); i++ {
+				fmt.Fprintf(&b, "      <Element xsi:type=\"Line\" Filters=\"\" StartLat=\"%s\" StartLon=\"%s\" EndLat=\"%s\" EndLon=\"%s\" Style=\"Solid\" Thickness=\"1\" />\n",
+					coord(l[i-1][1]), coord(l[i-1][0]), coord(l[i][1]), coord(l[i][0]))
+			}
+		}
+		b.WriteString("    </Elements>\n  </VideoMap>\n")
+	}
+	b.WriteString("</VideoMaps>\n")
+
+	errorExit("creating file", writeFileAtomic(fn, b.Bytes(), 0o644))
+}
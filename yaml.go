@@ -0,0 +1,545 @@
+// yaml.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// YAML
+//
+// ARTCC definitions may be written in YAML; they're converted to JSON so
+// that they're decoded exactly as CRC's JSON files are. This handles the
+// subset of YAML that's useful for such files: block mappings and
+// sequences, flow collections ([a, b], {k: v}), plain and quoted scalars,
+// literal and folded block scalars, and comments. Anchors, aliases, tags,
+// and multiple documents are not supported.
+
+// yamlToJSON converts a YAML document to JSON.
+func yamlToJSON(b []byte) ([]byte, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(strings.ReplaceAll(string(b), "\r\n", "\n"), "\n") {
+		content := strings.TrimRight(stripYAMLComment(raw), " \t")
+		trimmed := strings.TrimLeft(content, " ")
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs can't be used for indentation", i+1)
+		}
+		p.lines = append(p.lines, yamlLine{
+			num:     i + 1,
+			raw:     raw,
+			indent:  len(content) - len(trimmed),
+			content: trimmed,
+		})
+	}
+
+	// Skip blank lines and the document start marker.
+	p.skipBlank()
+	if p.pos < len(p.lines) && p.lines[p.pos].content == "---" {
+		p.pos++
+	}
+
+	v, err := p.block(0)
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	if p.pos < len(p.lines) && p.lines[p.pos].content != "..." {
+		return nil, fmt.Errorf("line %d: unexpected content", p.lines[p.pos].num)
+	}
+	return json.Marshal(v)
+}
+
+type yamlLine struct {
+	num     int
+	raw     string
+	indent  int
+	content string // without indentation or comments
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) && p.lines[p.pos].content == "" {
+		p.pos++
+	}
+}
+
+func (p *yamlParser) errorf(format string, args ...any) error {
+	num := len(p.lines)
+	if p.pos < len(p.lines) {
+		num = p.lines[p.pos].num
+	}
+	return fmt.Errorf("line %d: %s", num, fmt.Sprintf(format, args...))
+}
+
+// block parses the node starting at the current line, which must be
+// indented at least minIndent.
+func (p *yamlParser) block(minIndent int) (any, error) {
+	p.skipBlank()
+	if p.pos == len(p.lines) || p.lines[p.pos].indent < minIndent {
+		return nil, nil
+	}
+
+	l := p.lines[p.pos]
+	if l.content == "-" || strings.HasPrefix(l.content, "- ") {
+		return p.sequence(l.indent)
+	} else if _, _, ok := splitYAMLKey(l.content); ok {
+		return p.mapping(l.indent)
+	}
+	p.pos++
+	return p.value(l.content, l.indent)
+}
+
+func (p *yamlParser) sequence(indent int) (any, error) {
+	seq := []any{}
+	for {
+		p.skipBlank()
+		if p.pos == len(p.lines) || p.lines[p.pos].indent != indent {
+			break
+		}
+		l := p.lines[p.pos]
+		if l.content != "-" && !strings.HasPrefix(l.content, "- ") {
+			break
+		}
+
+		rest := strings.TrimLeft(strings.TrimPrefix(l.content, "-"), " ")
+		if rest == "" {
+			p.pos++
+			v, err := p.block(indent + 1)
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, v)
+			continue
+		}
+
+		// Parse the remainder of the line as if it started a new line at
+		// its column, so that "- key: value" begins a mapping.
+		p.lines[p.pos].indent += len(l.content) - len(rest)
+		p.lines[p.pos].content = rest
+		v, err := p.block(p.lines[p.pos].indent)
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, v)
+	}
+	return seq, nil
+}
+
+func (p *yamlParser) mapping(indent int) (any, error) {
+	m := make(map[string]any)
+	for {
+		p.skipBlank()
+		if p.pos == len(p.lines) || p.lines[p.pos].indent != indent {
+			break
+		}
+		l := p.lines[p.pos]
+		key, value, ok := splitYAMLKey(l.content)
+		if !ok {
+			return nil, p.errorf("expected \"key: value\"")
+		}
+		k, err := yamlKey(key)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		if _, ok := m[k]; ok {
+			return nil, p.errorf("%q: duplicate key", k)
+		}
+		p.pos++
+
+		if value == "" {
+			p.skipBlank()
+			if p.pos < len(p.lines) && p.lines[p.pos].indent == indent &&
+				(p.lines[p.pos].content == "-" || strings.HasPrefix(p.lines[p.pos].content, "- ")) {
+				// Sequences may be at the same indentation as their key.
+				m[k], err = p.sequence(indent)
+			} else {
+				m[k], err = p.block(indent + 1)
+			}
+		} else {
+			m[k], err = p.value(value, indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// value parses a value that starts on the line before the current one.
+func (p *yamlParser) value(s string, indent int) (any, error) {
+	switch {
+	case strings.HasPrefix(s, "|") || strings.HasPrefix(s, ">"):
+		return p.blockScalar(s, indent)
+
+	case strings.HasPrefix(s, "[") || strings.HasPrefix(s, "{"):
+		// Flow collections may continue over multiple lines.
+		for !yamlBalanced(s) && p.pos < len(p.lines) {
+			s += " " + p.lines[p.pos].content
+			p.pos++
+		}
+		f := &yamlFlow{s: s}
+		v, err := f.value()
+		if err == nil {
+			if f.skipSpace(); f.i != len(f.s) {
+				err = fmt.Errorf("unexpected %q after flow collection", f.s[f.i:])
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", p.lines[p.pos-1].num, err)
+		}
+		return v, nil
+
+	case strings.HasPrefix(s, "&") || strings.HasPrefix(s, "*") || strings.HasPrefix(s, "!"):
+		return nil, fmt.Errorf("line %d: anchors, aliases, and tags are not supported", p.lines[p.pos-1].num)
+
+	default:
+		v, err := yamlScalar(s)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", p.lines[p.pos-1].num, err)
+		}
+		return v, nil
+	}
+}
+
+// blockScalar parses a literal (|) or folded (>) scalar with the given
+// header; its lines are those following that are indented more than
+// indent.
+func (p *yamlParser) blockScalar(header string, indent int) (any, error) {
+	folded := header[0] == '>'
+	chomp := strings.TrimLeft(header[1:], "0123456789")
+
+	var lines []string
+	contentIndent := -1
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if strings.TrimSpace(l.raw) == "" {
+			lines = append(lines, "")
+			p.pos++
+			continue
+		}
+		if l.indent <= indent {
+			break
+		}
+		if contentIndent == -1 {
+			contentIndent = len(l.raw) - len(strings.TrimLeft(l.raw, " "))
+		}
+		lines = append(lines, strings.TrimPrefix(l.raw, strings.Repeat(" ", contentIndent)))
+		p.pos++
+	}
+
+	// Trailing blank lines belong to the chomping.
+	n := len(lines)
+	for n > 0 && lines[n-1] == "" {
+		n--
+	}
+	s := strings.Join(lines[:n], "\n")
+	if folded {
+		s = foldYAMLLines(lines[:n])
+	}
+	switch chomp {
+	case "-":
+	case "+":
+		s += strings.Repeat("\n", len(lines)-n+1)
+	default:
+		if n > 0 {
+			s += "\n"
+		}
+	}
+	return s, nil
+}
+
+// foldYAMLLines joins the lines of a folded scalar: line breaks between
+// lines become spaces, except that blank lines become line breaks and the
+// breaks around more-indented lines are kept.
+func foldYAMLLines(lines []string) string {
+	var b strings.Builder
+	for i, l := range lines {
+		if i > 0 {
+			prev := lines[i-1]
+			switch {
+			case l == "":
+				b.WriteByte('\n')
+			case prev == "":
+			case strings.HasPrefix(l, " ") || strings.HasPrefix(prev, " "):
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(l)
+	}
+	return b.String()
+}
+
+// stripYAMLComment removes a trailing comment from the line; "#" starts a
+// comment if it's at the start of the line or follows whitespace and
+// isn't in a quoted string.
+func stripYAMLComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || strings.ContainsRune(" \t[{,:-", rune(s[i-1])) {
+				quote = c
+			}
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// splitYAMLKey splits "key: value" at the first colon that's followed by
+// whitespace or ends the line and isn't within quotes or brackets.
+func splitYAMLKey(s string) (key, value string, ok bool) {
+	if strings.HasPrefix(s, "[") || strings.HasPrefix(s, "{") {
+		return "", "", false
+	}
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && i == 0:
+			quote = c
+		case c == ':' && (i+1 == len(s) || s[i+1] == ' '):
+			return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+func yamlKey(s string) (string, error) {
+	v, err := yamlScalar(s)
+	if err != nil {
+		return "", err
+	}
+	if str, ok := v.(string); ok {
+		return str, nil
+	}
+	return s, nil
+}
+
+func yamlBalanced(s string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+var (
+	yamlIntRe   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlFloatRe = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+)
+
+// yamlScalar converts a scalar to the corresponding JSON value, following
+// the YAML core schema for plain scalars.
+func yamlScalar(s string) (any, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		if len(s) < 2 || !strings.HasSuffix(s, `"`) {
+			return nil, fmt.Errorf("%s: unterminated string", s)
+		}
+		var str string
+		if err := json.Unmarshal([]byte(s), &str); err != nil {
+			return nil, fmt.Errorf("%s: invalid string", s)
+		}
+		return str, nil
+
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return nil, fmt.Errorf("%s: unterminated string", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+
+	case s == "~" || s == "null" || s == "Null" || s == "NULL":
+		return nil, nil
+	case s == "true" || s == "True" || s == "TRUE":
+		return true, nil
+	case s == "false" || s == "False" || s == "FALSE":
+		return false, nil
+	default:
+		if n, ok := yamlNumber(s); ok {
+			return n, nil
+		}
+		return s, nil
+	}
+}
+
+// yamlNumber returns the JSON number for a plain scalar that YAML treats
+// as an integer or float. YAML allows some that JSON doesn't, like 0123,
+// .5, and 1., so those are parsed and written in JSON's form. Numbers
+// that are out of range are returned as strings.
+func yamlNumber(s string) (json.Number, bool) {
+	if !yamlIntRe.MatchString(s) && !yamlFloatRe.MatchString(s) {
+		return "", false
+	}
+	s = strings.TrimPrefix(s, "+")
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return "", false
+	}
+	if json.Valid([]byte(s)) {
+		return json.Number(s), true
+	}
+	if yamlIntRe.MatchString(s) {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return json.Number(strconv.FormatInt(i, 10)), true
+		}
+	}
+	return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), true
+}
+
+// yamlFlow parses flow collections.
+type yamlFlow struct {
+	s string
+	i int
+}
+
+func (f *yamlFlow) skipSpace() {
+	for f.i < len(f.s) && (f.s[f.i] == ' ' || f.s[f.i] == '\t') {
+		f.i++
+	}
+}
+
+func (f *yamlFlow) value() (any, error) {
+	f.skipSpace()
+	if f.i == len(f.s) {
+		return nil, fmt.Errorf("unexpected end of flow collection")
+	}
+	switch f.s[f.i] {
+	case '[':
+		f.i++
+		seq := []any{}
+		for {
+			f.skipSpace()
+			if f.i < len(f.s) && f.s[f.i] == ']' {
+				f.i++
+				return seq, nil
+			}
+			v, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, v)
+			if err := f.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+
+	case '{':
+		f.i++
+		m := make(map[string]any)
+		for {
+			f.skipSpace()
+			if f.i < len(f.s) && f.s[f.i] == '}' {
+				f.i++
+				return m, nil
+			}
+			k, err := f.scalar(true)
+			if err != nil {
+				return nil, err
+			}
+			f.skipSpace()
+			if f.i == len(f.s) || f.s[f.i] != ':' {
+				return nil, fmt.Errorf("expected \":\" after %q", k)
+			}
+			f.i++
+			key, err := yamlKey(k)
+			if err != nil {
+				return nil, err
+			}
+			if m[key], err = f.value(); err != nil {
+				return nil, err
+			}
+			if err := f.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+
+	default:
+		s, err := f.scalar(false)
+		if err != nil {
+			return nil, err
+		}
+		return yamlScalar(s)
+	}
+}
+
+// separator consumes the "," between items or leaves the closing bracket.
+func (f *yamlFlow) separator(close byte) error {
+	f.skipSpace()
+	if f.i < len(f.s) && f.s[f.i] == ',' {
+		f.i++
+		return nil
+	} else if f.i < len(f.s) && f.s[f.i] == close {
+		return nil
+	}
+	return fmt.Errorf("expected \",\" or %q", close)
+}
+
+// scalar returns the text of the next scalar in a flow collection.
+func (f *yamlFlow) scalar(key bool) (string, error) {
+	f.skipSpace()
+	start := f.i
+	if f.i < len(f.s) && (f.s[f.i] == '"' || f.s[f.i] == '\'') {
+		quote := f.s[f.i]
+		for f.i++; f.i < len(f.s); f.i++ {
+			if f.s[f.i] == '\\' && quote == '"' {
+				f.i++
+			} else if f.s[f.i] == quote {
+				if quote == '\'' && f.i+1 < len(f.s) && f.s[f.i+1] == '\'' {
+					f.i++
+					continue
+				}
+				f.i++
+				return f.s[start:f.i], nil
+			}
+		}
+		return "", fmt.Errorf("%s: unterminated string", f.s[start:])
+	}
+
+	for f.i < len(f.s) && !strings.ContainsRune(",]}", rune(f.s[f.i])) {
+		if key && f.s[f.i] == ':' {
+			break
+		}
+		f.i++
+	}
+	return strings.TrimSpace(f.s[start:f.i]), nil
+}
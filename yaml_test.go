@@ -0,0 +1,110 @@
+// yaml_test.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestYAMLFixtures converts the YAML files in testdata/yaml and compares
+// the result to the corresponding JSON files, which were generated from
+// them with PyYAML's safe_load.
+func TestYAMLFixtures(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "yaml", "*.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no YAML fixtures found")
+	}
+
+	for _, fn := range files {
+		t.Run(filepath.Base(fn), func(t *testing.T) {
+			y, err := os.ReadFile(fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			j, err := os.ReadFile(strings.TrimSuffix(fn, ".yaml") + ".json")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := yamlToJSON(y)
+			if err != nil {
+				t.Fatalf("yamlToJSON: %v", err)
+			}
+			var g, w any
+			if err := json.Unmarshal(got, &g); err != nil {
+				t.Fatalf("%s: %v", got, err)
+			}
+			if err := json.Unmarshal(j, &w); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(g, w) {
+				t.Errorf("got %s\nwant %s", got, j)
+			}
+		})
+	}
+}
+
+func TestYAMLScalars(t *testing.T) {
+	for _, test := range []struct {
+		yaml, json string
+	}{
+		{"a: 12", `{"a":12}`},
+		{"a: -7", `{"a":-7}`},
+		{"a: +3", `{"a":3}`},
+		{"a: 1.25", `{"a":1.25}`},
+		{"a: -1.5e3", `{"a":-1.5e3}`},
+		// Numbers that YAML allows but JSON doesn't.
+		{"a: 0123", `{"a":123}`},
+		{"a: -007", `{"a":-7}`},
+		{"a: .5", `{"a":0.5}`},
+		{"a: -.5", `{"a":-0.5}`},
+		{"a: 1.", `{"a":1}`},
+		{"a: 1.e3", `{"a":1000}`},
+		{"a: 00.25", `{"a":0.25}`},
+		{"a: 099999999999999999999", `{"a":1e+20}`},
+		// Out of range, so left as a string.
+		{"a: 1e999", `{"a":"1e999"}`},
+		// Not numbers.
+		{"a: 1.2.3", `{"a":"1.2.3"}`},
+		{"a: 0x1F", `{"a":"0x1F"}`},
+		{"a: .", `{"a":"."}`},
+		{"a: '12'", `{"a":"12"}`},
+		{"a: [0123, .5, 1.]", `{"a":[123,0.5,1]}`},
+		{"a: {b: 1.e3}", `{"a":{"b":1000}}`},
+		{"a: ~", `{"a":null}`},
+		{"a: True", `{"a":true}`},
+		{"a: \"x\\ty\"", `{"a":"x\ty"}`},
+		{"a: 'it''s'", `{"a":"it's"}`},
+	} {
+		got, err := yamlToJSON([]byte(test.yaml))
+		if err != nil {
+			t.Errorf("%q: %v", test.yaml, err)
+		} else if string(got) != test.json {
+			t.Errorf("%q: got %s, want %s", test.yaml, got, test.json)
+		}
+	}
+}
+
+func TestYAMLErrors(t *testing.T) {
+	for _, y := range []string{
+		"a: 1\n  b: 2",
+		"a: &x 1",
+		"- a\n- [b",
+		"a: \"unterminated",
+		"a:\n\t- b",
+	} {
+		if _, err := yamlToJSON([]byte(y)); err == nil {
+			t.Errorf("%q: expected an error", y)
+		}
+	}
+}
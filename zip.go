@@ -0,0 +1,87 @@
+// zip.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// importZip converts the map data in a .zip archive, such as the sector
+// file packages distributed by GNG/AeroNav or zipped shapefiles. Files in
+// the archive are handled according to their extensions; ones that aren't
+// map data are ignored.
+func importZip(fn string) ([]STARSMap, error) {
+	zr, err := zip.OpenReader(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	// Read everything up front since .ese files may refer to points
+	// defined in the corresponding .sct file.
+	contents := make(map[string][]byte)
+	var names []string
+	for _, f := range zr.File {
+		switch strings.ToLower(path.Ext(f.Name)) {
+		case ".sct", ".sct2", ".ese", ".shp", ".dbf":
+			r, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			b, err := readDecompressed(r)
+			r.Close()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f.Name, err)
+			}
+			contents[f.Name] = b
+			names = append(names, f.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no sector files or shapefiles found in archive")
+	}
+	sort.Strings(names)
+
+	sectors := make(map[string]*sectorFile) // indexed by the name without the extension
+	var maps []STARSMap
+	for _, name := range names {
+		if ext := strings.ToLower(path.Ext(name)); ext == ".sct" || ext == ".sct2" {
+			sf := parseSectorFile(fn+":"+name, contents[name], true)
+			sectors[strings.TrimSuffix(name, path.Ext(name))] = sf
+			maps = append(maps, sf.maps...)
+		}
+	}
+	for _, name := range names {
+		if strings.ToLower(path.Ext(name)) == ".shp" {
+			var dbf []byte
+			if *shpNameField != "" {
+				var ok bool
+				if dbf, ok = contents[strings.TrimSuffix(name, path.Ext(name))+".dbf"]; !ok {
+					return nil, fmt.Errorf("%s: no .dbf file found for shapefile", name)
+				}
+			}
+			sm, err := parseShapefile(name, contents[name], dbf)
+			if err != nil {
+				return nil, err
+			}
+			maps = append(maps, sm...)
+		}
+	}
+	for _, name := range names {
+		if strings.ToLower(path.Ext(name)) == ".ese" {
+			sf, ok := sectors[strings.TrimSuffix(name, path.Ext(name))]
+			if !ok {
+				sf = &sectorFile{points: make(map[string]Point2LL)}
+			}
+			maps = append(maps, parseESE(name, contents[name], sf)...)
+		}
+	}
+
+	return maps, nil
+}
@@ -0,0 +1,945 @@
+// zstd.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+)
+
+///////////////////////////////////////////////////////////////////////////
+// zstd compression
+//
+// A small Zstandard (RFC 8878) encoder. It finds matches with a hash
+// table, stores literals uncompressed, and encodes sequences using the
+// predefined FSE tables. This doesn't compress as well as the reference
+// implementation, but GOB files have plenty of repetition for it to find
+// and the output can be read by any zstd decoder.
+
+const (
+	zstdMagic        = 0xfd2fb528
+	zstdWindowLog    = 22
+	zstdMaxBlockSize = 128 << 10
+	zstdMinMatch     = 4
+	zstdHashLog      = 17
+)
+
+// zstdCompress returns the given data compressed as a single zstd frame.
+func zstdCompress(src []byte) []byte {
+	dst := binary.LittleEndian.AppendUint32(nil, zstdMagic)
+	// Frame header: 8-byte content size, not single segment, no checksum
+	// or dictionary, followed by the window descriptor.
+	dst = append(dst, 0xc0, (zstdWindowLog-10)<<3)
+	dst = binary.LittleEndian.AppendUint64(dst, uint64(len(src)))
+
+	e := &zstdEncoder{src: src}
+	if len(src) == 0 {
+		return zstdAppendBlockHeader(dst, true, 0, 0)
+	}
+	for start := 0; start < len(src); start += zstdMaxBlockSize {
+		end := min(start+zstdMaxBlockSize, len(src))
+		dst = e.appendBlock(dst, start, end, end == len(src))
+	}
+	return dst
+}
+
+type zstdSequence struct {
+	litLen, matchLen, offset int
+}
+
+type zstdEncoder struct {
+	src   []byte
+	table [1 << zstdHashLog]int32 // positions+1 of recent 4-byte sequences
+}
+
+func zstdAppendBlockHeader(dst []byte, last bool, blockType, size int) []byte {
+	h := size<<3 | blockType<<1
+	if last {
+		h |= 1
+	}
+	return append(dst, byte(h), byte(h>>8), byte(h>>16))
+}
+
+func zstdHash(v uint32) uint32 {
+	return (v * 2654435761) >> (32 - zstdHashLog)
+}
+
+// appendBlock compresses src[start:end], which may refer back to earlier
+// data within the window.
+func (e *zstdEncoder) appendBlock(dst []byte, start, end int, last bool) []byte {
+	src := e.src
+	var seqs []zstdSequence
+	var literals []byte
+
+	anchor := start
+	for i := start; i+zstdMinMatch <= end; {
+		v := binary.LittleEndian.Uint32(src[i:])
+		h := zstdHash(v)
+		cand := int(e.table[h]) - 1
+		e.table[h] = int32(i + 1)
+
+		if cand < 0 || i-cand > 1<<zstdWindowLog || binary.LittleEndian.Uint32(src[cand:]) != v {
+			i++
+			continue
+		}
+
+		// Extend the match forward (within the block) and backward.
+		n := zstdMinMatch
+		for i+n < end && src[cand+n] == src[i+n] {
+			n++
+		}
+		for i > anchor && cand > 0 && src[i-1] == src[cand-1] {
+			i, cand, n = i-1, cand-1, n+1
+		}
+
+		literals = append(literals, src[anchor:i]...)
+		seqs = append(seqs, zstdSequence{litLen: i - anchor, matchLen: n, offset: i - cand})
+		i += n
+		anchor = i
+		// Index a position inside the match to help find the next one.
+		if i-2 > cand && i+2 <= end {
+			e.table[zstdHash(binary.LittleEndian.Uint32(src[i-2:]))] = int32(i - 2 + 1)
+		}
+	}
+	literals = append(literals, src[anchor:end]...)
+
+	block := zstdAppendLiterals(nil, literals)
+	block = zstdAppendSequences(block, seqs)
+	if len(block) >= end-start {
+		dst = zstdAppendBlockHeader(dst, last, 0, end-start)
+		return append(dst, src[start:end]...)
+	}
+	dst = zstdAppendBlockHeader(dst, last, 2, len(block))
+	return append(dst, block...)
+}
+
+// zstdAppendLiterals appends a raw literals section.
+func zstdAppendLiterals(dst []byte, lit []byte) []byte {
+	n := len(lit)
+	switch {
+	case n < 32:
+		dst = append(dst, byte(n<<3))
+	case n < 4096:
+		dst = append(dst, byte(n<<4|1<<2), byte(n>>4))
+	default:
+		dst = append(dst, byte(n<<4|3<<2), byte(n>>4), byte(n>>12))
+	}
+	return append(dst, lit...)
+}
+
+// Literal length, match length, and offset codes: baseline values and
+// numbers of extra bits.
+var (
+	zstdLLBase = []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+		16, 18, 20, 22, 24, 28, 32, 40, 48, 64, 128, 256, 512, 1024, 2048, 4096,
+		8192, 16384, 32768, 65536}
+	zstdLLBits = []int{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 6, 7, 8, 9, 10, 11, 12,
+		13, 14, 15, 16}
+	zstdMLBase = []int{3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18,
+		19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34,
+		35, 37, 39, 41, 43, 47, 51, 59, 67, 83, 99, 131, 259, 515, 1027, 2051,
+		4099, 8195, 16387, 32771, 65539}
+	zstdMLBits = []int{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 4, 5, 7, 8, 9, 10, 11,
+		12, 13, 14, 15, 16}
+)
+
+// The predefined distributions.
+var (
+	zstdLLTable = newFSETable(6, []int{4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1})
+	zstdMLTable = newFSETable(6, []int{1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+		-1, -1, -1, -1, -1})
+	zstdOFTable = newFSETable(5, []int{1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1})
+)
+
+// zstdCode returns the code for v given the code baselines.
+func zstdCode(v int, base []int) int {
+	c := len(base) - 1
+	for base[c] > v {
+		c--
+	}
+	return c
+}
+
+// zstdAppendSequences appends a sequences section using the predefined
+// FSE tables.
+func zstdAppendSequences(dst []byte, seqs []zstdSequence) []byte {
+	n := len(seqs)
+	switch {
+	case n < 128:
+		dst = append(dst, byte(n))
+	case n < 0x7f00:
+		dst = append(dst, byte(n>>8+128), byte(n))
+	default:
+		dst = append(dst, 255, byte(n-0x7f00), byte((n-0x7f00)>>8))
+	}
+	if n == 0 {
+		return dst
+	}
+	dst = append(dst, 0) // predefined mode for all three
+
+	type codes struct{ ll, ml, of int }
+	c := make([]codes, n)
+	for i, s := range seqs {
+		c[i] = codes{
+			ll: zstdCode(s.litLen, zstdLLBase),
+			ml: zstdCode(s.matchLen, zstdMLBase),
+			of: bits.Len(uint(s.offset+3)) - 1,
+		}
+	}
+
+	// The decoder reads the bitstream backward, so sequences are encoded
+	// from last to first.
+	var w zstdBitWriter
+	addExtra := func(i int) {
+		s := seqs[i]
+		w.add(uint64(s.litLen-zstdLLBase[c[i].ll]), zstdLLBits[c[i].ll])
+		w.add(uint64(s.matchLen-zstdMLBase[c[i].ml]), zstdMLBits[c[i].ml])
+		w.add(uint64(s.offset+3-1<<c[i].of), c[i].of)
+	}
+
+	llState := zstdLLTable.initState(c[n-1].ll)
+	mlState := zstdMLTable.initState(c[n-1].ml)
+	ofState := zstdOFTable.initState(c[n-1].of)
+	addExtra(n - 1)
+	for i := n - 2; i >= 0; i-- {
+		ofState = zstdOFTable.encode(&w, ofState, c[i].of)
+		mlState = zstdMLTable.encode(&w, mlState, c[i].ml)
+		llState = zstdLLTable.encode(&w, llState, c[i].ll)
+		addExtra(i)
+	}
+	w.add(uint64(mlState), zstdMLTable.log)
+	w.add(uint64(ofState), zstdOFTable.log)
+	w.add(uint64(llState), zstdLLTable.log)
+	return append(dst, w.close()...)
+}
+
+// fseTable holds an FSE decoding table along with what's needed to
+// encode with it.
+type fseTable struct {
+	log      int
+	symbol   []int
+	nbBits   []int
+	baseline []int
+	// states[s] lists the states that decode to symbol s.
+	states [][]int
+}
+
+func newFSETable(log int, dist []int) *fseTable {
+	size := 1 << log
+	t := &fseTable{
+		log:      log,
+		symbol:   make([]int, size),
+		nbBits:   make([]int, size),
+		baseline: make([]int, size),
+		states:   make([][]int, len(dist)),
+	}
+
+	// Spread the symbols over the table as the decoder does.
+	high := size - 1
+	for s, p := range dist {
+		if p == -1 {
+			t.symbol[high] = s
+			high--
+		}
+	}
+	pos, step := 0, size>>1+size>>3+3
+	for s, p := range dist {
+		for i := 0; i < p; i++ {
+			t.symbol[pos] = s
+			for pos = (pos + step) & (size - 1); pos > high; pos = (pos + step) & (size - 1) {
+			}
+		}
+	}
+
+	next := make([]int, len(dist))
+	for s, p := range dist {
+		next[s] = max(p, 1)
+	}
+	for state := 0; state < size; state++ {
+		s := t.symbol[state]
+		n := next[s]
+		next[s]++
+		t.nbBits[state] = log - (bits.Len(uint(n)) - 1)
+		t.baseline[state] = n<<t.nbBits[state] - size
+		t.states[s] = append(t.states[s], state)
+	}
+	return t
+}
+
+// initState returns a state that decodes to symbol s.
+func (t *fseTable) initState(s int) int {
+	return t.states[s][0]
+}
+
+// encode writes the bits that take the decoder from a state for symbol s
+// to the given state and returns that state.
+func (t *fseTable) encode(w *zstdBitWriter, state, s int) int {
+	for _, prev := range t.states[s] {
+		if state >= t.baseline[prev] && state < t.baseline[prev]+1<<t.nbBits[prev] {
+			w.add(uint64(state-t.baseline[prev]), t.nbBits[prev])
+			return prev
+		}
+	}
+	panic("zstd: no FSE transition")
+}
+
+// zstdBitWriter accumulates bits starting from the least significant bit
+// of each byte.
+type zstdBitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits int
+}
+
+func (w *zstdBitWriter) add(v uint64, n int) {
+	w.acc |= (v & (1<<n - 1)) << w.nbits
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.nbits -= 8
+	}
+}
+
+// close terminates the stream with a 1 bit, as the decoder expects, and
+// returns it.
+func (w *zstdBitWriter) close() []byte {
+	w.add(1, 1)
+	if w.nbits > 0 {
+		w.buf = append(w.buf, byte(w.acc))
+	}
+	return w.buf
+}
+
+///////////////////////////////////////////////////////////////////////////
+// zstd decompression
+//
+// A straightforward decoder for the full format, so that compressed video
+// maps from vice or elsewhere can be read back in.
+
+// zstdDecompress returns the decompressed contents of all of the frames in
+// src.
+func zstdDecompress(src []byte) ([]byte, error) {
+	var out []byte
+	for len(src) > 0 {
+		if len(src) < 4 {
+			return nil, errors.New("zstd: truncated frame")
+		}
+		magic := binary.LittleEndian.Uint32(src)
+		if magic&0xfffffff0 == 0x184d2a50 {
+			// Skippable frame
+			if len(src) < 8 {
+				return nil, errors.New("zstd: truncated skippable frame")
+			}
+			n := int(binary.LittleEndian.Uint32(src[4:]))
+			if n > len(src)-8 {
+				return nil, errors.New("zstd: truncated skippable frame")
+			}
+			src = src[8+n:]
+			continue
+		}
+		if magic != zstdMagic {
+			return nil, errors.New("zstd: invalid magic number")
+		}
+
+		var err error
+		out, src, err = zstdDecodeFrame(out, src[4:])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+var errZstdCorrupt = errors.New("zstd: corrupt input")
+
+// zstdDecodeFrame decodes the frame at the start of src, appending its
+// contents to out; it returns out and the remainder of src.
+func zstdDecodeFrame(out, src []byte) ([]byte, []byte, error) {
+	if len(src) < 1 {
+		return nil, nil, errZstdCorrupt
+	}
+	fhd := src[0]
+	singleSegment := fhd&0x20 != 0
+	checksum := fhd&0x04 != 0
+	hdrSize := 1 + []int{0, 1, 2, 4}[fhd&3]
+	if !singleSegment {
+		hdrSize++ // window descriptor
+	}
+	fcsSize := []int{0, 2, 4, 8}[fhd>>6]
+	if fcsSize == 0 && singleSegment {
+		fcsSize = 1
+	}
+	hdrSize += fcsSize
+	if fhd&0x08 != 0 || len(src) < hdrSize {
+		return nil, nil, errZstdCorrupt
+	}
+	src = src[hdrSize:]
+
+	frameStart := len(out)
+	d := &zstdDecoder{rep: [3]int{1, 4, 8}}
+	for {
+		if len(src) < 3 {
+			return nil, nil, errZstdCorrupt
+		}
+		h := int(src[0]) | int(src[1])<<8 | int(src[2])<<16
+		last, blockType, size := h&1 != 0, (h>>1)&3, h>>3
+		src = src[3:]
+
+		switch blockType {
+		case 0: // raw
+			if size > len(src) {
+				return nil, nil, errZstdCorrupt
+			}
+			out = append(out, src[:size]...)
+			src = src[size:]
+		case 1: // RLE
+			if len(src) < 1 {
+				return nil, nil, errZstdCorrupt
+			}
+			for i := 0; i < size; i++ {
+				out = append(out, src[0])
+			}
+			src = src[1:]
+		case 2: // compressed
+			if size > len(src) {
+				return nil, nil, errZstdCorrupt
+			}
+			var err error
+			if out, err = d.decodeBlock(out, frameStart, src[:size]); err != nil {
+				return nil, nil, err
+			}
+			src = src[size:]
+		default:
+			return nil, nil, errZstdCorrupt
+		}
+
+		// Blocks are at most 128 KiB, so this bounds how much memory a
+		// small file can make us allocate.
+		if inputLimits.Size > 0 && int64(len(out)) > inputLimits.Size {
+			return nil, nil, errInputTooLarge("decompressed data")
+		}
+		if last {
+			break
+		}
+	}
+
+	if checksum {
+		if len(src) < 4 {
+			return nil, nil, errZstdCorrupt
+		}
+		src = src[4:]
+	}
+	return out, src, nil
+}
+
+// zstdDecoder holds the state that carries over between the blocks of a
+// frame.
+type zstdDecoder struct {
+	rep                       [3]int
+	huffman                   *zstdHuffmanTable
+	llTable, ofTable, mlTable *fseTable
+}
+
+func (d *zstdDecoder) decodeBlock(out []byte, frameStart int, block []byte) ([]byte, error) {
+	literals, block, err := d.decodeLiterals(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sequences section header
+	if len(block) < 1 {
+		return nil, errZstdCorrupt
+	}
+	var nseq int
+	switch b0 := int(block[0]); {
+	case b0 < 128:
+		nseq, block = b0, block[1:]
+	case b0 < 255:
+		if len(block) < 2 {
+			return nil, errZstdCorrupt
+		}
+		nseq, block = (b0-128)<<8+int(block[1]), block[2:]
+	default:
+		if len(block) < 3 {
+			return nil, errZstdCorrupt
+		}
+		nseq, block = int(block[1])+int(block[2])<<8+0x7f00, block[3:]
+	}
+	if nseq == 0 {
+		return append(out, literals...), nil
+	}
+
+	if len(block) < 1 {
+		return nil, errZstdCorrupt
+	}
+	modes := block[0]
+	block = block[1:]
+	for _, t := range []struct {
+		table      **fseTable
+		mode       byte
+		predefined *fseTable
+		maxLog     int
+		maxSymbol  int
+	}{
+		{&d.llTable, modes >> 6, zstdLLTable, 9, 35},
+		{&d.ofTable, (modes >> 4) & 3, zstdOFTable, 8, 31},
+		{&d.mlTable, (modes >> 2) & 3, zstdMLTable, 9, 52},
+	} {
+		switch t.mode {
+		case 0: // predefined
+			*t.table = t.predefined
+		case 1: // RLE
+			if len(block) < 1 || int(block[0]) > t.maxSymbol {
+				return nil, errZstdCorrupt
+			}
+			*t.table = &fseTable{symbol: []int{int(block[0])}, nbBits: []int{0}, baseline: []int{0}}
+			block = block[1:]
+		case 2: // FSE-compressed
+			var n int
+			*t.table, n, err = zstdReadFSETable(block, t.maxLog, t.maxSymbol)
+			if err != nil {
+				return nil, err
+			}
+			block = block[n:]
+		case 3: // repeat
+			if *t.table == nil {
+				return nil, errZstdCorrupt
+			}
+		}
+	}
+
+	br, err := newZstdBitReader(block)
+	if err != nil {
+		return nil, err
+	}
+	llState := int(br.read(d.llTable.log))
+	ofState := int(br.read(d.ofTable.log))
+	mlState := int(br.read(d.mlTable.log))
+
+	for i := 0; i < nseq; i++ {
+		ofCode := d.ofTable.symbol[ofState]
+		mlCode := d.mlTable.symbol[mlState]
+		llCode := d.llTable.symbol[llState]
+		if ofCode > 31 || mlCode >= len(zstdMLBase) || llCode >= len(zstdLLBase) {
+			return nil, errZstdCorrupt
+		}
+
+		ov := 1<<ofCode + int(br.read(ofCode))
+		ml := zstdMLBase[mlCode] + int(br.read(zstdMLBits[mlCode]))
+		ll := zstdLLBase[llCode] + int(br.read(zstdLLBits[llCode]))
+
+		// Resolve the offset, handling repeat offsets.
+		var offset int
+		if ov > 3 {
+			offset = ov - 3
+			d.rep = [3]int{offset, d.rep[0], d.rep[1]}
+		} else {
+			if ll == 0 {
+				ov++
+			}
+			switch ov {
+			case 1:
+				offset = d.rep[0]
+			case 2:
+				offset = d.rep[1]
+				d.rep = [3]int{offset, d.rep[0], d.rep[2]}
+			case 3:
+				offset = d.rep[2]
+				d.rep = [3]int{offset, d.rep[0], d.rep[1]}
+			case 4:
+				offset = d.rep[0] - 1
+				d.rep = [3]int{offset, d.rep[0], d.rep[1]}
+			}
+		}
+
+		if ll > len(literals) {
+			return nil, errZstdCorrupt
+		}
+		out = append(out, literals[:ll]...)
+		literals = literals[ll:]
+		if offset <= 0 || offset > len(out)-frameStart {
+			return nil, errZstdCorrupt
+		}
+		// Copy a byte at a time since the match may overlap its output.
+		for j, from := 0, len(out)-offset; j < ml; j++ {
+			out = append(out, out[from+j])
+		}
+
+		if i < nseq-1 {
+			llState = d.llTable.nextState(llState, br)
+			mlState = d.mlTable.nextState(mlState, br)
+			ofState = d.ofTable.nextState(ofState, br)
+		}
+	}
+	return append(out, literals...), nil
+}
+
+// nextState returns the state that follows the given one after reading
+// its bits from br.
+func (t *fseTable) nextState(state int, br *zstdBitReader) int {
+	return t.baseline[state] + int(br.read(t.nbBits[state]))
+}
+
+// decodeLiterals decodes the literals section at the start of block and
+// returns the literals and the remainder of the block.
+func (d *zstdDecoder) decodeLiterals(block []byte) ([]byte, []byte, error) {
+	if len(block) < 1 {
+		return nil, nil, errZstdCorrupt
+	}
+	litType, sizeFormat := block[0]&3, (block[0]>>2)&3
+
+	if litType == 0 || litType == 1 {
+		// Raw or RLE
+		var size, hdr int
+		switch sizeFormat {
+		case 0, 2:
+			size, hdr = int(block[0])>>3, 1
+		case 1:
+			if len(block) < 2 {
+				return nil, nil, errZstdCorrupt
+			}
+			size, hdr = int(block[0])>>4|int(block[1])<<4, 2
+		case 3:
+			if len(block) < 3 {
+				return nil, nil, errZstdCorrupt
+			}
+			size, hdr = int(block[0])>>4|int(block[1])<<4|int(block[2])<<12, 3
+		}
+		block = block[hdr:]
+		if litType == 0 {
+			if size > len(block) {
+				return nil, nil, errZstdCorrupt
+			}
+			return block[:size], block[size:], nil
+		}
+		if len(block) < 1 {
+			return nil, nil, errZstdCorrupt
+		}
+		lit := make([]byte, size)
+		for i := range lit {
+			lit[i] = block[0]
+		}
+		return lit, block[1:], nil
+	}
+
+	// Huffman-compressed literals
+	hdr, sizeBits := []int{3, 3, 4, 5}[sizeFormat], []int{10, 10, 14, 18}[sizeFormat]
+	if len(block) < hdr {
+		return nil, nil, errZstdCorrupt
+	}
+	var h uint64
+	for i := hdr - 1; i >= 0; i-- {
+		h = h<<8 | uint64(block[i])
+	}
+	mask := uint64(1)<<sizeBits - 1
+	regenSize, compSize := int((h>>4)&mask), int((h>>(4+sizeBits))&mask)
+	block = block[hdr:]
+	if compSize > len(block) {
+		return nil, nil, errZstdCorrupt
+	}
+	data, rest := block[:compSize], block[compSize:]
+
+	if litType == 2 {
+		t, n, err := zstdReadHuffmanTable(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		d.huffman = t
+		data = data[n:]
+	} else if d.huffman == nil {
+		return nil, nil, errZstdCorrupt
+	}
+
+	lit := make([]byte, 0, regenSize)
+	if sizeFormat == 0 {
+		var err error
+		if lit, err = d.huffman.decode(lit, data, regenSize); err != nil {
+			return nil, nil, err
+		}
+		return lit, rest, nil
+	}
+
+	// Four streams, with a jump table giving the sizes of the first three.
+	if len(data) < 6 {
+		return nil, nil, errZstdCorrupt
+	}
+	sizes := []int{int(binary.LittleEndian.Uint16(data)), int(binary.LittleEndian.Uint16(data[2:])),
+		int(binary.LittleEndian.Uint16(data[4:]))}
+	data = data[6:]
+	sizes = append(sizes, len(data)-sizes[0]-sizes[1]-sizes[2])
+	streamSize := (regenSize + 3) / 4
+	for i, sz := range sizes {
+		if sz < 0 || sz > len(data) {
+			return nil, nil, errZstdCorrupt
+		}
+		n := streamSize
+		if i == 3 {
+			n = regenSize - 3*streamSize
+		}
+		var err error
+		if lit, err = d.huffman.decode(lit, data[:sz], n); err != nil {
+			return nil, nil, err
+		}
+		data = data[sz:]
+	}
+	return lit, rest, nil
+}
+
+// zstdHuffmanTable is a Huffman decoding table indexed by the next log
+// bits of the stream.
+type zstdHuffmanTable struct {
+	log    int
+	symbol []byte
+	nbBits []uint8
+}
+
+// zstdReadHuffmanTable reads a Huffman tree description, returning the
+// decoding table and the number of bytes used.
+func zstdReadHuffmanTable(b []byte) (*zstdHuffmanTable, int, error) {
+	if len(b) < 1 {
+		return nil, 0, errZstdCorrupt
+	}
+	var weights []int
+	n := int(b[0])
+	if n >= 128 {
+		// Weights stored directly, 4 bits each.
+		n -= 127
+		nb := (n + 1) / 2
+		if len(b) < 1+nb {
+			return nil, 0, errZstdCorrupt
+		}
+		for i := 0; i < n; i++ {
+			w := b[1+i/2]
+			if i%2 == 0 {
+				w >>= 4
+			}
+			weights = append(weights, int(w&0xf))
+		}
+		n = 1 + nb
+	} else {
+		// FSE-compressed weights, decoded with two interleaved states.
+		if len(b) < 1+n {
+			return nil, 0, errZstdCorrupt
+		}
+		data := b[1 : 1+n]
+		t, tn, err := zstdReadFSETable(data, 6, 255)
+		if err != nil {
+			return nil, 0, err
+		}
+		br, err := newZstdBitReader(data[tn:])
+		if err != nil {
+			return nil, 0, err
+		}
+		s1, s2 := int(br.read(t.log)), int(br.read(t.log))
+		for len(weights) < 255 {
+			weights = append(weights, t.symbol[s1])
+			s1 = t.nextState(s1, br)
+			if br.overflow() {
+				weights = append(weights, t.symbol[s2])
+				break
+			}
+			weights = append(weights, t.symbol[s2])
+			s2 = t.nextState(s2, br)
+			if br.overflow() {
+				weights = append(weights, t.symbol[s1])
+				break
+			}
+		}
+		n++
+	}
+
+	// The weight of the last symbol is implied by the others.
+	total := 0
+	for _, w := range weights {
+		if w > 12 {
+			return nil, 0, errZstdCorrupt
+		}
+		if w > 0 {
+			total += 1 << (w - 1)
+		}
+	}
+	if total == 0 || len(weights) > 255 {
+		return nil, 0, errZstdCorrupt
+	}
+	log := bits.Len(uint(total))
+	rest := 1<<log - total
+	if rest&(rest-1) != 0 || log > 11 {
+		return nil, 0, errZstdCorrupt
+	}
+	weights = append(weights, bits.Len(uint(rest)))
+
+	// Symbols are assigned table entries in order of increasing weight.
+	t := &zstdHuffmanTable{log: log, symbol: make([]byte, 1<<log), nbBits: make([]uint8, 1<<log)}
+	pos := 0
+	for w := 1; w <= log; w++ {
+		for s, sw := range weights {
+			if sw != w {
+				continue
+			}
+			for i := 0; i < 1<<(w-1); i++ {
+				t.symbol[pos] = byte(s)
+				t.nbBits[pos] = uint8(log + 1 - w)
+				pos++
+			}
+		}
+	}
+	return t, n, nil
+}
+
+// decode appends n symbols decoded from the stream in b to lit.
+func (t *zstdHuffmanTable) decode(lit []byte, b []byte, n int) ([]byte, error) {
+	br, err := newZstdBitReader(b)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < n; i++ {
+		v := br.peek(t.log)
+		lit = append(lit, t.symbol[v])
+		br.pos -= int(t.nbBits[v])
+	}
+	if br.overflow() {
+		return nil, errZstdCorrupt
+	}
+	return lit, nil
+}
+
+// zstdReadFSETable reads an FSE table description, returning the table
+// and the number of bytes used.
+func zstdReadFSETable(b []byte, maxLog, maxSymbol int) (*fseTable, int, error) {
+	bitpos := 0
+	peek := func(n int) int {
+		var v int
+		for i := 0; i < n; i++ {
+			p := bitpos + i
+			if p>>3 < len(b) && b[p>>3]&(1<<(p&7)) != 0 {
+				v |= 1 << i
+			}
+		}
+		return v
+	}
+
+	log := peek(4) + 5
+	bitpos += 4
+	if log > maxLog {
+		return nil, 0, errZstdCorrupt
+	}
+	remaining := 1<<log + 1
+	threshold, nbBits := 1<<log, log+1
+	var dist []int
+	prev0 := false
+	for remaining > 1 && len(dist) <= maxSymbol {
+		if prev0 {
+			n := len(dist)
+			for peek(16) == 0xffff {
+				n += 24
+				bitpos += 16
+			}
+			for peek(2) == 3 {
+				n += 3
+				bitpos += 2
+			}
+			n += peek(2)
+			bitpos += 2
+			if n > maxSymbol+1 {
+				return nil, 0, errZstdCorrupt
+			}
+			for len(dist) < n {
+				dist = append(dist, 0)
+			}
+			if len(dist) > maxSymbol {
+				break
+			}
+		}
+
+		max := 2*threshold - 1 - remaining
+		var count int
+		if v := peek(nbBits - 1); v < max {
+			count = v
+			bitpos += nbBits - 1
+		} else {
+			count = peek(nbBits)
+			if count >= threshold {
+				count -= max
+			}
+			bitpos += nbBits
+		}
+		count-- // -1 denotes a "less than one" probability
+		if count < 0 {
+			remaining--
+		} else {
+			remaining -= count
+		}
+		dist = append(dist, count)
+		prev0 = count == 0
+		for remaining < threshold && threshold > 1 {
+			nbBits--
+			threshold >>= 1
+		}
+	}
+	n := (bitpos + 7) / 8
+	if remaining != 1 || n > len(b) {
+		return nil, 0, errZstdCorrupt
+	}
+	return newFSETable(log, dist), n, nil
+}
+
+// zstdBitReader reads a bitstream backward from its end, as is done for
+// the Huffman and FSE streams. Bits past the start of the stream read as
+// zeros.
+type zstdBitReader struct {
+	b   []byte
+	pos int // number of unread bits
+}
+
+func newZstdBitReader(b []byte) (*zstdBitReader, error) {
+	if len(b) == 0 || b[len(b)-1] == 0 {
+		return nil, errZstdCorrupt
+	}
+	// Skip the padding up to and including the final 1 bit.
+	return &zstdBitReader{b: b, pos: (len(b)-1)*8 + bits.Len8(b[len(b)-1]) - 1}, nil
+}
+
+// peek returns the next n bits without consuming them.
+func (r *zstdBitReader) peek(n int) uint64 {
+	lo := r.pos - n
+	if lo < 0 {
+		if n+lo <= 0 {
+			return 0
+		}
+		return r.bits(0, n+lo) << -lo
+	}
+	return r.bits(lo, n)
+}
+
+// bits returns the n bits starting at bit lo.
+func (r *zstdBitReader) bits(lo, n int) uint64 {
+	var buf [8]byte
+	copy(buf[:], r.b[min(lo>>3, len(r.b)):])
+	return (binary.LittleEndian.Uint64(buf[:]) >> (lo & 7)) & (1<<n - 1)
+}
+
+func (r *zstdBitReader) read(n int) uint64 {
+	if n == 0 {
+		return 0
+	}
+	v := r.peek(n)
+	r.pos -= n
+	return v
+}
+
+// overflow reports whether more bits have been read than were available.
+func (r *zstdBitReader) overflow() bool {
+	return r.pos < 0
+}
@@ -0,0 +1,153 @@
+// zstd_test.go
+// Copyright(c) 2023 Matt Pharr, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// The files in testdata/zstd were written by the reference zstd
+// implementation (v1.5.6); the table gives the size and SHA-256 hash of
+// their decompressed contents.
+var zstdFixtures = []struct {
+	file   string
+	size   int
+	sha256 string
+}{
+	{"empty.zst", 0, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+	// A video map GOB file, at -19, with and without a checksum.
+	{"gob.zst", 941, "20274b16f4824c6cd8d43fc8bd7d43c5ced50e17da7986e1950a9d1bf40865c1"},
+	{"gob-nocheck.zst", 941, "20274b16f4824c6cd8d43fc8bd7d43c5ced50e17da7986e1950a9d1bf40865c1"},
+	// The same, after a skippable frame.
+	{"skippable.zst", 941, "20274b16f4824c6cd8d43fc8bd7d43c5ced50e17da7986e1950a9d1bf40865c1"},
+	// Two frames, compressed at different levels.
+	{"multi.zst", 12000, "b4e3e4f2f79773d5f404f19e9522b57d72c61c941e9a9dd15b5ec5530302add8"},
+	// Incompressible data, which is stored in raw blocks.
+	{"random.zst", 20000, "0df32aec2962bf2e437ee27cbb891ff426b3aba213006ef46bd6cf1b75d55d9e"},
+	// 1 MiB of zeros, in RLE blocks.
+	{"zeros.zst", 1 << 20, "30e14955ebf1352266dc2ff8067e68104607e750abb9d3b36582b8af909fcb58"},
+	// Text that spans multiple blocks at various levels, and compressed
+	// from a stream so that the frame doesn't give the content size.
+	{"text-1.zst", 223974, "1ca5c5ff1e7c84b44ecb7ff8106e635f3a7da83e2c87285926e3ee70e9c54bec"},
+	{"text-3.zst", 223974, "1ca5c5ff1e7c84b44ecb7ff8106e635f3a7da83e2c87285926e3ee70e9c54bec"},
+	{"text-19.zst", 223974, "1ca5c5ff1e7c84b44ecb7ff8106e635f3a7da83e2c87285926e3ee70e9c54bec"},
+	{"text-stream.zst", 223974, "1ca5c5ff1e7c84b44ecb7ff8106e635f3a7da83e2c87285926e3ee70e9c54bec"},
+}
+
+func readZstdFixture(t *testing.T, fn string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", "zstd", fn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestZstdDecompressFixtures(t *testing.T) {
+	for _, f := range zstdFixtures {
+		t.Run(f.file, func(t *testing.T) {
+			out, err := zstdDecompress(readZstdFixture(t, f.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			sum := sha256.Sum256(out)
+			if len(out) != f.size || hex.EncodeToString(sum[:]) != f.sha256 {
+				t.Errorf("got %d bytes with SHA-256 %x, want %d bytes with %s", len(out), sum, f.size, f.sha256)
+			}
+		})
+	}
+}
+
+// zstdTestInputs returns a variety of inputs for compression tests.
+func zstdTestInputs(t *testing.T) map[string][]byte {
+	r := rand.New(rand.NewSource(1))
+	random := make([]byte, 50000)
+	r.Read(random)
+	text, err := zstdDecompress(readZstdFixture(t, "text-3.zst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gob, err := zstdDecompress(readZstdFixture(t, "gob.zst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string][]byte{
+		"empty":  {},
+		"byte":   {42},
+		"zeros":  make([]byte, 300000),
+		"random": random,
+		"text":   text,
+		"gob":    gob,
+	}
+}
+
+func TestZstdRoundTrip(t *testing.T) {
+	for name, in := range zstdTestInputs(t) {
+		c := zstdCompress(in)
+		out, err := zstdDecompress(c)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if !bytes.Equal(in, out) {
+			t.Errorf("%s: decompressed data differs", name)
+		}
+	}
+}
+
+// TestZstdReference checks that the reference implementation can
+// decompress what we write, if it's installed.
+func TestZstdReference(t *testing.T) {
+	zstd, err := exec.LookPath("zstd")
+	if err != nil {
+		t.Skip("zstd not found")
+	}
+	for name, in := range zstdTestInputs(t) {
+		cmd := exec.Command(zstd, "-d", "-c")
+		cmd.Stdin = bytes.NewReader(zstdCompress(in))
+		out, err := cmd.Output()
+		if err != nil {
+			t.Errorf("%s: zstd: %v", name, err)
+		} else if !bytes.Equal(in, out) {
+			t.Errorf("%s: decompressed data differs", name)
+		}
+	}
+}
+
+func TestZstdCorrupt(t *testing.T) {
+	b := readZstdFixture(t, "text-3.zst")
+	for _, n := range []int{1, 4, 5, 10, 100, len(b) / 2, len(b) - 5} {
+		if _, err := zstdDecompress(b[:n]); err == nil {
+			t.Errorf("truncated to %d bytes: expected an error", n)
+		}
+	}
+
+	// Flipping bytes in the compressed data should give an error or
+	// different output, but it mustn't panic.
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < 200; i++ {
+		c := bytes.Clone(b)
+		c[8+r.Intn(len(c)-8)] ^= byte(1 + r.Intn(255))
+		zstdDecompress(c)
+	}
+}
+
+func TestZstdInputLimit(t *testing.T) {
+	defer func(size int64) { inputLimits.Size = size }(inputLimits.Size)
+	inputLimits.Size = 1 << 16
+
+	if _, err := zstdDecompress(readZstdFixture(t, "zeros.zst")); err == nil {
+		t.Errorf("expected an error for data larger than the limit")
+	}
+	if _, err := zstdDecompress(readZstdFixture(t, "gob.zst")); err != nil {
+		t.Errorf("gob.zst: %v", err)
+	}
+}